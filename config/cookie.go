@@ -0,0 +1,42 @@
+package config
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type CookieConfig struct {
+	Enabled  bool
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+	MaxAge   int
+}
+
+// AuthCookieConfig reads the cookie authentication settings from the
+// environment. Cookie mode is off unless AUTH_COOKIE_MODE is "true"; cookies
+// are Secure unless AUTH_COOKIE_SECURE is "false".
+func AuthCookieConfig() CookieConfig {
+	maxAge, err := strconv.Atoi(os.Getenv("AUTH_COOKIE_MAX_AGE"))
+	if err != nil || maxAge <= 0 {
+		maxAge = 7 * 24 * 60 * 60
+	}
+
+	sameSite := http.SameSiteLaxMode
+	switch strings.ToLower(os.Getenv("AUTH_COOKIE_SAMESITE")) {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+
+	return CookieConfig{
+		Enabled:  os.Getenv("AUTH_COOKIE_MODE") == "true",
+		Domain:   os.Getenv("AUTH_COOKIE_DOMAIN"),
+		Secure:   os.Getenv("AUTH_COOKIE_SECURE") != "false",
+		SameSite: sameSite,
+		MaxAge:   maxAge,
+	}
+}
@@ -19,8 +19,9 @@ func DBConfig() string {
 	db_Password := os.Getenv("DB_PASSWORD")
 	db_Name := os.Getenv("DB_NAME")
 	db_Port := os.Getenv("DB_PORT")
-	db_Timezone := os.Getenv("DB_TZ")
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=%s", db_Host, db_User, db_Password, db_Name, db_Port, db_Timezone)
+	// Timestamps are stored as instants and read back in UTC; sessions keep
+	// their own time zone for display.
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC", db_Host, db_User, db_Password, db_Name, db_Port)
 	return dsn
 }
 
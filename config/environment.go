@@ -2,6 +2,8 @@ package config
 
 import (
 	"log"
+	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -12,3 +14,23 @@ func EnvLoad() {
 		log.Fatal("Error loading .env file")
 	}
 }
+
+// ServerURL returns the public base URL of the server from SERVER_URL,
+// without a trailing slash. It is used to build absolute callback URLs.
+func ServerURL() string {
+	return strings.TrimRight(os.Getenv("SERVER_URL"), "/")
+}
+
+// AntiEnumerationEnabled reports whether AUTH_ANTI_ENUMERATION is "true". In
+// that mode registration goes through email verification so auth responses
+// never reveal whether an account exists.
+func AntiEnumerationEnabled() bool {
+	return os.Getenv("AUTH_ANTI_ENUMERATION") == "true"
+}
+
+// RowLevelSecurityEnabled reports whether DB_ROW_LEVEL_SECURITY is "true". In
+// that mode Postgres itself limits workspace-owned tables to the workspace set
+// on the current transaction.
+func RowLevelSecurityEnabled() bool {
+	return os.Getenv("DB_ROW_LEVEL_SECURITY") == "true"
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"errors"
 	"fmt"
+	"server/utils"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -21,6 +23,8 @@ func MsgForTag(fe validator.FieldError) string {
 	switch fe.Tag() {
 	case "required":
 		return "This field is required."
+	case "required_without":
+		return fmt.Sprintf("This field is required when %s is not set.", fe.Param())
 	case "email":
 		return "Invalid email format."
 	case "min":
@@ -45,8 +49,20 @@ func MsgForTag(fe validator.FieldError) string {
 		return "Value must be a file."
 	case "url":
 		return "Value must be a valid URL."
+	case "http_url":
+		return "Value must be an http or https URL."
 	case "base64":
 		return "Value must be a valid Base64 string."
+	case "oneof":
+		return fmt.Sprintf("Value must be one of: %s.", strings.Join(strings.Fields(fe.Param()), ", "))
+	case "session_category":
+		return fmt.Sprintf("Value must be one of: %s.", strings.Join(utils.SESSION_CATEGORIES, ", "))
+	case "datetime":
+		return "Value must be an RFC 3339 timestamp with an offset, e.g. 2025-03-01T14:00:00+01:00."
+	case "timezone":
+		return "Value must be a valid IANA time zone, e.g. Europe/Berlin."
+	case "bcp47_language_tag":
+		return "Value must be a valid locale, e.g. en-US."
 	default:
 		return fe.Error() // Fallback to the default error message if no custom message is specified
 	}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Plan holds the limits of a subscription tier. A limit of 0 means unlimited.
+type Plan struct {
+	Name         string `json:"name"`
+	Seats        int64  `json:"seats"`
+	MaxSessions  int64  `json:"max_sessions"`
+	StorageBytes int64  `json:"storage_bytes"`
+}
+
+const PLAN_FREE string = "free"
+const PLAN_PRO string = "pro"
+const PLAN_BUSINESS string = "business"
+const PLAN_ENTERPRISE string = "enterprise"
+
+var defaultPlans = []Plan{
+	{Name: PLAN_FREE, Seats: 3, MaxSessions: 25, StorageBytes: 500 << 20},
+	{Name: PLAN_PRO, Seats: 10, MaxSessions: 500, StorageBytes: 10 << 30},
+	{Name: PLAN_BUSINESS, Seats: 50, MaxSessions: 5000, StorageBytes: 100 << 30},
+	{Name: PLAN_ENTERPRISE},
+}
+
+// Plans returns every plan the server knows about. The defaults can be
+// overridden per plan with PLAN_<NAME>_SEATS, PLAN_<NAME>_MAX_SESSIONS and
+// PLAN_<NAME>_STORAGE_BYTES.
+func Plans() []Plan {
+	plans := make([]Plan, 0, len(defaultPlans))
+	for _, plan := range defaultPlans {
+		prefix := "PLAN_" + strings.ToUpper(plan.Name) + "_"
+		plan.Seats = planLimit(prefix+"SEATS", plan.Seats)
+		plan.MaxSessions = planLimit(prefix+"MAX_SESSIONS", plan.MaxSessions)
+		plan.StorageBytes = planLimit(prefix+"STORAGE_BYTES", plan.StorageBytes)
+		plans = append(plans, plan)
+	}
+	return plans
+}
+
+// PlanByName returns the plan with the given name, or the free plan when
+// there is no such plan.
+func PlanByName(name string) Plan {
+	plans := Plans()
+	for _, plan := range plans {
+		if plan.Name == name {
+			return plan
+		}
+	}
+	return plans[0]
+}
+
+func planLimit(key string, fallback int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"server/utils"
+	"slices"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegisterValidators adds the validation tags of this server to the validator
+// gin binds requests with:
+//   - session_category: one of utils.SESSION_CATEGORIES.
+func RegisterValidators() error {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil
+	}
+	return validate.RegisterValidation("session_category", func(fl validator.FieldLevel) bool {
+		return slices.Contains(utils.SESSION_CATEGORIES, fl.Field().String())
+	})
+}
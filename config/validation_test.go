@@ -0,0 +1,47 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func TestSessionCategoryValidation(t *testing.T) {
+	if err := RegisterValidators(); err != nil {
+		t.Fatal(err)
+	}
+	type payload struct {
+		Category string `binding:"omitempty,session_category"`
+	}
+
+	for category, valid := range map[string]bool{"1": true, "4": true, "": true, "5": false, "files": false} {
+		err := binding.Validator.ValidateStruct(payload{Category: category})
+		if valid && err != nil {
+			t.Errorf("category %q: %v", category, err)
+		}
+		var ve validator.ValidationErrors
+		if !valid && (!errors.As(err, &ve) || MsgForTag(ve[0]) != "Value must be one of: 1, 2, 3, 4.") {
+			t.Errorf("category %q: error = %v, want a session_category error", category, err)
+		}
+	}
+}
+
+func TestAvatarMustBeAnHTTPURL(t *testing.T) {
+	type payload struct {
+		Avatar string `binding:"omitempty,http_url"`
+	}
+	for avatar, valid := range map[string]bool{
+		"https://cdn.example.com/a.png": true,
+		"http://example.com/a.png":      true,
+		"javascript:alert(1)":           false,
+		"data:image/png;base64,AAAA":    false,
+		"file:///etc/passwd":            false,
+	} {
+		err := binding.Validator.ValidateStruct(payload{Avatar: avatar})
+		if valid != (err == nil) {
+			t.Errorf("avatar %q: error = %v, want valid = %v", avatar, err, valid)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/types"
+	"server/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List users
+// @Description Search users by name or email and filter them by status, provider and creation date. Admins only.
+// @ID admin-list-users
+// @Produce  json
+// @Param q query string false "Name or email contains"
+// @Param status query string false "active or suspended"
+// @Param provider query string false "password, google or saml"
+// @Param created_from query string false "Created at or after (RFC 3339)"
+// @Param created_to query string false "Created before (RFC 3339)"
+// @Param page query int false "Page number, starting at 1"
+// @Param per_page query int false "Users per page, at most 100"
+// @Success 200 {object} types.AdminUserListResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/users [get]
+// @Security BearerAuth
+func AdminListUsers(c *gin.Context) {
+	var query types.AdminUserQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid query parameters"})
+		return
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.PerPage == 0 {
+		query.PerPage = 20
+	}
+
+	users, total, err := models.SearchUsers(query)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to fetch users"})
+		return
+	}
+
+	response := types.AdminUserListResponse{
+		Users:   make([]types.AdminUserResponse, 0, len(users)),
+		Total:   total,
+		Page:    query.Page,
+		PerPage: query.PerPage,
+	}
+	for i := range users {
+		response.Users = append(response.Users, adminUserResponse(&users[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": response, "message": "Users fetched successfully"})
+}
+
+// @Summary Get user
+// @Description Get a user's account details. Admins only.
+// @ID admin-get-user
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} types.AdminUserResponse
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id} [get]
+// @Security BearerAuth
+func AdminGetUser(c *gin.Context) {
+	userData, ok := adminUserParam(c, false)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": adminUserResponse(userData), "message": "User data fetched successfully"})
+}
+
+// @Summary Suspend user
+// @Description Block a user from signing in and sign them out everywhere. Admins only.
+// @ID admin-suspend-user
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} types.AdminUserResponse
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/suspend [post]
+// @Security BearerAuth
+func AdminSuspendUser(c *gin.Context) {
+	adminUserAction(c, models.SuspendUser, "User suspended successfully")
+}
+
+// @Summary Unsuspend user
+// @Description Allow a suspended user to sign in again. Admins only.
+// @ID admin-unsuspend-user
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} types.AdminUserResponse
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/unsuspend [post]
+// @Security BearerAuth
+func AdminUnsuspendUser(c *gin.Context) {
+	adminUserAction(c, models.UnsuspendUser, "User unsuspended successfully")
+}
+
+// @Summary Force password reset
+// @Description Sign a user out everywhere and require them to set a new password through an emailed reset link. Admins only.
+// @ID admin-force-password-reset
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} types.AdminUserResponse
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/force-password-reset [post]
+// @Security BearerAuth
+func AdminForcePasswordReset(c *gin.Context) {
+	adminUserAction(c, models.ForcePasswordReset, "Password reset required for user")
+}
+
+// @Summary Revoke tokens
+// @Description Invalidate every token issued to a user so far. Admins only.
+// @ID admin-revoke-tokens
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} types.AdminUserResponse
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/revoke-tokens [post]
+// @Security BearerAuth
+func AdminRevokeUserTokens(c *gin.Context) {
+	adminUserAction(c, models.RevokeUserTokens, "User tokens revoked successfully")
+}
+
+// @Summary Delete user
+// @Description Permanently delete a user and their memberships. Their sessions go to the owner of each workspace. Owners have to transfer or delete their workspaces first. Admins only.
+// @ID admin-delete-user
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /admin/users/{id} [delete]
+// @Security BearerAuth
+func AdminDeleteUser(c *gin.Context) {
+	userData, ok := adminUserParam(c, true)
+	if !ok {
+		return
+	}
+
+	err := models.DeleteUser(userData.ID)
+	if errors.Is(err, models.ErrUserOwnsWorkspaces) {
+		c.JSON(http.StatusConflict, gin.H{"status": "error", "data": nil, "message": "The user owns workspaces. Transfer their ownership or delete them first."})
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to delete user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": nil, "message": "User deleted successfully"})
+}
+
+func adminUserAction(c *gin.Context, action func(id int) (*types.User, error), message string) {
+	userData, ok := adminUserParam(c, true)
+	if !ok {
+		return
+	}
+
+	updated, err := action(userData.ID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to update user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": adminUserResponse(updated), "message": message})
+}
+
+// adminUserParam loads the user named by the :id path parameter. Admins can
+// look themselves up but not act on their own account, so they can't lock
+// themselves out.
+func adminUserParam(c *gin.Context, action bool) (*types.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid user ID"})
+		return nil, false
+	}
+
+	admin, ok := contextUser(c)
+	if !ok {
+		return nil, false
+	}
+	if action && admin.ID == id {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "You can't do this to your own account"})
+		return nil, false
+	}
+
+	userData, _ := models.FetchUser(id)
+	if userData == nil {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "data": nil, "message": "User not found"})
+		return nil, false
+	}
+	return userData, true
+}
+
+func adminUserResponse(userData *types.User) types.AdminUserResponse {
+	status := utils.USER_STATUS_ACTIVE
+	if userData.SuspendedAt != nil {
+		status = utils.USER_STATUS_SUSPENDED
+	}
+	return types.AdminUserResponse{
+		ID:                    userData.ID,
+		Name:                  userData.Name,
+		Email:                 userData.Email,
+		Avatar:                userData.Avatar,
+		Role:                  userData.Role,
+		Provider:              userData.Provider,
+		Status:                status,
+		SuspendedAt:           userData.SuspendedAt,
+		TokensRevokedAt:       userData.TokensRevokedAt,
+		PasswordResetRequired: userData.PasswordResetRequired,
+		CreatedAt:             userData.CreatedAt,
+		UpdatedAt:             userData.UpdatedAt,
+	}
+}
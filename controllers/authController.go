@@ -6,6 +6,7 @@ import (
 	"server/config"
 	"server/models"
 	"server/types"
+	"server/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,7 +32,10 @@ func Login(c *gin.Context) {
 
 	// Retreive user data
 	userData, _ := models.FetchUserByEmail(loginData.Email)
-	if userData == nil {
+	if userData == nil || userData.Password == "" {
+		// Spend the same time as a wrong password so timing doesn't reveal
+		// whether the account exists.
+		models.CheckDummyPassword(loginData.Password)
 		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "data": nil, "message": "Invalid email or password"})
 		return
 	}
@@ -42,28 +46,31 @@ func Login(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "data": nil, "message": "Invalid email or password"})
 		return
 	}
+	if userData.SuspendedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "User account is suspended"})
+		return
+	}
+	if userData.PasswordResetRequired {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Password reset required. Check your email for a reset link."})
+		return
+	}
 	user := types.User{
-		ID:       userData.ID,
-		Name:     userData.Name,
-		Email:    loginData.Email,
-		Password: loginData.Password,
+		ID:    userData.ID,
+		Name:  userData.Name,
+		Email: loginData.Email,
 	}
 
 	// Generate JWT token
-	tokenString, tokenError := models.CreateJWTToken(user)
+	tokenString, tokenError := createLoginToken(user)
 	if tokenError != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to register user"})
 		return
 	}
-	response := types.AuthResponse{
-		Token: tokenString,
-	}
-
-	c.JSON(http.StatusOK, gin.H{"status": "success", "data": response, "message": "User login successful."})
+	sendAuthResponse(c, tokenString, "User login successful.")
 }
 
 // @Summary Register
-// @Description User registration
+// @Description User registration. When AUTH_ANTI_ENUMERATION is enabled no token is returned; a verification email is sent instead and the account is created by /auth/verify-email.
 // @ID register
 // @Accept  json
 // @Produce  json
@@ -81,10 +88,16 @@ func Register(c *gin.Context) {
 		}
 	}
 
+	if config.AntiEnumerationEnabled() {
+		registerWithVerification(c, registerData)
+		return
+	}
+
 	user := types.User{
 		Name:     registerData.Name,
 		Email:    registerData.Email,
 		Password: registerData.Password,
+		Provider: utils.USER_PROVIDER_PASSWORD,
 	}
 
 	// Retreive user data
@@ -111,18 +124,17 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
 	user.ID = saveUserData.ID
-	tokenString, tokenError := models.CreateJWTToken(user)
+	acceptSignupInvitation(registerData.InvitationToken, &user)
+
+	// Generate JWT token
+	tokenString, tokenError := createLoginToken(user)
 	if tokenError != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to register user"})
 		return
 	}
 
-	response := types.AuthResponse{
-		Token: tokenString,
-	}
-	c.JSON(http.StatusOK, gin.H{"status": "success", "data": response, "message": "User registration successful."})
+	sendAuthResponse(c, tokenString, "User registration successful.")
 }
 
 // @Summary Social Login
@@ -153,6 +165,12 @@ func SocialLogin(c *gin.Context) {
 			return
 		}
 
+		if authData.SuspendedAt != nil {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "User account is suspended"})
+			return
+		}
+		acceptSignupInvitation(payload.InvitationToken, authData)
+
 		// Generate JWT token
 		user := types.User{
 			ID:    authData.ID,
@@ -160,17 +178,204 @@ func SocialLogin(c *gin.Context) {
 			Email: authData.Email,
 		}
 
-		tokenString, tokenError := models.CreateJWTToken(user)
+		tokenString, tokenError := createLoginToken(user)
 		if tokenError != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "data": nil, "message": "Failed to register user"})
 			return
 		}
 
-		response := types.AuthResponse{
-			Token: tokenString,
-		}
-		c.JSON(http.StatusOK, gin.H{"status": "success", "data": response, "message": "User registration successful."})
+		sendAuthResponse(c, tokenString, "User registration successful.")
 		return
 	}
 	c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "User registration failed."})
 }
+
+// @Summary Logout
+// @Description Clear the authentication cookies set in cookie mode
+// @ID logout
+// @Produce  json
+// @Success 200 {object} map[string]string
+// @Router /auth/logout [post]
+func Logout(c *gin.Context) {
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": nil, "message": "User logout successful."})
+}
+
+// @Summary Verify email
+// @Description Complete a registration started while AUTH_ANTI_ENUMERATION is enabled
+// @ID verify-email
+// @Accept  json
+// @Produce  json
+// @Param token body types.VerifyEmailPayload true "Verification token"
+// @Success 200 {object} types.AuthResponse
+// @Failure 400 {object} map[string]string
+// @Router /auth/verify-email [post]
+func VerifyEmail(c *gin.Context) {
+	var payload types.VerifyEmailPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+	}
+
+	user, err := models.CompletePendingRegistration(payload.Token)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid or expired verification link"})
+		return
+	}
+	acceptSignupInvitation(payload.InvitationToken, user)
+
+	tokenString, tokenError := createLoginToken(*user)
+	if tokenError != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to register user"})
+		return
+	}
+	sendAuthResponse(c, tokenString, "User registration successful.")
+}
+
+// @Summary Forgot password
+// @Description Send a password reset link. The response is the same whether or not an account exists for the email.
+// @ID forgot-password
+// @Accept  json
+// @Produce  json
+// @Param email body types.ForgotPasswordPayload true "Account email"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/forgot-password [post]
+func ForgotPassword(c *gin.Context) {
+	var payload types.ForgotPasswordPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+	}
+
+	// The token is created in the background so the response time doesn't
+	// depend on whether the account exists.
+	go func(email string) {
+		userData, _ := models.FetchUserByEmail(email)
+		if userData == nil {
+			return
+		}
+		token, err := models.CreatePasswordResetToken(userData.ID)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		utils.SendMailAsync(userData.Email, "Reset your password",
+			fmt.Sprintf("Use the link below to choose a new password. It expires in one hour.\n\n%s/reset-password?token=%s\n\nIf you didn't ask to reset your password you can ignore this email.", utils.AppURL(), token))
+	}(payload.Email)
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": nil, "message": "If an account exists for this email, a password reset link has been sent."})
+}
+
+// @Summary Reset password
+// @Description Set a new password using the token from a password reset email
+// @ID reset-password
+// @Accept  json
+// @Produce  json
+// @Param reset body types.ResetPasswordPayload true "Reset token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/reset-password [post]
+func ResetPassword(c *gin.Context) {
+	var payload types.ResetPasswordPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+	}
+
+	if err := models.ResetPassword(payload.Token, payload.Password); err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid or expired reset link"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": nil, "message": "Password reset successful."})
+}
+
+// @Summary Switch workspace
+// @Description Issue a new token with another workspace as the active workspace. The token carries the workspace ID and the caller's role in it, so later requests don't need the X-Workspace-ID header.
+// @ID switch-workspace
+// @Accept  json
+// @Produce  json
+// @Param workspace body types.SwitchWorkspacePayload true "Workspace to switch to"
+// @Success 200 {object} types.AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /auth/switch-workspace [post]
+// @Security BearerAuth
+func SwitchWorkspace(c *gin.Context) {
+	var payload types.SwitchWorkspacePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+
+	user, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	workspace, _ := models.FetchWorkspace(payload.WorkspaceId)
+	if workspace == nil || workspace.DeletedAt != nil {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "data": nil, "message": "Workspace not found"})
+		return
+	}
+	workspaceUser, _ := models.FetchWorkspaceUser(payload.WorkspaceId, user.ID)
+	if workspaceUser == nil {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "You are not a member of this workspace"})
+		return
+	}
+
+	tokenString, tokenError := models.CreateJWTToken(*user, workspaceUser)
+	if tokenError != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to switch workspace"})
+		return
+	}
+	sendAuthResponse(c, tokenString, "Workspace switched successfully.")
+}
+
+// registerWithVerification handles registration in anti-enumeration mode. The
+// password is always hashed and the lookup and email happen in the
+// background, so the response and its timing are the same whether or not the
+// address is taken.
+func registerWithVerification(c *gin.Context, registerData types.RegisterPayload) {
+	hashPassword, hashPasswordError := models.HashPassword(registerData.Password)
+	if hashPasswordError != nil {
+		fmt.Println(hashPasswordError)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to register user"})
+		return
+	}
+
+	go func(registerData types.RegisterPayload) {
+		userData, _ := models.FetchUserByEmail(registerData.Email)
+		if userData != nil {
+			utils.SendMailAsync(registerData.Email, "Sign-up attempt",
+				fmt.Sprintf("Someone tried to create an account with this email address, but you already have one.\n\nSign in at %s/login or reset your password at %s/forgot-password.", utils.AppURL(), utils.AppURL()))
+			return
+		}
+		token, err := models.CreatePendingRegistration(registerData.Name, registerData.Email, hashPassword)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		utils.SendMailAsync(registerData.Email, "Confirm your email address",
+			fmt.Sprintf("Use the link below to finish creating your account. It expires in 24 hours.\n\n%s/verify-email?token=%s", utils.AppURL(), token))
+	}(registerData)
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": nil, "message": "If this email can be registered, a confirmation link has been sent."})
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"server/models"
+	"server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Upload user avatar
+// @Description Upload a JPEG, PNG or GIF avatar for the current user. Metadata is stripped and square thumbnails are generated in several sizes.
+// @ID upload-user-avatar
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param avatar formData file true "Avatar image"
+// @Success 200 {object} types.UserResponse
+// @Failure 400 {object} map[string]string
+// @Router /user/avatar [put]
+// @Security BearerAuth
+func UploadUserAvatar(c *gin.Context) {
+	user, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	data, ok := avatarFormFile(c)
+	if !ok {
+		return
+	}
+
+	avatar, sizes, err := models.UploadAvatar(fmt.Sprintf("avatars/users/%d", user.ID), data)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to process avatar"})
+		return
+	}
+
+	userData, err := models.SaveUserAvatar(user.ID, avatar, sizes)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to update user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": userResponse(userData), "message": "Avatar updated successfully"})
+}
+
+// @Summary Upload workspace avatar
+// @Description Upload a JPEG, PNG or GIF avatar for a workspace. Only workspace owners can change it.
+// @ID upload-workspace-avatar
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Param avatar formData file true "Avatar image"
+// @Success 200 {object} types.Workspace
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /workspaces/{id}/avatar [put]
+// @Security BearerAuth
+func UploadWorkspaceAvatar(c *gin.Context) {
+	workspace, ok := ownedWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+
+	data, ok := avatarFormFile(c)
+	if !ok {
+		return
+	}
+
+	avatar, sizes, err := models.UploadAvatar(fmt.Sprintf("avatars/workspaces/%d", workspace.ID), data)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to process avatar"})
+		return
+	}
+
+	workspace, err = models.SaveWorkspaceAvatar(workspace.ID, avatar, sizes)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to update workspace"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": workspace, "message": "Avatar updated successfully"})
+}
+
+// avatarFormFile reads the "avatar" multipart file, enforcing AVATAR_MAX_UPLOAD_BYTES.
+func avatarFormFile(c *gin.Context) ([]byte, bool) {
+	fileHeader, err := c.FormFile("avatar")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Avatar file is required"})
+		return nil, false
+	}
+	if fileHeader.Size > utils.AVATAR_MAX_UPLOAD_BYTES {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"status": "error", "data": nil, "message": "Avatar file is too large"})
+		return nil, false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to read avatar file"})
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := utils.ReadLimited(file, utils.AVATAR_MAX_UPLOAD_BYTES)
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"status": "error", "data": nil, "message": "Avatar file is too large"})
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/models"
+	"server/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Create calendar feed
+// @Description Issue a secret URL serving an iCalendar feed of the sessions the current user created or collaborates on, in every workspace they belong to. The URL is only shown once; creating a new one revokes the previous URL.
+// @ID create-calendar-feed
+// @Produce  json
+// @Success 201 {object} types.CalendarFeedResponse
+// @Failure 500 {object} map[string]string
+// @Router /user/calendar-feed [post]
+// @Security BearerAuth
+func CreateCalendarFeed(c *gin.Context) {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	feed, err := models.CreateCalendarFeed(data.ID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to create calendar feed"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "success", "data": feed, "message": "Calendar feed created successfully"})
+}
+
+// @Summary Delete calendar feed
+// @Description Revoke the current user's calendar feed URL.
+// @ID delete-calendar-feed
+// @Produce  json
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /user/calendar-feed [delete]
+// @Security BearerAuth
+func DeleteCalendarFeed(c *gin.Context) {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	err := models.DeleteCalendarFeed(data.ID)
+	if errors.Is(err, models.ErrCalendarFeedNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "data": nil, "message": "No calendar feed to delete"})
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to delete calendar feed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": nil, "message": "Calendar feed deleted successfully"})
+}
+
+// @Summary Calendar feed
+// @Description Serve an RFC 5545 feed of a user's sessions for calendar apps to subscribe to. The secret token in the URL authenticates the request.
+// @ID get-calendar-feed
+// @Produce  text/calendar
+// @Param token path string true "Feed token, optionally followed by .ics"
+// @Success 200 {string} string
+// @Failure 404 {string} string
+// @Router /calendar/{token} [get]
+func GetCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	calendar, err := models.FetchCalendarFeed(c.Request.Context(), token)
+	if errors.Is(err, models.ErrCalendarFeedNotFound) {
+		c.String(http.StatusNotFound, "Calendar feed not found")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.String(http.StatusInternalServerError, "Failed to build calendar feed")
+		return
+	}
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, utils.CALENDAR_CONTENT_TYPE, calendar)
+}
+
+// @Summary Download session calendar event
+// @Description Download a session as an .ics file to import into a calendar app. Only its creator and collaborators can do this.
+// @ID download-session-calendar
+// @Produce  text/calendar
+// @Param X-Workspace-ID header int false "Workspace ID, defaults to the workspace of the token"
+// @Param id path int true "Session ID"
+// @Success 200 {string} string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /sessions/{id}/calendar [get]
+// @Security BearerAuth
+func DownloadSessionCalendar(c *gin.Context) {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	sessionId, ok := sessionParam(c)
+	if !ok {
+		return
+	}
+
+	calendar, err := models.FetchSessionCalendar(c.Request.Context(), sessionId, data.ID)
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"session-%d.ics\"", sessionId))
+	c.Data(http.StatusOK, utils.CALENDAR_CONTENT_TYPE, calendar)
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/models"
+	"server/types"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Export personal data
+// @Description Start building a ZIP archive with the current user's profile, workspaces, sessions and attachments. Poll the status endpoint for the download link.
+// @ID start-data-export
+// @Produce  json
+// @Success 202 {object} types.DataExportResponse
+// @Failure 409 {object} map[string]string
+// @Router /user/export [post]
+// @Security BearerAuth
+func StartDataExport(c *gin.Context) {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	export, err := models.CreateDataExport(data.ID)
+	if errors.Is(err, models.ErrDataExportInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"status": "error", "data": nil, "message": "A data export is already in progress"})
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to start data export"})
+		return
+	}
+
+	go models.RunDataExport(export.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "success", "data": dataExportResponse(export, ""), "message": "Data export started"})
+}
+
+// @Summary Get data export
+// @Description Get the status of a personal data export. Completed exports include a download link that is valid for a short time; fetch the export again for a new one.
+// @ID get-data-export
+// @Produce  json
+// @Param id path int true "Export ID"
+// @Success 200 {object} types.DataExportResponse
+// @Failure 404 {object} map[string]string
+// @Router /user/export/{id} [get]
+// @Security BearerAuth
+func GetDataExport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid export ID"})
+		return
+	}
+
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	export, _ := models.FetchDataExport(id, data.ID)
+	if export == nil {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "data": nil, "message": "Data export not found"})
+		return
+	}
+
+	downloadURL, err := models.DataExportDownloadURL(export)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to create download link"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": dataExportResponse(export, downloadURL), "message": "Data export fetched successfully"})
+}
+
+func dataExportResponse(export *types.DataExport, downloadURL string) types.DataExportResponse {
+	return types.DataExportResponse{
+		ID:          export.ID,
+		Status:      export.Status,
+		DownloadURL: downloadURL,
+		CompletedAt: export.CompletedAt,
+		ExpiresAt:   export.ExpiresAt,
+		CreatedAt:   export.CreatedAt,
+	}
+}
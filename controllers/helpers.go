@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/types"
+	"server/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contextUser returns the user stored in the request context by
+// middleware.AuthMiddleware. When it is missing an error response is written
+// and ok is false.
+func contextUser(c *gin.Context) (user *types.User, ok bool) {
+	ctxUserData, ctxUserDataExists := c.Get("user")
+	if !ctxUserDataExists {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "User not found"})
+		return nil, false
+	}
+
+	user, _ = ctxUserData.(*types.User)
+	if user == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to retrieve user data"})
+		return nil, false
+	}
+	return user, true
+}
+
+// createLoginToken creates the token of a user who just signed in, with their
+// primary workspace as the active workspace.
+func createLoginToken(user types.User) (string, error) {
+	workspaceUser, err := models.FetchPrimaryWorkspaceUser(user.ID)
+	if err != nil {
+		return "", err
+	}
+	return models.CreateJWTToken(user, workspaceUser)
+}
+
+// sendAuthResponse writes a successful login response. In cookie mode the
+// token is only sent as an HttpOnly cookie and the body carries the CSRF token
+// the client has to echo in the X-CSRF-Token header.
+func sendAuthResponse(c *gin.Context, tokenString, message string) {
+	response := types.AuthResponse{
+		Token: tokenString,
+	}
+	if config.AuthCookieConfig().Enabled {
+		csrfToken, err := setAuthCookies(c, tokenString)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to create session"})
+			return
+		}
+		response = types.AuthResponse{
+			CSRFToken: csrfToken,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": response, "message": message})
+}
+
+// setAuthCookies sets the HttpOnly auth cookie and the readable CSRF cookie
+// and returns the CSRF token.
+func setAuthCookies(c *gin.Context, tokenString string) (string, error) {
+	csrfToken, err := models.GenerateCSRFToken()
+	if err != nil {
+		return "", err
+	}
+	cookieConfig := config.AuthCookieConfig()
+	c.SetSameSite(cookieConfig.SameSite)
+	c.SetCookie(utils.AUTH_COOKIE_NAME, tokenString, cookieConfig.MaxAge, "/", cookieConfig.Domain, cookieConfig.Secure, true)
+	c.SetCookie(utils.CSRF_COOKIE_NAME, csrfToken, cookieConfig.MaxAge, "/", cookieConfig.Domain, cookieConfig.Secure, false)
+	return csrfToken, nil
+}
+
+func clearAuthCookies(c *gin.Context) {
+	cookieConfig := config.AuthCookieConfig()
+	c.SetSameSite(cookieConfig.SameSite)
+	c.SetCookie(utils.AUTH_COOKIE_NAME, "", -1, "/", cookieConfig.Domain, cookieConfig.Secure, true)
+	c.SetCookie(utils.CSRF_COOKIE_NAME, "", -1, "/", cookieConfig.Domain, cookieConfig.Secure, false)
+}
+
+// contextWorkspace returns the workspace and membership stored in the request
+// context by middleware.WorkspaceMiddleware. When they are missing an error
+// response is written and ok is false.
+func contextWorkspace(c *gin.Context) (workspace *types.Workspace, workspaceUser *types.WorkspaceUser, ok bool) {
+	ctxWorkspace, _ := c.Get("workspace")
+	ctxWorkspaceUser, _ := c.Get("workspace_user")
+	workspace, _ = ctxWorkspace.(*types.Workspace)
+	workspaceUser, _ = ctxWorkspaceUser.(*types.WorkspaceUser)
+	if workspace == nil || workspaceUser == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Workspace is required"})
+		return nil, nil, false
+	}
+	return workspace, workspaceUser, true
+}
+
+// workspaceParam loads the workspace whose ID is in the named path parameter.
+// When it is invalid or missing an error response is written and ok is false.
+func workspaceParam(c *gin.Context, name string) (workspace *types.Workspace, ok bool) {
+	workspaceId, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid workspace ID"})
+		return nil, false
+	}
+	workspace, _ = models.FetchWorkspace(workspaceId)
+	if workspace == nil || workspace.DeletedAt != nil {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "data": nil, "message": "Workspace not found"})
+		return nil, false
+	}
+	return workspace, true
+}
+
+// memberWorkspaceParam is workspaceParam for routes restricted to members of
+// the workspace. It also returns the current user's membership.
+func memberWorkspaceParam(c *gin.Context, name string) (workspace *types.Workspace, workspaceUser *types.WorkspaceUser, ok bool) {
+	user, ok := contextUser(c)
+	if !ok {
+		return nil, nil, false
+	}
+	workspace, ok = workspaceParam(c, name)
+	if !ok {
+		return nil, nil, false
+	}
+	workspaceUser, _ = models.FetchWorkspaceUser(workspace.ID, user.ID)
+	if workspaceUser == nil {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "You are not a member of this workspace"})
+		return nil, nil, false
+	}
+	return workspace, workspaceUser, true
+}
+
+// ownedWorkspaceParam is workspaceParam for routes restricted to the owners
+// of the workspace.
+func ownedWorkspaceParam(c *gin.Context, name string) (workspace *types.Workspace, ok bool) {
+	user, ok := contextUser(c)
+	if !ok {
+		return nil, false
+	}
+	workspace, ok = workspaceParam(c, name)
+	if !ok {
+		return nil, false
+	}
+	workspaceUser, _ := models.FetchWorkspaceUser(workspace.ID, user.ID)
+	if workspaceUser == nil || !workspaceUser.IsOwner {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Only workspace owners can do this"})
+		return nil, false
+	}
+	if !writableWorkspace(c, workspace) {
+		return nil, false
+	}
+	return workspace, true
+}
+
+// writableWorkspace writes a 403 response when the request would change an
+// archived workspace, which is read-only, and reports whether it may go ahead.
+func writableWorkspace(c *gin.Context, workspace *types.Workspace) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	if workspace.ArchivedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Workspace is archived and read-only"})
+		return false
+	}
+	return true
+}
+
+// quotaExceeded writes a 402 response when err is a plan limit being reached
+// and reports whether it did.
+func quotaExceeded(c *gin.Context, err error) bool {
+	var quotaErr *models.QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+	c.JSON(http.StatusPaymentRequired, gin.H{
+		"status":  "error",
+		"data":    gin.H{"resource": quotaErr.Resource, "limit": quotaErr.Limit},
+		"message": fmt.Sprintf("This workspace has reached its plan limit of %d %s. Upgrade the plan to continue.", quotaErr.Limit, strings.ReplaceAll(quotaErr.Resource, "_", " ")),
+	})
+	return true
+}
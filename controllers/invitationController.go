@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/types"
+	"server/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List invitations
+// @Description List the pending invitations of a workspace. Only owners and admins can do this.
+// @ID list-workspace-invitations
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Success 200 {array} types.WorkspaceInvitation
+// @Failure 403 {object} map[string]string
+// @Router /workspaces/{id}/invitations [get]
+// @Security BearerAuth
+func ListWorkspaceInvitations(c *gin.Context) {
+	workspace, ok := managedWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+
+	invitations, err := models.FetchPendingInvitations(workspace.ID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to fetch invitations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": invitations, "message": "Invitations fetched successfully"})
+}
+
+// @Summary Invite to workspace
+// @Description Email an invitation to join a workspace. The invitee doesn't need an account yet. Only owners and admins can do this.
+// @ID create-workspace-invitation
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Param invitation body types.WorkspaceInvitationPayload true "Invitation"
+// @Success 201 {object} types.WorkspaceInvitation
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 402 {object} map[string]string
+// @Router /workspaces/{id}/invitations [post]
+// @Security BearerAuth
+func CreateWorkspaceInvitation(c *gin.Context) {
+	var payload types.WorkspaceInvitationPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+	if payload.Role == "" {
+		payload.Role = utils.WORKSPACE_ROLE_MEMBER
+	}
+
+	workspace, ok := managedWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+	inviter, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	invitation, err := models.CreateWorkspaceInvitation(workspace, inviter, payload.Email, payload.Role)
+	if err != nil {
+		invitationError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "success", "data": invitation, "message": "Invitation sent successfully"})
+}
+
+// @Summary Resend invitation
+// @Description Send a pending invitation again with a new link and expiry. The previous link stops working. Only owners and admins can do this.
+// @ID resend-workspace-invitation
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Param invitation_id path int true "Invitation ID"
+// @Success 200 {object} types.WorkspaceInvitation
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /workspaces/{id}/invitations/{invitation_id}/resend [post]
+// @Security BearerAuth
+func ResendWorkspaceInvitation(c *gin.Context) {
+	workspace, ok := managedWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+	invitationId, ok := invitationParam(c)
+	if !ok {
+		return
+	}
+	inviter, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	invitation, err := models.ResendWorkspaceInvitation(workspace, inviter, invitationId)
+	if err != nil {
+		invitationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": invitation, "message": "Invitation resent successfully"})
+}
+
+// @Summary Revoke invitation
+// @Description Cancel a pending invitation. Only owners and admins can do this.
+// @ID revoke-workspace-invitation
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Param invitation_id path int true "Invitation ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /workspaces/{id}/invitations/{invitation_id} [delete]
+// @Security BearerAuth
+func RevokeWorkspaceInvitation(c *gin.Context) {
+	workspace, ok := managedWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+	invitationId, ok := invitationParam(c)
+	if !ok {
+		return
+	}
+
+	if err := models.RevokeWorkspaceInvitation(workspace.ID, invitationId); err != nil {
+		invitationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": nil, "message": "Invitation revoked successfully"})
+}
+
+// @Summary Accept invitation
+// @Description Join the workspace of an invitation. The invitation must have been sent to the current user's email address. New users can pass the token as invitation_token when registering instead.
+// @ID accept-workspace-invitation
+// @Accept  json
+// @Produce  json
+// @Param invitation body types.AcceptInvitationPayload true "Invitation token"
+// @Success 200 {object} types.WorkspaceUser
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 402 {object} map[string]string
+// @Router /invitations/accept [post]
+// @Security BearerAuth
+func AcceptWorkspaceInvitation(c *gin.Context) {
+	var payload types.AcceptInvitationPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	workspaceUser, err := models.AcceptWorkspaceInvitation(payload.Token, data)
+	if err != nil {
+		invitationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": workspaceUser, "message": "Invitation accepted successfully"})
+}
+
+// acceptSignupInvitation accepts the invitation a new user signed up with.
+// Registration goes ahead even when the invitation can't be used.
+func acceptSignupInvitation(token string, user *types.User) {
+	if token == "" {
+		return
+	}
+	if _, err := models.AcceptWorkspaceInvitation(token, user); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func invitationParam(c *gin.Context) (int, bool) {
+	invitationId, err := strconv.Atoi(c.Param("invitation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid invitation ID"})
+		return 0, false
+	}
+	return invitationId, true
+}
+
+func invitationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidInvitation):
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "data": nil, "message": "Invitation not found or no longer valid"})
+	case errors.Is(err, models.ErrInvitationEmailMismatch):
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "This invitation was sent to a different email address"})
+	case errors.Is(err, models.ErrWorkspaceArchived):
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Workspace is archived and read-only"})
+	case errors.Is(err, models.ErrInvitationPending):
+		c.JSON(http.StatusConflict, gin.H{"status": "error", "data": nil, "message": "An invitation for this email is already pending. Resend it instead."})
+	default:
+		workspaceMemberError(c, err)
+	}
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"server/types"
+	"server/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listQueryParam parses the query string of a list endpoint. When it doesn't
+// fit the spec an error response is written and ok is false.
+func listQueryParam(c *gin.Context, spec types.ListQuerySpec) (query types.ListQuery, ok bool) {
+	query, err := parseListQuery(c.Request.URL.Query(), spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": err.Error()})
+		return types.ListQuery{}, false
+	}
+	return query, true
+}
+
+// parseListQuery reads the grammar every list endpoint shares:
+//
+//	field=value            equal to value
+//	field_from=value       greater than or equal to value
+//	field_to=value         less than value
+//	sort=-field,other      sort descending on field, then ascending on other
+//	limit=n                page size
+//	cursor=...             next_cursor of the previous page
+//
+// Parameters the spec doesn't know are ignored.
+func parseListQuery(values url.Values, spec types.ListQuerySpec) (types.ListQuery, error) {
+	query := types.ListQuery{
+		Sort:   spec.DefaultSort,
+		Limit:  spec.DefaultLimit,
+		Cursor: values.Get("cursor"),
+	}
+
+	for param := range values {
+		name, op := param, utils.LIST_OP_EQ
+		if field, found := strings.CutSuffix(param, utils.LIST_RANGE_FROM_SUFFIX); found && spec.Fields[field].Filter {
+			name, op = field, utils.LIST_OP_GTE
+		} else if field, found := strings.CutSuffix(param, utils.LIST_RANGE_TO_SUFFIX); found && spec.Fields[field].Filter {
+			name, op = field, utils.LIST_OP_LT
+		}
+		field, known := spec.Fields[name]
+		if !known || !field.Filter {
+			continue
+		}
+		value, err := parseListValue(field.Type, values.Get(param))
+		if err != nil {
+			return types.ListQuery{}, fmt.Errorf("invalid value for %s: %w", param, err)
+		}
+		query.Filters = append(query.Filters, types.ListFilter{Field: name, Op: op, Value: value})
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		query.Sort = nil
+		for _, part := range strings.Split(sort, ",") {
+			name, desc := strings.CutPrefix(strings.TrimSpace(part), "-")
+			if !spec.Fields[name].Sort {
+				return types.ListQuery{}, fmt.Errorf("cannot sort by %q", name)
+			}
+			query.Sort = append(query.Sort, types.ListSort{Field: name, Desc: desc})
+		}
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > spec.MaxLimit {
+			return types.ListQuery{}, fmt.Errorf("limit must be between 1 and %d", spec.MaxLimit)
+		}
+		query.Limit = n
+	}
+	return query, nil
+}
+
+// parseListValue converts a query parameter to the type of its field.
+func parseListValue(fieldType, raw string) (interface{}, error) {
+	switch fieldType {
+	case utils.LIST_FIELD_INT:
+		return strconv.Atoi(raw)
+	case utils.LIST_FIELD_BOOL:
+		return strconv.ParseBool(raw)
+	case utils.LIST_FIELD_TIME:
+		return time.Parse(time.RFC3339, raw)
+	default:
+		return raw, nil
+	}
+}
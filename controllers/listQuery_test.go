@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"net/url"
+	"reflect"
+	"server/types"
+	"server/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testListSpec = types.ListQuerySpec{
+	Fields: map[string]types.ListField{
+		"id":       {Column: "id", Type: utils.LIST_FIELD_INT, Sort: true},
+		"stage":    {Column: "stage", Type: utils.LIST_FIELD_STRING, Filter: true, Sort: true},
+		"status":   {Column: "status", Type: utils.LIST_FIELD_BOOL, Filter: true},
+		"datetime": {Column: "datetime", Type: utils.LIST_FIELD_TIME, Filter: true, Sort: true},
+		"owner":    {Column: "owner", Type: utils.LIST_FIELD_INT, Filter: true},
+		// Sortable but not filterable, so owner_id_from is not a range.
+		"owner_id": {Column: "owner_id", Type: utils.LIST_FIELD_INT, Sort: true},
+	},
+	DefaultSort:  []types.ListSort{{Field: "datetime", Desc: true}},
+	DefaultLimit: 25,
+	MaxLimit:     100,
+}
+
+func TestParseListQuery(t *testing.T) {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 4, 1, 0, 0, 0, 0, time.FixedZone("", 2*60*60))
+
+	tests := []struct {
+		name    string
+		query   string
+		filters []types.ListFilter
+		sort    []types.ListSort
+		limit   int
+		cursor  string
+		wantErr string
+	}{
+		{
+			name:  "defaults",
+			sort:  testListSpec.DefaultSort,
+			limit: 25,
+		},
+		{
+			name:    "equality filters",
+			query:   "stage=review&status=true&owner=7",
+			filters: []types.ListFilter{{Field: "owner", Op: utils.LIST_OP_EQ, Value: 7}, {Field: "stage", Op: utils.LIST_OP_EQ, Value: "review"}, {Field: "status", Op: utils.LIST_OP_EQ, Value: true}},
+			sort:    testListSpec.DefaultSort,
+			limit:   25,
+		},
+		{
+			name:  "range suffixes",
+			query: "datetime_from=2026-03-01T09:00:00Z&datetime_to=2026-04-01T00:00:00%2B02:00",
+			filters: []types.ListFilter{
+				{Field: "datetime", Op: utils.LIST_OP_GTE, Value: start},
+				{Field: "datetime", Op: utils.LIST_OP_LT, Value: end},
+			},
+			sort:  testListSpec.DefaultSort,
+			limit: 25,
+		},
+		{
+			name:  "unknown and unfilterable fields are ignored",
+			query: "color=red&id=3&owner_id_from=2&stage_since=x&page=2",
+			sort:  testListSpec.DefaultSort,
+			limit: 25,
+		},
+		{
+			name:    "invalid filter value",
+			query:   "owner=abc",
+			wantErr: "invalid value for owner",
+		},
+		{
+			name:    "invalid range value",
+			query:   "datetime_from=yesterday",
+			wantErr: "invalid value for datetime_from",
+		},
+		{
+			name:  "spaces around sort fields",
+			query: "sort=-datetime,%20stage%20",
+			sort:  []types.ListSort{{Field: "datetime", Desc: true}, {Field: "stage"}},
+			limit: 25,
+		},
+		{
+			name:  "sort",
+			query: "sort=-datetime,stage,-id",
+			sort:  []types.ListSort{{Field: "datetime", Desc: true}, {Field: "stage"}, {Field: "id", Desc: true}},
+			limit: 25,
+		},
+		{
+			name:    "unknown sort field",
+			query:   "sort=color",
+			wantErr: `cannot sort by "color"`,
+		},
+		{
+			name:    "unsortable field",
+			query:   "sort=-status",
+			wantErr: `cannot sort by "status"`,
+		},
+		{
+			name:    "empty sort field",
+			query:   "sort=stage,",
+			wantErr: `cannot sort by ""`,
+		},
+		{
+			name:  "minimum limit",
+			query: "limit=1",
+			sort:  testListSpec.DefaultSort,
+			limit: 1,
+		},
+		{
+			name:  "maximum limit",
+			query: "limit=100",
+			sort:  testListSpec.DefaultSort,
+			limit: 100,
+		},
+		{
+			name:    "limit below range",
+			query:   "limit=0",
+			wantErr: "limit must be between 1 and 100",
+		},
+		{
+			name:    "limit above range",
+			query:   "limit=101",
+			wantErr: "limit must be between 1 and 100",
+		},
+		{
+			name:    "limit not a number",
+			query:   "limit=ten",
+			wantErr: "limit must be between 1 and 100",
+		},
+		{
+			name:   "cursor is passed through",
+			query:  "cursor=abc",
+			sort:   testListSpec.DefaultSort,
+			limit:  25,
+			cursor: "abc",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := url.ParseQuery(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := parseListQuery(values, testListSpec)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Filters come from a map, so their order is not defined.
+			if len(query.Filters) != len(test.filters) {
+				t.Fatalf("filters = %+v, want %+v", query.Filters, test.filters)
+			}
+			for _, want := range test.filters {
+				got, ok := query.Filter(want.Field, want.Op)
+				if !ok {
+					t.Errorf("missing filter %s %s", want.Field, want.Op)
+					continue
+				}
+				if wantTime, isTime := want.Value.(time.Time); isTime {
+					if gotTime, _ := got.(time.Time); !gotTime.Equal(wantTime) {
+						t.Errorf("filter %s %s = %v, want %v", want.Field, want.Op, got, want.Value)
+					}
+				} else if got != want.Value {
+					t.Errorf("filter %s %s = %#v, want %#v", want.Field, want.Op, got, want.Value)
+				}
+			}
+			if !reflect.DeepEqual(query.Sort, test.sort) {
+				t.Errorf("sort = %+v, want %+v", query.Sort, test.sort)
+			}
+			if query.Limit != test.limit {
+				t.Errorf("limit = %d, want %d", query.Limit, test.limit)
+			}
+			if query.Cursor != test.cursor {
+				t.Errorf("cursor = %q, want %q", query.Cursor, test.cursor)
+			}
+		})
+	}
+}
@@ -0,0 +1,420 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"server/config"
+	"server/models"
+	"server/types"
+	"server/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary OpenID Connect discovery
+// @Description OpenID Provider configuration document
+// @ID oidc-discovery
+// @Produce  json
+// @Success 200 {object} types.OIDCDiscoveryResponse
+// @Router /.well-known/openid-configuration [get]
+func OIDCDiscovery(c *gin.Context) {
+	issuer := models.OIDCIssuer()
+	c.JSON(http.StatusOK, types.OIDCDiscoveryResponse{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserinfoEndpoint:                  issuer + "/oauth/userinfo",
+		JwksURI:                           issuer + "/oauth/jwks",
+		ScopesSupported:                   utils.OAUTH_SUPPORTED_SCOPES,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{utils.OAUTH_GRANT_AUTHORIZATION_CODE, utils.OAUTH_GRANT_REFRESH_TOKEN},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  []string{"RS256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{utils.OAUTH_PKCE_METHOD_S256, utils.OAUTH_PKCE_METHOD_PLAIN},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "email", "picture"},
+	})
+}
+
+// @Summary JSON Web Key Set
+// @Description Public keys used to verify ID and access tokens
+// @ID oidc-jwks
+// @Produce  json
+// @Success 200 {object} types.JSONWebKeySet
+// @Router /oauth/jwks [get]
+func OIDCJWKS(c *gin.Context) {
+	jwks, err := models.FetchJWKS()
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to load signing keys"})
+		return
+	}
+	c.JSON(http.StatusOK, jwks)
+}
+
+// @Summary Register OAuth client
+// @Description Register an application that signs users in through this server. The client secret is only returned once.
+// @ID oauth-register-client
+// @Accept  json
+// @Produce  json
+// @Param client body types.OAuthClientPayload true "Client info"
+// @Success 200 {object} types.OAuthClientResponse
+// @Failure 400 {object} map[string]string
+// @Router /oauth/clients [post]
+// @Security BearerAuth
+func RegisterOAuthClient(c *gin.Context) {
+	var payload types.OAuthClientPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+
+	user, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	client, secret, err := models.RegisterOAuthClient(payload, user.ID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to register client"})
+		return
+	}
+
+	response := oauthClientResponse(*client)
+	response.ClientSecret = secret
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": response, "message": "Client registered successfully."})
+}
+
+// @Summary List OAuth clients
+// @Description List the OAuth clients registered by the current user
+// @ID oauth-list-clients
+// @Produce  json
+// @Success 200 {array} types.OAuthClientResponse
+// @Router /oauth/clients [get]
+// @Security BearerAuth
+func ListOAuthClients(c *gin.Context) {
+	user, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	clients, err := models.FetchOAuthClientsByUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to fetch clients"})
+		return
+	}
+
+	response := make([]types.OAuthClientResponse, len(clients))
+	for i, client := range clients {
+		response[i] = oauthClientResponse(client)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": response, "message": "Clients fetched successfully"})
+}
+
+// @Summary Authorize
+// @Description Authorization endpoint for browsers. Users signed in through the auth cookie who already consented are redirected back to the client with a code. Everyone else is redirected to the consent page of the frontend at APP_URL/oauth/consent with the same query, which signs them in and asks for consent. With prompt=none the client gets login_required or consent_required instead.
+// @ID oauth-authorize
+// @Param response_type query string true "Must be code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string true "Registered redirect URI"
+// @Param scope query string true "Space separated scopes, must include openid"
+// @Param state query string false "Opaque client state"
+// @Param nonce query string false "ID token nonce"
+// @Param prompt query string false "consent to always show the consent screen, none to never show it"
+// @Param code_challenge query string false "PKCE code challenge"
+// @Param code_challenge_method query string false "S256 or plain"
+// @Success 302
+// @Failure 400 {object} map[string]string
+// @Router /oauth/authorize [get]
+func Authorize(c *gin.Context) {
+	var payload types.OAuthAuthorizePayload
+	if err := c.ShouldBindQuery(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+	}
+
+	client, scopes, ok := validateAuthorizeRequest(c, payload, true)
+	if !ok {
+		return
+	}
+
+	// Set by OptionalAuthMiddleware when the browser sent a valid session.
+	ctxUserData, _ := c.Get("user")
+	user, _ := ctxUserData.(*types.User)
+	if user != nil && payload.Prompt != "consent" && models.HasOAuthConsent(user.ID, client.ClientId, scopes) {
+		issueAuthorizationCode(c, payload, user.ID, scopes, true)
+		return
+	}
+	if payload.Prompt == "none" {
+		code := "consent_required"
+		if user == nil {
+			code = "login_required"
+		}
+		c.Redirect(http.StatusFound, authorizeRedirect(payload.RedirectURI, payload.State, url.Values{"error": {code}}))
+		return
+	}
+	c.Redirect(http.StatusFound, utils.AppURL()+"/oauth/consent?"+c.Request.URL.RawQuery)
+}
+
+// @Summary Authorization request details
+// @Description Details the consent page shows for an authorization request: the client's name, the scopes it will get and whether the user has to approve them.
+// @ID oauth-authorize-details
+// @Produce  json
+// @Param response_type query string true "Must be code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string true "Registered redirect URI"
+// @Param scope query string true "Space separated scopes, must include openid"
+// @Param prompt query string false "Set to consent to always ask for consent"
+// @Param code_challenge query string false "PKCE code challenge"
+// @Param code_challenge_method query string false "S256 or plain"
+// @Success 200 {object} types.OAuthAuthorizeResponse
+// @Failure 400 {object} map[string]string
+// @Router /oauth/authorize/details [get]
+// @Security BearerAuth
+func AuthorizeDetails(c *gin.Context) {
+	var payload types.OAuthAuthorizePayload
+	if err := c.ShouldBindQuery(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+	}
+
+	user, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	client, scopes, ok := validateAuthorizeRequest(c, payload, false)
+	if !ok {
+		return
+	}
+
+	response := types.OAuthAuthorizeResponse{
+		ConsentRequired: payload.Prompt == "consent" || !models.HasOAuthConsent(user.ID, client.ClientId, scopes),
+		ClientName:      client.Name,
+		Scopes:          scopes,
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": response, "message": "Authorization request fetched successfully"})
+}
+
+// @Summary Authorize consent
+// @Description Approve or deny an authorization request for the signed-in user
+// @ID oauth-authorize-consent
+// @Accept  json
+// @Produce  json
+// @Param consent body types.OAuthConsentPayload true "Authorization request and decision"
+// @Success 200 {object} types.OAuthAuthorizeResponse
+// @Failure 400 {object} map[string]string
+// @Router /oauth/authorize [post]
+// @Security BearerAuth
+func AuthorizeConsent(c *gin.Context) {
+	var payload types.OAuthConsentPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+	}
+
+	user, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	client, scopes, ok := validateAuthorizeRequest(c, payload.OAuthAuthorizePayload, false)
+	if !ok {
+		return
+	}
+
+	if !payload.Approve {
+		authorizeResult(c, authorizeRedirect(payload.RedirectURI, payload.State, url.Values{"error": {"access_denied"}}), "Authorization denied", false)
+		return
+	}
+
+	if err := models.SaveOAuthConsent(user.ID, client.ClientId, scopes); err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to save consent"})
+		return
+	}
+	issueAuthorizationCode(c, payload.OAuthAuthorizePayload, user.ID, scopes, false)
+}
+
+// @Summary Token
+// @Description Exchange an authorization code or refresh token for tokens
+// @ID oauth-token
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param grant_type formData string true "authorization_code or refresh_token"
+// @Param code formData string false "Authorization code"
+// @Param redirect_uri formData string false "Redirect URI used in the authorization request"
+// @Param code_verifier formData string false "PKCE code verifier"
+// @Param refresh_token formData string false "Refresh token"
+// @Param scope formData string false "Narrower scope for refresh"
+// @Param client_id formData string false "Client ID when not using HTTP Basic auth"
+// @Param client_secret formData string false "Client secret when not using HTTP Basic auth"
+// @Success 200 {object} types.OAuthTokenResponse
+// @Failure 400 {object} types.OAuthErrorResponse
+// @Failure 401 {object} types.OAuthErrorResponse
+// @Router /oauth/token [post]
+func Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var payload types.OAuthTokenPayload
+	if err := c.ShouldBind(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, types.OAuthErrorResponse{Error: "invalid_request", ErrorDescription: "grant_type is required"})
+		return
+	}
+
+	clientId, clientSecret, hasBasicAuth := c.Request.BasicAuth()
+	if !hasBasicAuth {
+		clientId, clientSecret = payload.ClientId, payload.ClientSecret
+	}
+	client, err := models.AuthenticateOAuthClient(clientId, clientSecret)
+	if err != nil {
+		if hasBasicAuth {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		oauthErrorResponse(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var response *types.OAuthTokenResponse
+	switch payload.GrantType {
+	case utils.OAUTH_GRANT_AUTHORIZATION_CODE:
+		response, err = models.ExchangeAuthorizationCode(client, payload.Code, payload.RedirectURI, payload.CodeVerifier)
+	case utils.OAUTH_GRANT_REFRESH_TOKEN:
+		response, err = models.RefreshOAuthToken(client, payload.RefreshToken, payload.Scope)
+	default:
+		c.JSON(http.StatusBadRequest, types.OAuthErrorResponse{Error: "unsupported_grant_type"})
+		return
+	}
+	if err != nil {
+		oauthErrorResponse(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary User info
+// @Description Claims about the user an OAuth access token was issued for
+// @ID oauth-userinfo
+// @Produce  json
+// @Success 200 {object} types.OIDCUserInfo
+// @Failure 401 {object} types.OAuthErrorResponse
+// @Router /oauth/userinfo [get]
+// @Security BearerAuth
+func UserInfo(c *gin.Context) {
+	tokenString := strings.TrimSpace(strings.Replace(c.GetHeader("Authorization"), "Bearer", "", 1))
+	if tokenString == "" {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_request"`)
+		c.JSON(http.StatusUnauthorized, types.OAuthErrorResponse{Error: "invalid_request", ErrorDescription: "Authorization header is missing"})
+		return
+	}
+
+	user, scopes, err := models.VerifyOAuthAccessToken(tokenString)
+	if err != nil {
+		fmt.Println(err)
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, types.OAuthErrorResponse{Error: "invalid_token", ErrorDescription: "Invalid token"})
+		return
+	}
+	c.JSON(http.StatusOK, models.OIDCUserInfoForScopes(user, scopes))
+}
+
+// validateAuthorizeRequest validates the request and writes the error response
+// when it is invalid. Errors for unknown clients or redirect URIs are returned
+// directly, every other error is sent back to the client's redirect URI, by
+// redirecting the browser or, for the consent page, in the response.
+func validateAuthorizeRequest(c *gin.Context, payload types.OAuthAuthorizePayload, browser bool) (*types.OAuthClient, []string, bool) {
+	client, scopes, err := models.ValidateAuthorizeRequest(payload)
+	if err == nil {
+		return client, scopes, true
+	}
+
+	var oauthErr *models.OAuthError
+	if !errors.As(err, &oauthErr) || client == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": err.Error()})
+		return nil, nil, false
+	}
+
+	authorizeResult(c, authorizeRedirect(payload.RedirectURI, payload.State, url.Values{
+		"error":             {oauthErr.Code},
+		"error_description": {oauthErr.Description},
+	}), "Authorization request rejected", browser)
+	return nil, nil, false
+}
+
+func issueAuthorizationCode(c *gin.Context, payload types.OAuthAuthorizePayload, userId int, scopes []string, browser bool) {
+	code, err := models.CreateAuthorizationCode(payload, userId, scopes)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to issue authorization code"})
+		return
+	}
+
+	authorizeResult(c, authorizeRedirect(payload.RedirectURI, payload.State, url.Values{"code": {code}}), "Authorization granted", browser)
+}
+
+// authorizeResult sends the browser back to the client. Requests from the
+// consent page get the URL to redirect to in the response instead.
+func authorizeResult(c *gin.Context, redirectTo, message string, browser bool) {
+	if browser {
+		c.Redirect(http.StatusFound, redirectTo)
+		return
+	}
+	response := types.OAuthAuthorizeResponse{RedirectTo: redirectTo}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": response, "message": message})
+}
+
+func authorizeRedirect(redirectURI, state string, params url.Values) string {
+	redirect, err := url.Parse(redirectURI)
+	if err != nil {
+		return ""
+	}
+	query := redirect.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	redirect.RawQuery = query.Encode()
+	return redirect.String()
+}
+
+func oauthErrorResponse(c *gin.Context, status int, err error) {
+	var oauthErr *models.OAuthError
+	if errors.As(err, &oauthErr) {
+		c.JSON(status, types.OAuthErrorResponse{Error: oauthErr.Code, ErrorDescription: oauthErr.Description})
+		return
+	}
+	fmt.Println(err)
+	c.JSON(http.StatusInternalServerError, types.OAuthErrorResponse{Error: "server_error"})
+}
+
+func oauthClientResponse(client types.OAuthClient) types.OAuthClientResponse {
+	return types.OAuthClientResponse{
+		ClientId:     client.ClientId,
+		Name:         client.Name,
+		RedirectURIs: strings.Fields(client.RedirectURIs),
+		Scopes:       strings.Fields(client.Scopes),
+		IsPublic:     client.IsPublic,
+		CreatedAt:    client.CreatedAt,
+	}
+}
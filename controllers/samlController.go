@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"server/config"
+	"server/models"
+	"server/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary SAML SP metadata
+// @Description Service provider metadata to upload to the workspace's identity provider
+// @ID saml-metadata
+// @Produce  xml
+// @Param workspace_id path int true "Workspace ID"
+// @Success 200 {string} string
+// @Failure 404 {object} map[string]string
+// @Router /saml/{workspace_id}/metadata [get]
+func SAMLMetadata(c *gin.Context) {
+	workspace, ok := workspaceParam(c, "workspace_id")
+	if !ok {
+		return
+	}
+
+	metadata, err := models.BuildSAMLServiceProviderMetadata(workspace.ID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to build metadata"})
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// @Summary SAML login
+// @Description Redirect to the workspace's identity provider to start a service provider initiated login
+// @ID saml-login
+// @Param workspace_id path int true "Workspace ID"
+// @Param relay_state query string false "Value returned as RelayState to the ACS endpoint"
+// @Success 302
+// @Failure 400 {object} map[string]string
+// @Router /saml/{workspace_id}/login [get]
+func SAMLLogin(c *gin.Context) {
+	workspace, ok := workspaceParam(c, "workspace_id")
+	if !ok {
+		return
+	}
+
+	redirectURL, err := models.BuildSAMLAuthnRequestURL(workspace, c.Query("relay_state"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// @Summary SAML assertion consumer service
+// @Description Validate a signed SAML response to a login started at /saml/{workspace_id}/login, provision the user into the workspace and sign them in. Existing accounts must already be members of the workspace.
+// @ID saml-acs
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param workspace_id path int true "Workspace ID"
+// @Param SAMLResponse formData string true "Base64 encoded SAML response"
+// @Param RelayState formData string false "Relay state"
+// @Success 200 {object} types.AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 402 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /saml/{workspace_id}/acs [post]
+func SAMLAssertionConsumer(c *gin.Context) {
+	workspace, ok := workspaceParam(c, "workspace_id")
+	if !ok {
+		return
+	}
+
+	samlResponse := c.PostForm("SAMLResponse")
+	if samlResponse == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "SAMLResponse is missing"})
+		return
+	}
+
+	assertion, err := models.ValidateSAMLResponse(workspace, samlResponse)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "data": nil, "message": "Invalid SAML response"})
+		return
+	}
+
+	email, name, err := models.SAMLAssertionIdentity(assertion)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "data": nil, "message": "Invalid SAML response"})
+		return
+	}
+
+	user, err := models.ProvisionSAMLUser(workspace.ID, email, name)
+	if quotaExceeded(c, err) {
+		return
+	}
+	if errors.Is(err, models.ErrSAMLAccountNotLinked) {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "An account with this email already exists. Ask a workspace admin to invite it before signing in with SSO."})
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to sign in user"})
+		return
+	}
+
+	if user.SuspendedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "User account is suspended"})
+		return
+	}
+
+	tokenString, tokenError := createLoginToken(*user)
+	if tokenError != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to sign in user"})
+		return
+	}
+
+	// Browsers post the response here directly, so hand the session to the
+	// frontend when a redirect is configured: as cookies in cookie mode,
+	// otherwise in the URL fragment.
+	if redirectURL := os.Getenv("SAML_LOGIN_REDIRECT_URL"); redirectURL != "" {
+		if config.AuthCookieConfig().Enabled {
+			if _, err := setAuthCookies(c, tokenString); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to create session"})
+				return
+			}
+			c.Redirect(http.StatusSeeOther, redirectURL)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, redirectURL+"#token="+tokenString)
+		return
+	}
+
+	sendAuthResponse(c, tokenString, "User login successful.")
+}
+
+// @Summary Get SAML configuration
+// @Description Get the SAML SSO configuration of a workspace. Only workspace owners can view it.
+// @ID saml-get-config
+// @Produce  json
+// @Param workspace_id path int true "Workspace ID"
+// @Success 200 {object} types.SAMLConfigResponse
+// @Failure 403 {object} map[string]string
+// @Router /saml/{workspace_id}/idp [get]
+// @Security BearerAuth
+func GetSAMLConfig(c *gin.Context) {
+	workspace, ok := ownedWorkspaceParam(c, "workspace_id")
+	if !ok {
+		return
+	}
+
+	response := types.SAMLConfigResponse{
+		WorkspaceId: workspace.ID,
+		Enabled:     workspace.SamlEnabled,
+		SPEntityId:  models.SAMLServiceProviderEntityID(workspace.ID),
+		SPACSURL:    models.SAMLServiceProviderACSURL(workspace.ID),
+	}
+	if workspace.SamlIdpMetadata != "" {
+		if idp, err := models.ParseSAMLIdPMetadata(workspace.SamlIdpMetadata); err == nil {
+			response.IdPEntityId = idp.EntityID
+			response.IdPSSOURL = idp.SSOURL
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": response, "message": "SAML configuration fetched successfully"})
+}
+
+// @Summary Update SAML configuration
+// @Description Store the identity provider metadata of a workspace and enable or disable SAML SSO. Only workspace owners can change it.
+// @ID saml-update-config
+// @Accept  json
+// @Produce  json
+// @Param workspace_id path int true "Workspace ID"
+// @Param config body types.SAMLIdPPayload true "IdP metadata"
+// @Success 200 {object} types.SAMLConfigResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /saml/{workspace_id}/idp [put]
+// @Security BearerAuth
+func UpdateSAMLConfig(c *gin.Context) {
+	var payload types.SAMLIdPPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+	}
+
+	workspace, ok := ownedWorkspaceParam(c, "workspace_id")
+	if !ok {
+		return
+	}
+
+	idp, err := models.UpdateWorkspaceSAMLConfig(workspace, payload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": err.Error()})
+		return
+	}
+
+	response := types.SAMLConfigResponse{
+		WorkspaceId: workspace.ID,
+		Enabled:     payload.Enabled,
+		IdPEntityId: idp.EntityID,
+		IdPSSOURL:   idp.SSOURL,
+		SPEntityId:  models.SAMLServiceProviderEntityID(workspace.ID),
+		SPACSURL:    models.SAMLServiceProviderACSURL(workspace.ID),
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": response, "message": "SAML configuration updated successfully"})
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/types"
+	"server/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List session attachments
+// @Description List the attachments of a session with a download link valid for 15 minutes.
+// @ID list-session-attachments
+// @Produce  json
+// @Param X-Workspace-ID header int false "Workspace ID, defaults to the workspace of the token"
+// @Param id path int true "Session ID"
+// @Param category query string false "Only list this category" Enums(1, 2, 3, 4)
+// @Success 200 {array} types.SessionAttachmentResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /sessions/{id}/attachments [get]
+// @Security BearerAuth
+func ListSessionAttachments(c *gin.Context) {
+	var query types.SessionAttachmentQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid query"})
+		return
+	}
+
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	sessionId, ok := sessionParam(c)
+	if !ok {
+		return
+	}
+
+	attachments, err := models.FetchSessionAttachments(c.Request.Context(), sessionId, data.ID, query.Category)
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": attachments, "message": "Attachments fetched successfully"})
+}
+
+// @Summary Upload session attachments
+// @Description Upload up to 10 files of at most 25 MB each to a session. Their size counts against the workspace's storage. The session's creator and collaborators can do this, unless they are viewers of the workspace.
+// @ID upload-session-attachments
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param X-Workspace-ID header int false "Workspace ID, defaults to the workspace of the token"
+// @Param id path int true "Session ID"
+// @Param category formData string true "Attachment category" Enums(1, 2, 3, 4)
+// @Param files formData file true "Files to attach"
+// @Success 201 {array} types.SessionAttachmentResponse
+// @Failure 400 {object} map[string]string
+// @Failure 402 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Router /sessions/{id}/attachments [post]
+// @Security BearerAuth
+func UploadSessionAttachments(c *gin.Context) {
+	// Leave room for the multipart boundaries and the other fields.
+	maxBody := int64(utils.SESSION_ATTACHMENT_MAX_FILES)*utils.SESSION_ATTACHMENT_MAX_BYTES + 1<<20
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBody)
+
+	var payload types.SessionAttachmentsPayload
+	if err := c.ShouldBind(&payload); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"status": "error", "data": nil, "message": "Upload is too large"})
+			return
+		}
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	sessionId, ok := sessionParam(c)
+	if !ok {
+		return
+	}
+	if !sessionEditor(c) {
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["files"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "At least one file is required"})
+		return
+	}
+	files := form.File["files"]
+	if len(files) > utils.SESSION_ATTACHMENT_MAX_FILES {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": fmt.Sprintf("At most %d files can be uploaded at once", utils.SESSION_ATTACHMENT_MAX_FILES)})
+		return
+	}
+	for _, file := range files {
+		if file.Size > utils.SESSION_ATTACHMENT_MAX_BYTES {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"status": "error", "data": nil, "message": fmt.Sprintf("%s is larger than %d MB", file.Filename, utils.SESSION_ATTACHMENT_MAX_BYTES>>20)})
+			return
+		}
+	}
+
+	attachments, err := models.AddSessionAttachments(c.Request.Context(), sessionId, data.ID, payload.Category, files)
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "success", "data": attachments, "message": "Attachments uploaded successfully"})
+}
+
+// @Summary Delete session attachment
+// @Description Delete an attachment and its file. The uploader and the creator of the session can do this, unless they are viewers of the workspace.
+// @ID delete-session-attachment
+// @Produce  json
+// @Param X-Workspace-ID header int false "Workspace ID, defaults to the workspace of the token"
+// @Param id path int true "Session ID"
+// @Param attachment_id path int true "Attachment ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /sessions/{id}/attachments/{attachment_id} [delete]
+// @Security BearerAuth
+func DeleteSessionAttachment(c *gin.Context) {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	sessionId, ok := sessionParam(c)
+	if !ok {
+		return
+	}
+	attachmentId, err := strconv.Atoi(c.Param("attachment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid attachment ID"})
+		return
+	}
+	if !sessionEditor(c) {
+		return
+	}
+
+	if err := models.DeleteSessionAttachment(c.Request.Context(), sessionId, data.ID, attachmentId); err != nil {
+		sessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": nil, "message": "Attachment deleted successfully"})
+}
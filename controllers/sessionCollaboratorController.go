@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List session collaborators
+// @Description List the collaborators of a session with their name, email and avatar.
+// @ID list-session-collaborators
+// @Produce  json
+// @Param X-Workspace-ID header int false "Workspace ID, defaults to the workspace of the token"
+// @Param id path int true "Session ID"
+// @Success 200 {array} types.ProcessedSessionCollaborator
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /sessions/{id}/collaborators [get]
+// @Security BearerAuth
+func ListSessionCollaborators(c *gin.Context) {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	sessionId, ok := sessionParam(c)
+	if !ok {
+		return
+	}
+
+	collaborators, err := models.FetchSessionCollaborators(c.Request.Context(), sessionId, data.ID)
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": collaborators, "message": "Collaborators fetched successfully"})
+}
+
+// @Summary Add session collaborator
+// @Description Add a member of the workspace to a session by user ID or email. The session's creator and collaborators can do this, unless they are viewers of the workspace. Answers 409 with the conflicts when the member already has an active session at that time, unless allow_conflicts is set.
+// @ID add-session-collaborator
+// @Accept  json
+// @Produce  json
+// @Param X-Workspace-ID header int false "Workspace ID, defaults to the workspace of the token"
+// @Param id path int true "Session ID"
+// @Param collaborator body types.AddSessionCollaboratorPayload true "User ID or email"
+// @Success 201 {object} types.ProcessedSessionCollaborator
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /sessions/{id}/collaborators [post]
+// @Security BearerAuth
+func AddSessionCollaborator(c *gin.Context) {
+	var payload types.AddSessionCollaboratorPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	sessionId, ok := sessionParam(c)
+	if !ok {
+		return
+	}
+	if !sessionEditor(c) {
+		return
+	}
+
+	collaborator, err := models.AddSessionCollaborator(c.Request.Context(), sessionId, data.ID, payload)
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "success", "data": collaborator, "message": "Collaborator added successfully"})
+}
+
+// @Summary Remove session collaborator
+// @Description Remove a collaborator from a session. The session's creator can remove anyone; collaborators can remove themselves.
+// @ID remove-session-collaborator
+// @Produce  json
+// @Param X-Workspace-ID header int false "Workspace ID, defaults to the workspace of the token"
+// @Param id path int true "Session ID"
+// @Param user_id path int true "User ID of the collaborator"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /sessions/{id}/collaborators/{user_id} [delete]
+// @Security BearerAuth
+func RemoveSessionCollaborator(c *gin.Context) {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	sessionId, ok := sessionParam(c)
+	if !ok {
+		return
+	}
+	collaboratorId, ok := memberUserParam(c)
+	if !ok {
+		return
+	}
+	// Anyone may leave a session, viewers included.
+	if collaboratorId != data.ID && !sessionEditor(c) {
+		return
+	}
+
+	if err := models.RemoveSessionCollaborator(c.Request.Context(), sessionId, data.ID, collaboratorId); err != nil {
+		sessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": nil, "message": "Collaborator removed successfully"})
+}
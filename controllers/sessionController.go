@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/types"
+	"server/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Create session
+// @Description Create a session in the active workspace. The current user becomes its creator. datetime is RFC 3339 with an offset, duration_minutes its length and timezone the IANA zone it is shown in, defaulting to the creator's. Collaborators, given by user ID or email, must be members of the workspace; if any can't be added the session isn't created. If the creator or a collaborator is already in an overlapping session the request fails with 409 and the conflicts, unless allow_conflicts is set. Viewers can't create sessions.
+// @ID create-session
+// @Accept  json
+// @Produce  json
+// @Param X-Workspace-ID header int false "Workspace ID, defaults to the workspace of the token"
+// @Param session body types.SessionPayload true "Session"
+// @Success 201 {object} types.ProcessedSessionResponse
+// @Failure 400 {object} map[string]string
+// @Failure 402 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /sessions [post]
+// @Security BearerAuth
+func CreateSession(c *gin.Context) {
+	var payload types.SessionPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	if !sessionEditor(c) {
+		return
+	}
+
+	session, err := models.CreateSession(c.Request.Context(), data.ID, payload)
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "success", "data": session, "message": "Session created successfully"})
+}
+
+// @Summary List sessions
+// @Description List the sessions of the active workspace that the current user created or collaborates on. Filter with stage, status, created_by, collaborator (a user ID) and datetime_from/datetime_to or created_at_from/created_at_to; sort with sort=-datetime,stage on datetime, stage, created_by, created_at or id. Pass next_cursor back as cursor for the next page.
+// @ID list-sessions
+// @Produce  json
+// @Param X-Workspace-ID header int false "Workspace ID, defaults to the workspace of the token"
+// @Param stage query string false "Stage"
+// @Param status query bool false "Status"
+// @Param created_by query int false "Creator user ID"
+// @Param collaborator query int false "Collaborator user ID"
+// @Param datetime_from query string false "Earliest start, inclusive, RFC 3339"
+// @Param datetime_to query string false "Latest start, exclusive, RFC 3339"
+// @Param sort query string false "Comma separated fields, prefix with - for descending" default(-datetime)
+// @Param limit query int false "Page size" default(25)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} types.SessionListResponse
+// @Failure 400 {object} map[string]string
+// @Router /sessions [get]
+// @Security BearerAuth
+func ListSessions(c *gin.Context) {
+	query, ok := listQueryParam(c, models.SessionListSpec)
+	if !ok {
+		return
+	}
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := models.FetchSessions(c.Request.Context(), data.ID, query)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid cursor. Start again from the first page."})
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to fetch sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": sessions, "message": "Sessions fetched successfully"})
+}
+
+// @Summary Get session
+// @Description Get a session with its collaborators. Only its creator and collaborators can see it.
+// @ID get-session
+// @Produce  json
+// @Param X-Workspace-ID header int false "Workspace ID, defaults to the workspace of the token"
+// @Param id path int true "Session ID"
+// @Success 200 {object} types.ProcessedSessionResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /sessions/{id} [get]
+// @Security BearerAuth
+func GetSession(c *gin.Context) {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	sessionId, ok := sessionParam(c)
+	if !ok {
+		return
+	}
+
+	session, err := models.FetchSession(c.Request.Context(), sessionId, data.ID)
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": session, "message": "Session fetched successfully"})
+}
+
+// @Summary Update session
+// @Description Update the fields of a session that are sent. Its creator and collaborators can do this, unless they are viewers of the workspace. Moving, lengthening or reactivating a session fails with 409 and the conflicts when a participant is already in an overlapping session, unless allow_conflicts is set.
+// @ID update-session
+// @Accept  json
+// @Produce  json
+// @Param X-Workspace-ID header int false "Workspace ID, defaults to the workspace of the token"
+// @Param id path int true "Session ID"
+// @Param session body types.UpdateSessionPayload true "Session fields"
+// @Success 200 {object} types.ProcessedSessionResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /sessions/{id} [patch]
+// @Security BearerAuth
+func UpdateSession(c *gin.Context) {
+	var payload types.UpdateSessionPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	sessionId, ok := sessionParam(c)
+	if !ok {
+		return
+	}
+	if !sessionEditor(c) {
+		return
+	}
+
+	session, err := models.UpdateSession(c.Request.Context(), sessionId, data.ID, payload)
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": session, "message": "Session updated successfully"})
+}
+
+// @Summary Delete session
+// @Description Delete a session with its collaborators and attachments. Only its creator can do this.
+// @ID delete-session
+// @Produce  json
+// @Param X-Workspace-ID header int false "Workspace ID, defaults to the workspace of the token"
+// @Param id path int true "Session ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /sessions/{id} [delete]
+// @Security BearerAuth
+func DeleteSession(c *gin.Context) {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	sessionId, ok := sessionParam(c)
+	if !ok {
+		return
+	}
+	if !sessionEditor(c) {
+		return
+	}
+
+	if err := models.DeleteSession(c.Request.Context(), sessionId, data.ID); err != nil {
+		sessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": nil, "message": "Session deleted successfully"})
+}
+
+// sessionEditor writes a 403 response when the current user is a viewer of
+// the active workspace and reports whether they may change sessions.
+func sessionEditor(c *gin.Context) bool {
+	_, workspaceUser, ok := contextWorkspace(c)
+	if !ok {
+		return false
+	}
+	if !workspaceUser.IsOwner && workspaceUser.Role == utils.WORKSPACE_ROLE_VIEWER {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Viewers can't change sessions"})
+		return false
+	}
+	return true
+}
+
+func sessionParam(c *gin.Context) (int, bool) {
+	sessionId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid session ID"})
+		return 0, false
+	}
+	return sessionId, true
+}
+
+func sessionError(c *gin.Context, err error) {
+	if quotaExceeded(c, err) {
+		return
+	}
+	var conflictErr *models.SessionConflictError
+	switch {
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, gin.H{"status": "error", "data": gin.H{"conflicts": conflictErr.Conflicts}, "message": "Participants already have a session at this time. Pass allow_conflicts to schedule it anyway."})
+	case errors.Is(err, models.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "data": nil, "message": "Session not found"})
+	case errors.Is(err, models.ErrSessionAccessDenied):
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "You don't have access to this session"})
+	case errors.Is(err, models.ErrSessionCreatorRequired):
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Only the creator of the session can do this"})
+	case errors.Is(err, models.ErrCollaboratorNotMember):
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Collaborators must be members of this workspace"})
+	case errors.Is(err, models.ErrAlreadySessionCollaborator):
+		c.JSON(http.StatusConflict, gin.H{"status": "error", "data": nil, "message": "User is already part of this session"})
+	case errors.Is(err, models.ErrNotSessionCollaborator):
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "data": nil, "message": "User is not a collaborator of this session"})
+	case errors.Is(err, models.ErrAttachmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "data": nil, "message": "Attachment not found"})
+	case errors.Is(err, models.ErrAttachmentUploaderRequired):
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Only the uploader or the creator of the session can delete this attachment"})
+	default:
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to process session"})
+	}
+}
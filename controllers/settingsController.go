@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get settings
+// @Description Get the current user's settings. Settings the user hasn't changed have their default value.
+// @ID get-user-settings
+// @Produce  json
+// @Success 200 {object} types.UserSettings
+// @Failure 400 {object} map[string]string
+// @Router /user/settings [get]
+// @Security BearerAuth
+func GetUserSettings(c *gin.Context) {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	settings, err := models.FetchUserSettings(data.ID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to fetch settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": settings, "message": "Settings fetched successfully"})
+}
+
+// @Summary Update settings
+// @Description Update the current user's settings. Only the fields sent are changed; default_workspace_id sets the primary workspace that login signs into, 0 clears it.
+// @ID update-user-settings
+// @Accept  json
+// @Produce  json
+// @Param settings body types.UserSettingsPayload true "Settings to change"
+// @Success 200 {object} types.UserSettings
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /user/settings [patch]
+// @Security BearerAuth
+func UpdateUserSettings(c *gin.Context) {
+	var payload types.UserSettingsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	settings, err := models.UpdateUserSettings(data.ID, payload)
+	if errors.Is(err, models.ErrNotWorkspaceMember) {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []config.APIError{{Field: "DefaultWorkspaceId", Message: "You are not a member of this workspace."}}})
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to update settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": settings, "message": "Settings updated successfully"})
+}
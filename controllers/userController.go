@@ -1,57 +1,172 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 	"server/config"
 	"server/models"
 	"server/types"
+	"server/utils"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 // @Summary Get user
-// @Description Get user by ID
+// @Description Get the profile of the current user
 // @ID get-user
-// @Accept  json
 // @Produce  json
 // @Success 200 {object} types.UserResponse
 // @Failure 400 {object} map[string]string
 // @Router /user [get]
 // @Security BearerAuth
 func GetUser(c *gin.Context) {
-	var user types.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	userData, _ := models.FetchUser(data.ID)
+	if userData == nil {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "User data not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": userResponse(userData), "message": "User data fetched successfully"})
+}
+
+// @Summary Update user
+// @Description Update the profile of the current user. Only the fields sent are changed.
+// @ID update-user
+// @Accept  json
+// @Produce  json
+// @Param user body types.UpdateUserPayload true "Profile fields"
+// @Success 200 {object} types.UserResponse
+// @Failure 400 {object} map[string]string
+// @Router /user [patch]
+// @Security BearerAuth
+func UpdateUser(c *gin.Context) {
+	var payload types.UpdateUserPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
 		validationError := config.ValidationErrors(err, c)
 		if len(validationError) > 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
 			return
 		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
 	}
-	ctxUserData, ctxUserDataExists := c.Get("user")
-	if !ctxUserDataExists {
-		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "User not found"})
+
+	data, ok := contextUser(c)
+	if !ok {
 		return
 	}
 
-	data := ctxUserData.(*types.User)
-	if data == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to retrieve user data"})
+	userData, err := models.UpdateUserProfile(data.ID, payload)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to update user"})
 		return
 	}
 
-	userData, _ := models.FetchUser(data.ID)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": userResponse(userData), "message": "User updated successfully"})
+}
+
+// @Summary Get public profile
+// @Description Get another user's public profile. The email is only included for the user themselves and for members of a shared workspace.
+// @ID get-public-user
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} types.PublicUserResponse
+// @Failure 404 {object} map[string]string
+// @Router /users/{id} [get]
+// @Security BearerAuth
+func GetPublicUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid user ID"})
+		return
+	}
+
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	userData, _ := models.FetchUser(id)
 	if userData == nil {
-		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "User data not found"})
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "data": nil, "message": "User not found"})
 		return
 	}
 
-	userResponse := types.UserResponse{
-		ID:        userData.ID,
-		Name:      userData.Name,
-		Email:     userData.Email,
-		Avatar:    userData.Avatar,
-		CreatedAt: userData.CreatedAt,
-		UpdatedAt: userData.UpdatedAt,
+	response := types.PublicUserResponse{
+		ID:          userData.ID,
+		Name:        userData.Name,
+		Avatar:      userData.Avatar,
+		AvatarSizes: userData.AvatarSizes,
+		Timezone:    userData.Timezone,
+		Locale:      userData.Locale,
+		Title:       userData.Title,
+		Bio:         userData.Bio,
+	}
+	if data.ID == userData.ID || models.SharesWorkspace(data.ID, userData.ID) {
+		response.Email = userData.Email
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": response, "message": "User data fetched successfully"})
+}
+
+// @Summary Search users
+// @Description Find users who share a workspace with the current user by name or email, best match first. Meant for collaborator pickers.
+// @ID search-users
+// @Produce  json
+// @Param q query string true "Name or email, or the start of it"
+// @Param limit query int false "Maximum number of users, at most 25"
+// @Success 200 {array} types.CollaboratorResponse
+// @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /users/search [get]
+// @Security BearerAuth
+func SearchUsers(c *gin.Context) {
+	var query types.UserSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid query parameters"})
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = utils.USER_SEARCH_DEFAULT_LIMIT
+	}
+
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	users, err := models.SearchWorkspaceUsers(data.ID, query.Query, query.Limit)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "data": nil, "message": "Failed to search users"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": users, "message": "Users fetched successfully"})
+}
+
+func userResponse(userData *types.User) types.UserResponse {
+	return types.UserResponse{
+		ID:          userData.ID,
+		Name:        userData.Name,
+		Email:       userData.Email,
+		Avatar:      userData.Avatar,
+		AvatarSizes: userData.AvatarSizes,
+		Timezone:    userData.Timezone,
+		Locale:      userData.Locale,
+		Title:       userData.Title,
+		Bio:         userData.Bio,
+		CreatedAt:   userData.CreatedAt,
+		UpdatedAt:   userData.UpdatedAt,
 	}
-	c.JSON(http.StatusOK, gin.H{"status": "success", "data": userResponse, "message": "User data fetched successfully"})
 }
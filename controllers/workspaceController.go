@@ -0,0 +1,292 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/types"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Create workspace
+// @Description Create a workspace. The creator becomes its owner.
+// @ID create-workspace
+// @Accept  json
+// @Produce  json
+// @Param workspace body types.WorkspacePayload true "Workspace"
+// @Success 201 {object} types.WorkspaceResponse
+// @Failure 400 {object} map[string]string
+// @Router /workspaces [post]
+// @Security BearerAuth
+func CreateWorkspace(c *gin.Context) {
+	var payload types.WorkspacePayload
+	if err := c.ShouldBind(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	workspace, err := models.CreateWorkspace(data.ID, payload)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to create workspace"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "success", "data": workspace, "message": "Workspace created successfully"})
+}
+
+// @Summary List workspaces
+// @Description List the workspaces the current user is a member of, primary first.
+// @ID list-workspaces
+// @Produce  json
+// @Param include_archived query bool false "Include archived workspaces"
+// @Success 200 {array} types.WorkspaceResponse
+// @Failure 400 {object} map[string]string
+// @Router /workspaces [get]
+// @Security BearerAuth
+func ListWorkspaces(c *gin.Context) {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+
+	workspaces, err := models.FetchUserWorkspaces(data.ID, c.Query("include_archived") == "true")
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to fetch workspaces"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": workspaces, "message": "Workspaces fetched successfully"})
+}
+
+// @Summary Get workspace
+// @Description Get a workspace the current user is a member of.
+// @ID get-workspace
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} types.WorkspaceResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /workspaces/{id} [get]
+// @Security BearerAuth
+func GetWorkspace(c *gin.Context) {
+	workspace, workspaceUser, ok := memberWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": workspaceResponse(workspace, workspaceUser), "message": "Workspace fetched successfully"})
+}
+
+// @Summary Workspace usage
+// @Description Show the workspace's plan and how much of its seats, sessions and attachment storage is used. A limit of 0 means unlimited.
+// @ID get-workspace-usage
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} types.WorkspaceUsageResponse
+// @Failure 403 {object} map[string]string
+// @Router /workspaces/{id}/usage [get]
+// @Security BearerAuth
+func GetWorkspaceUsage(c *gin.Context) {
+	workspace, _, ok := memberWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+
+	usage, err := models.FetchWorkspaceUsage(workspace)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to fetch usage"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": usage, "message": "Usage fetched successfully"})
+}
+
+// @Summary Update workspace
+// @Description Update the name or role of a workspace. Only the fields sent are changed. Only workspace owners can do this.
+// @ID update-workspace
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Param workspace body types.UpdateWorkspacePayload true "Workspace fields"
+// @Success 200 {object} types.WorkspaceResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /workspaces/{id} [patch]
+// @Security BearerAuth
+func UpdateWorkspace(c *gin.Context) {
+	var payload types.UpdateWorkspacePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+
+	workspace, workspaceUser, ok := memberWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+	if !workspaceUser.IsOwner {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Only workspace owners can do this"})
+		return
+	}
+	if !writableWorkspace(c, workspace) {
+		return
+	}
+
+	workspace, err := models.UpdateWorkspace(workspace.ID, payload)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to update workspace"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": workspaceResponse(workspace, workspaceUser), "message": "Workspace updated successfully"})
+}
+
+// @Summary Archive workspace
+// @Description Archive a workspace. Archived workspaces are left out of the workspace list by default. Only workspace owners can do this.
+// @ID archive-workspace
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} types.WorkspaceResponse
+// @Failure 403 {object} map[string]string
+// @Router /workspaces/{id}/archive [post]
+// @Security BearerAuth
+func ArchiveWorkspace(c *gin.Context) {
+	workspace, workspaceUser, ok := memberWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+	if !workspaceUser.IsOwner {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Only workspace owners can do this"})
+		return
+	}
+
+	workspace, err := models.ArchiveWorkspace(workspace.ID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to archive workspace"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": workspaceResponse(workspace, workspaceUser), "message": "Workspace archived successfully"})
+}
+
+// @Summary Unarchive workspace
+// @Description Make an archived workspace active and writable again. Only workspace owners can do this.
+// @ID unarchive-workspace
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} types.WorkspaceResponse
+// @Failure 403 {object} map[string]string
+// @Router /workspaces/{id}/unarchive [post]
+// @Security BearerAuth
+func UnarchiveWorkspace(c *gin.Context) {
+	workspace, workspaceUser, ok := memberWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+	if !workspaceUser.IsOwner {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Only workspace owners can do this"})
+		return
+	}
+
+	workspace, err := models.UnarchiveWorkspace(workspace.ID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to unarchive workspace"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": workspaceResponse(workspace, workspaceUser), "message": "Workspace unarchived successfully"})
+}
+
+// @Summary Delete workspace
+// @Description Archive a workspace and schedule it for permanent deletion. Until the retention window ends the owner can restore it; after that its sessions, attachments, collaborators and files are purged. Only workspace owners can do this.
+// @ID delete-workspace
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} types.WorkspaceResponse
+// @Failure 403 {object} map[string]string
+// @Router /workspaces/{id} [delete]
+// @Security BearerAuth
+func DeleteWorkspace(c *gin.Context) {
+	workspace, workspaceUser, ok := memberWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+	if !workspaceUser.IsOwner {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Only workspace owners can do this"})
+		return
+	}
+
+	workspace, err := models.DeleteWorkspace(workspace.ID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to delete workspace"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": workspaceResponse(workspace, workspaceUser), "message": "Workspace scheduled for deletion"})
+}
+
+// @Summary Restore workspace
+// @Description Cancel the deletion of a workspace before its retention window ends. The workspace stays archived until it is unarchived. Only workspace owners can do this.
+// @ID restore-workspace
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} types.WorkspaceResponse
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /workspaces/{id}/restore [post]
+// @Security BearerAuth
+func RestoreWorkspace(c *gin.Context) {
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	// Deleted workspaces are hidden from workspaceParam.
+	workspaceId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid workspace ID"})
+		return
+	}
+	workspaceUser, _ := models.FetchWorkspaceUser(workspaceId, data.ID)
+	if workspaceUser == nil || !workspaceUser.IsOwner {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Only workspace owners can do this"})
+		return
+	}
+
+	workspace, err := models.RestoreWorkspace(workspaceId)
+	if errors.Is(err, models.ErrWorkspaceNotRestorable) {
+		c.JSON(http.StatusConflict, gin.H{"status": "error", "data": nil, "message": "Workspace is not deleted or can no longer be restored"})
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to restore workspace"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": workspaceResponse(workspace, workspaceUser), "message": "Workspace restored successfully"})
+}
+
+func workspaceResponse(workspace *types.Workspace, workspaceUser *types.WorkspaceUser) types.WorkspaceResponse {
+	return types.WorkspaceResponse{
+		Workspace: *workspace,
+		IsOwner:   workspaceUser.IsOwner,
+		IsPrimary: workspaceUser.IsPrimary,
+	}
+}
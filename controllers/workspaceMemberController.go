@@ -0,0 +1,289 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/types"
+	"server/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List workspace members
+// @Description List the members of a workspace the current user belongs to.
+// @ID list-workspace-members
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Success 200 {array} types.WorkspaceMemberResponse
+// @Failure 403 {object} map[string]string
+// @Router /workspaces/{id}/members [get]
+// @Security BearerAuth
+func ListWorkspaceMembers(c *gin.Context) {
+	workspace, _, ok := memberWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+
+	members, err := models.FetchWorkspaceMembers(workspace.ID)
+	if err != nil {
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to fetch members"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": members, "message": "Members fetched successfully"})
+}
+
+// @Summary Add workspace member
+// @Description Add an existing user who already shares a workspace with the current user, by user ID or email. Anyone else has to be invited. Only owners and admins can do this.
+// @ID add-workspace-member
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Param member body types.WorkspaceMemberPayload true "Member"
+// @Success 201 {object} types.WorkspaceUser
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 402 {object} map[string]string
+// @Router /workspaces/{id}/members [post]
+// @Security BearerAuth
+func AddWorkspaceMember(c *gin.Context) {
+	var payload types.WorkspaceMemberPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+	if payload.Role == "" {
+		payload.Role = utils.WORKSPACE_ROLE_MEMBER
+	}
+
+	data, ok := contextUser(c)
+	if !ok {
+		return
+	}
+	workspace, ok := managedWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var userData *types.User
+	if payload.UserId != 0 {
+		userData, _ = models.FetchUser(payload.UserId)
+	} else {
+		userData, _ = models.FetchUserByEmail(payload.Email)
+	}
+	if userData == nil {
+		memberNotFound(c)
+		return
+	}
+
+	workspaceUser, err := models.AddWorkspaceMember(workspace.ID, data.ID, userData.ID, payload.Role)
+	if err != nil {
+		workspaceMemberError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "success", "data": workspaceUser, "message": "Member added successfully"})
+}
+
+// @Summary Change member role
+// @Description Change the role of a workspace member. The owner's role can't be changed. Only owners and admins can do this.
+// @ID update-workspace-member
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Param user_id path int true "User ID"
+// @Param role body types.WorkspaceMemberRolePayload true "Role"
+// @Success 200 {object} types.WorkspaceUser
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /workspaces/{id}/members/{user_id} [patch]
+// @Security BearerAuth
+func UpdateWorkspaceMember(c *gin.Context) {
+	var payload types.WorkspaceMemberRolePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+
+	workspace, ok := managedWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+	userId, ok := memberUserParam(c)
+	if !ok {
+		return
+	}
+
+	workspaceUser, err := models.UpdateWorkspaceMemberRole(workspace.ID, userId, payload.Role)
+	if err != nil {
+		workspaceMemberError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": workspaceUser, "message": "Member updated successfully"})
+}
+
+// @Summary Remove workspace member
+// @Description Remove a member from a workspace. Owners and admins can remove other members and anyone can leave. The owner has to transfer ownership before leaving. When it was the member's primary workspace, another of their workspaces becomes primary.
+// @ID remove-workspace-member
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Param user_id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /workspaces/{id}/members/{user_id} [delete]
+// @Security BearerAuth
+func RemoveWorkspaceMember(c *gin.Context) {
+	workspace, workspaceUser, ok := memberWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+	userId, ok := memberUserParam(c)
+	if !ok {
+		return
+	}
+	if userId != workspaceUser.UserId && !canManageWorkspace(workspaceUser) {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Only workspace owners and admins can do this"})
+		return
+	}
+
+	if err := models.RemoveWorkspaceMember(workspace.ID, userId); err != nil {
+		workspaceMemberError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": nil, "message": "Member removed successfully"})
+}
+
+// @Summary Transfer ownership
+// @Description Make another member the owner of a workspace. The current owner stays on as an admin. Only the owner can do this.
+// @ID transfer-workspace-ownership
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Param owner body types.TransferOwnershipPayload true "New owner"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /workspaces/{id}/transfer-ownership [post]
+// @Security BearerAuth
+func TransferWorkspaceOwnership(c *gin.Context) {
+	var payload types.TransferOwnershipPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		validationError := config.ValidationErrors(err, c)
+		if len(validationError) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": validationError})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid request body"})
+		return
+	}
+
+	workspace, ok := ownedWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := models.TransferWorkspaceOwnership(workspace.ID, payload.UserId); err != nil {
+		workspaceMemberError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": nil, "message": "Ownership transferred successfully"})
+}
+
+// @Summary Set primary workspace
+// @Description Make a workspace the current user's primary workspace.
+// @ID set-primary-workspace
+// @Produce  json
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} types.WorkspaceUser
+// @Failure 403 {object} map[string]string
+// @Router /workspaces/{id}/primary [put]
+// @Security BearerAuth
+func SetPrimaryWorkspace(c *gin.Context) {
+	workspace, workspaceUser, ok := memberWorkspaceParam(c, "id")
+	if !ok {
+		return
+	}
+
+	workspaceUser, err := models.SetPrimaryWorkspace(workspaceUser.UserId, workspace.ID)
+	if err != nil {
+		workspaceMemberError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": workspaceUser, "message": "Primary workspace updated successfully"})
+}
+
+// managedWorkspaceParam is workspaceParam for routes restricted to the owner
+// and admins of the workspace.
+func managedWorkspaceParam(c *gin.Context, name string) (*types.Workspace, bool) {
+	workspace, workspaceUser, ok := memberWorkspaceParam(c, name)
+	if !ok {
+		return nil, false
+	}
+	if !canManageWorkspace(workspaceUser) {
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "data": nil, "message": "Only workspace owners and admins can do this"})
+		return nil, false
+	}
+	if !writableWorkspace(c, workspace) {
+		return nil, false
+	}
+	return workspace, true
+}
+
+func canManageWorkspace(workspaceUser *types.WorkspaceUser) bool {
+	return workspaceUser.IsOwner || workspaceUser.Role == utils.WORKSPACE_ROLE_ADMIN
+}
+
+func memberUserParam(c *gin.Context) (int, bool) {
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Invalid user ID"})
+		return 0, false
+	}
+	return userId, true
+}
+
+func workspaceMemberError(c *gin.Context, err error) {
+	if quotaExceeded(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, models.ErrNotWorkspaceMember):
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "data": nil, "message": "User is not a member of this workspace"})
+	case errors.Is(err, models.ErrAlreadyWorkspaceMember):
+		c.JSON(http.StatusConflict, gin.H{"status": "error", "data": nil, "message": "User is already a member of this workspace"})
+	case errors.Is(err, models.ErrMemberInvitationRequired):
+		// Answer like an unknown user, so adding can't be used to find out
+		// who has an account.
+		memberNotFound(c)
+	case errors.Is(err, models.ErrWorkspaceOwnerRole):
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "The owner's role can't be changed"})
+	case errors.Is(err, models.ErrWorkspaceOwnerRequired):
+		c.JSON(http.StatusConflict, gin.H{"status": "error", "data": nil, "message": "A workspace must keep its owner. Transfer ownership first."})
+	default:
+		fmt.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "data": nil, "message": "Failed to update members"})
+	}
+}
+
+func memberNotFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{"status": "error", "data": nil, "message": "User not found among the people you share a workspace with. Send an invitation instead."})
+}
@@ -2,14 +2,28 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"server/config"
+	"server/models"
 	"server/routes"
+	"server/utils"
 )
 
 func main() {
 	config.EnvLoad()
 	config.InitDBConnection()
+	if err := models.Migrate(); err != nil {
+		log.Fatalf("database migration failed: %v", err)
+	}
+	if err := models.RegisterTenantScopes(); err != nil {
+		log.Fatalf("failed to register tenant scopes: %v", err)
+	}
+	if err := config.RegisterValidators(); err != nil {
+		log.Fatalf("failed to register validators: %v", err)
+	}
+	go models.RunWorkspacePurge(utils.WORKSPACE_PURGE_INTERVAL)
+	go models.RunDataExportCleanup(utils.DATA_EXPORT_CLEANUP_INTERVAL)
 
 	// @title Gin Postgres Swagger Example API
 	// @version 1.0
@@ -1,10 +1,14 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"server/config"
 	"server/models"
 	"server/types"
+	"server/utils"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -12,35 +16,96 @@ import (
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		tokenString, fromCookie := requestToken(c)
+		if tokenString == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Authorization header is missing"})
 			c.Abort()
 			return
 		}
 
-		tokenString := strings.TrimSpace(strings.Replace(authHeader, "Bearer", "", 1))
-		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Authorization header is missing"})
+		// Browsers attach cookies to cross-site requests, so cookie
+		// authenticated requests that change state must also prove they can
+		// read the CSRF cookie by echoing it in the X-CSRF-Token header.
+		if fromCookie && !isSafeMethod(c.Request.Method) && !validCSRFToken(c) {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "Invalid CSRF token"})
 			c.Abort()
 			return
 		}
 
-		userData, userDataErr := models.VerifyJWTToken(tokenString)
+		user, tokenWorkspaceId, userDataErr := models.AuthenticateJWTToken(tokenString)
 		if userDataErr != nil {
 			fmt.Println(userDataErr)
 			c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Invalid token"})
 			c.Abort()
 			return
 		}
+		c.Set("user", user)
+		if tokenWorkspaceId != 0 {
+			c.Set("token_workspace_id", tokenWorkspaceId)
+		}
+
+		c.Next()
+	}
+}
 
-		user := &types.User{
-			ID:    userData.ID,
-			Name:  userData.Name,
-			Email: userData.Email,
+// OptionalAuthMiddleware sets the user like AuthMiddleware when the request
+// carries a valid token, and lets it through without one. It skips the CSRF
+// check, so it must only guard safe methods.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tokenString, _ := requestToken(c); tokenString != "" {
+			user, tokenWorkspaceId, err := models.AuthenticateJWTToken(tokenString)
+			if err == nil {
+				c.Set("user", user)
+				if tokenWorkspaceId != 0 {
+					c.Set("token_workspace_id", tokenWorkspaceId)
+				}
+			}
 		}
-		c.Set("user", user)
+		c.Next()
+	}
+}
 
+// RequireRole only lets users with one of the given roles through. It must
+// run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctxUserData, _ := c.Get("user")
+		user, _ := ctxUserData.(*types.User)
+		if user == nil || !slices.Contains(roles, user.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "You are not allowed to access this resource"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
+
+// requestToken returns the JWT from the Authorization header, falling back to
+// the auth cookie when cookie mode is enabled.
+func requestToken(c *gin.Context) (token string, fromCookie bool) {
+	if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+		return strings.TrimSpace(strings.Replace(authHeader, "Bearer", "", 1)), false
+	}
+	if !config.AuthCookieConfig().Enabled {
+		return "", false
+	}
+	cookie, err := c.Cookie(utils.AUTH_COOKIE_NAME)
+	if err != nil {
+		return "", false
+	}
+	return cookie, true
+}
+
+func validCSRFToken(c *gin.Context) bool {
+	cookie, err := c.Cookie(utils.CSRF_COOKIE_NAME)
+	header := c.GetHeader(utils.CSRF_HEADER_NAME)
+	if err != nil || cookie == "" || header == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
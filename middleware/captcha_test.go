@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/utils"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// captchaRouter serves /login behind the CAPTCHA middleware with a stub
+// verifier that accepts the token "pass". The password "right" succeeds.
+func captchaRouter(t *testing.T, threshold int) *gin.Engine {
+	t.Helper()
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		json.NewEncoder(w).Encode(map[string]bool{"success": r.PostForm.Get("response") == "pass"})
+	}))
+	t.Cleanup(stub.Close)
+	t.Setenv("CAPTCHA_PROVIDER", "turnstile")
+	t.Setenv("CAPTCHA_SECRET", "stub-secret")
+	t.Setenv("CAPTCHA_VERIFY_URL", stub.URL)
+
+	captchaFailuresMu.Lock()
+	captchaFailures = map[string]*failureCount{}
+	captchaFailuresMu.Unlock()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/login", CaptchaMiddleware(threshold), func(c *gin.Context) {
+		if c.Query("password") != "right" {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func login(router *gin.Engine, password, captchaToken string) int {
+	request := httptest.NewRequest(http.MethodPost, "/login?password="+password, nil)
+	if captchaToken != "" {
+		request.Header.Set(utils.CAPTCHA_HEADER_NAME, captchaToken)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestCaptchaRequiredAfterFailures(t *testing.T) {
+	router := captchaRouter(t, 2)
+
+	for i := 0; i < 2; i++ {
+		if status := login(router, "wrong", ""); status != http.StatusUnauthorized {
+			t.Fatalf("failed login %d: status %d, want 401", i+1, status)
+		}
+	}
+	if status := login(router, "right", ""); status != http.StatusForbidden {
+		t.Errorf("login without a token after failures: status %d, want 403", status)
+	}
+	if status := login(router, "right", "fail"); status != http.StatusForbidden {
+		t.Errorf("login with a rejected token: status %d, want 403", status)
+	}
+	if status := login(router, "right", "pass"); status != http.StatusOK {
+		t.Errorf("login with a valid token: status %d, want 200", status)
+	}
+}
+
+func TestCaptchaFailuresSurviveSuccessfulLogins(t *testing.T) {
+	router := captchaRouter(t, 2)
+
+	// Logins to an account the client controls between guesses must not
+	// reset the count.
+	login(router, "wrong", "")
+	if status := login(router, "right", ""); status != http.StatusOK {
+		t.Fatalf("successful login: status %d, want 200", status)
+	}
+	login(router, "wrong", "")
+	if status := login(router, "wrong", ""); status != http.StatusForbidden {
+		t.Errorf("guess after interleaved successes: status %d, want 403", status)
+	}
+}
+
+func TestCaptchaAlwaysRequiredWithZeroThreshold(t *testing.T) {
+	router := captchaRouter(t, 0)
+	if status := login(router, "right", ""); status != http.StatusForbidden {
+		t.Errorf("login without a token: status %d, want 403", status)
+	}
+	if status := login(router, "right", "pass"); status != http.StatusOK {
+		t.Errorf("login with a valid token: status %d, want 200", status)
+	}
+}
@@ -2,16 +2,33 @@ package middleware
 
 import (
 	"log"
+	"os"
+	"slices"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 func InitCORSMiddleware() gin.HandlerFunc {
+	// Credentialed requests (cookie mode) are rejected by browsers when the
+	// allowed origin is "*", so echo the origin when it is in CORS_ALLOWED_ORIGINS.
+	var allowedOrigins []string
+	if origins := os.Getenv("CORS_ALLOWED_ORIGINS"); origins != "" {
+		allowedOrigins = strings.Split(origins, ",")
+	}
+
 	return func(ctx *gin.Context) {
-		ctx.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if len(allowedOrigins) == 0 {
+			ctx.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			ctx.Writer.Header().Add("Vary", "Origin")
+			if origin := ctx.GetHeader("Origin"); slices.Contains(allowedOrigins, origin) {
+				ctx.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
 		ctx.Writer.Header().Set("Access-Control-Max-Age", "86400")
-		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-		ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, api_key, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE, UPDATE")
+		ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, api_key, Content-Length, Accept-Encoding, X-CSRF-Token, X-Captcha-Token, X-Workspace-ID, Authorization")
 		ctx.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
 		ctx.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		ctx.Writer.Header().Set("Cache-Control", "no-cache")
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"server/types"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type requestCount struct {
+	count   int
+	resetAt time.Time
+}
+
+// RateLimitMiddleware allows each client limit requests per window on the
+// routes it guards. Authenticated requests are counted per user, others per
+// IP, so it should run after AuthMiddleware where there is one. Every call
+// creates its own counters, so routes don't share a budget.
+func RateLimitMiddleware(limit int, window time.Duration) gin.HandlerFunc {
+	var (
+		counts   = map[string]*requestCount{}
+		countsMu sync.Mutex
+	)
+	return func(c *gin.Context) {
+		key := "ip:" + c.ClientIP()
+		if ctxUserData, ok := c.Get("user"); ok {
+			if user, _ := ctxUserData.(*types.User); user != nil {
+				key = "user:" + strconv.Itoa(user.ID)
+			}
+		}
+
+		countsMu.Lock()
+		now := time.Now()
+		entry, ok := counts[key]
+		if !ok || entry.resetAt.Before(now) {
+			entry = &requestCount{resetAt: now.Add(window)}
+			counts[key] = entry
+		}
+		entry.count++
+		exceeded := entry.count > limit
+		retryAfter := entry.resetAt.Sub(now)
+
+		if len(counts) > 10000 {
+			for k, value := range counts {
+				if value.resetAt.Before(now) {
+					delete(counts, k)
+				}
+			}
+		}
+		countsMu.Unlock()
+
+		if exceeded {
+			c.Header("Retry-After", fmt.Sprint(int(math.Ceil(retryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"status": "error", "data": nil, "message": "Too many requests. Try again later."})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"server/models"
+	"server/types"
+	"server/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkspaceMiddleware resolves the active workspace of a request from the
+// X-Workspace-ID header, falling back to the workspace_id claim of the token,
+// and checks that the user is a member. The workspace and membership are
+// stored in the context as "workspace" and "workspace_user", and the request
+// context is scoped to the workspace with models.WithTenant. Archived
+// workspaces only accept reads. It must run after AuthMiddleware.
+func WorkspaceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctxUserData, _ := c.Get("user")
+		user, _ := ctxUserData.(*types.User)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "User not found"})
+			c.Abort()
+			return
+		}
+
+		workspaceId := c.GetInt("token_workspace_id")
+		if header := c.GetHeader(utils.WORKSPACE_HEADER_NAME); header != "" {
+			id, err := strconv.Atoi(header)
+			if err != nil || id <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Invalid workspace ID"})
+				c.Abort()
+				return
+			}
+			workspaceId = id
+		}
+		if workspaceId == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Workspace is required. Send it in the X-Workspace-ID header."})
+			c.Abort()
+			return
+		}
+
+		workspaceUser, _ := models.FetchWorkspaceUser(workspaceId, user.ID)
+		if workspaceUser == nil {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "You are not a member of this workspace"})
+			c.Abort()
+			return
+		}
+		workspace, _ := models.FetchWorkspace(workspaceId)
+		if workspace == nil || workspace.DeletedAt != nil {
+			c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": "Workspace not found"})
+			c.Abort()
+			return
+		}
+		if workspace.ArchivedAt != nil && !isSafeMethod(c.Request.Method) {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "Workspace is archived and read-only"})
+			c.Abort()
+			return
+		}
+
+		c.Set("workspace", workspace)
+		c.Set("workspace_user", workspaceUser)
+		ctx := models.WithTenant(c.Request.Context(), workspace.ID)
+		c.Request = c.Request.WithContext(models.WithTenantUser(ctx, user.ID))
+		c.Next()
+	}
+}
@@ -0,0 +1,260 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUserOwnsWorkspaces = errors.New("user owns workspaces")
+
+// SearchUsers lists users for the admin console.
+//
+// Parameters:
+//   - query: The search text, status, provider, creation date filters and page.
+//
+// Returns:
+//   - []types.User: The users on the requested page, newest first.
+//   - int64: The total number of users matching the filters.
+//   - error: An error object if there is an issue querying the users.
+func SearchUsers(query types.AdminUserQuery) ([]types.User, int64, error) {
+	db := config.DB.Model(&types.User{})
+	if q := strings.TrimSpace(query.Query); q != "" {
+		pattern := "%" + escapeLike(q) + "%"
+		db = db.Where("name ILIKE ? OR email ILIKE ?", pattern, pattern)
+	}
+	switch query.Status {
+	case utils.USER_STATUS_ACTIVE:
+		db = db.Where("suspended_at IS NULL")
+	case utils.USER_STATUS_SUSPENDED:
+		db = db.Where("suspended_at IS NOT NULL")
+	}
+	if query.Provider != "" {
+		db = db.Where("provider=?", query.Provider)
+	}
+	if query.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *query.CreatedFrom)
+	}
+	if query.CreatedTo != nil {
+		db = db.Where("created_at < ?", *query.CreatedTo)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []types.User
+	result := db.Order("created_at DESC, id DESC").
+		Offset((query.Page - 1) * query.PerPage).
+		Limit(query.PerPage).
+		Find(&users)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	return users, total, nil
+}
+
+// SuspendUser blocks a user from signing in and invalidates their tokens.
+//
+// Parameters:
+//   - id: The ID of the user to suspend.
+//
+// Returns:
+//   - *types.User: The updated user.
+//   - error: An error object if there is an issue updating the user.
+func SuspendUser(id int) (*types.User, error) {
+	now := time.Now()
+	return updateUserAccount(id, map[string]interface{}{
+		"suspended_at":      now,
+		"tokens_revoked_at": now,
+	})
+}
+
+// UnsuspendUser lets a suspended user sign in again.
+//
+// Parameters:
+//   - id: The ID of the user to unsuspend.
+//
+// Returns:
+//   - *types.User: The updated user.
+//   - error: An error object if there is an issue updating the user.
+func UnsuspendUser(id int) (*types.User, error) {
+	return updateUserAccount(id, map[string]interface{}{"suspended_at": nil})
+}
+
+// RevokeUserTokens signs a user out everywhere. Tokens issued before now are
+// rejected and OAuth refresh tokens are revoked.
+//
+// Parameters:
+//   - id: The ID of the user.
+//
+// Returns:
+//   - *types.User: The updated user.
+//   - error: An error object if there is an issue updating the user.
+func RevokeUserTokens(id int) (*types.User, error) {
+	return updateUserAccount(id, map[string]interface{}{"tokens_revoked_at": time.Now()})
+}
+
+// ForcePasswordReset requires a user to choose a new password before they can
+// sign in again, revokes their tokens and emails them a reset link.
+//
+// Parameters:
+//   - id: The ID of the user.
+//
+// Returns:
+//   - *types.User: The updated user.
+//   - error: An error object if there is an issue updating the user.
+func ForcePasswordReset(id int) (*types.User, error) {
+	user, err := updateUserAccount(id, map[string]interface{}{
+		"password_reset_required": true,
+		"tokens_revoked_at":       time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := CreatePasswordResetToken(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	utils.SendMailAsync(user.Email, "Reset your password",
+		fmt.Sprintf("An administrator has asked you to choose a new password. Use the link below to set one. It expires in one hour.\n\n%s/reset-password?token=%s", utils.AppURL(), token))
+	return user, nil
+}
+
+// DeleteUser permanently removes a user together with their workspace
+// memberships, collaborations, OAuth clients, outstanding tokens, data exports
+// and avatar. Sessions they created are handed to the owner of the session's
+// workspace. Owners of workspaces that aren't deleted can't be deleted, so no
+// workspace is left without an owner.
+//
+// Parameters:
+//   - id: The ID of the user to delete.
+//
+// Returns:
+//   - error: ErrUserOwnsWorkspaces, or an error object if there is an issue
+//     deleting the user. Files that can't be removed from S3 are logged but
+//     don't fail the deletion.
+func DeleteUser(id int) error {
+	var objectKeys []string
+	// The user's sessions and collaborations span workspaces.
+	err := SystemTransaction(func(tx *gorm.DB) error {
+		var user types.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "avatar", "avatar_sizes").First(&user, id).Error; err != nil {
+			return err
+		}
+		objectKeys = avatarObjectKeys(user.Avatar, user.AvatarSizes)
+		// Export archives hold a copy of the user's personal data.
+		var exportKeys []string
+		if err := tx.Model(&types.DataExport{}).Where("user_id=? AND object_key <> ''", id).Pluck("object_key", &exportKeys).Error; err != nil {
+			return err
+		}
+		objectKeys = append(objectKeys, exportKeys...)
+
+		var owned int64
+		err := tx.Model(&types.WorkspaceUser{}).
+			Joins("JOIN "+utils.WORKSPACES_TABLE+" ON "+utils.WORKSPACES_TABLE+".id = "+utils.WORKSPACE_USERS_TABLE+".workspace_id").
+			Where(utils.WORKSPACE_USERS_TABLE+".user_id = ? AND "+utils.WORKSPACE_USERS_TABLE+".is_owner AND "+utils.WORKSPACES_TABLE+".deleted_at IS NULL", id).
+			Count(&owned).Error
+		if err != nil {
+			return err
+		}
+		if owned > 0 {
+			return ErrUserOwnsWorkspaces
+		}
+
+		// A new creator who already collaborates on a session stays a
+		// participant only once.
+		err = tx.Exec("DELETE FROM "+utils.SESSION_COLLABORATORS_TABLE+" AS sc USING "+utils.SESSIONS_TABLE+" AS s, "+utils.WORKSPACE_USERS_TABLE+" AS wu "+
+			"WHERE sc.session_id = s.id AND s.created_by = ? AND wu.workspace_id = s.workspace_id AND wu.is_owner AND sc.user_id = wu.user_id", id).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec("UPDATE "+utils.SESSIONS_TABLE+" AS s SET created_by = wu.user_id FROM "+utils.WORKSPACE_USERS_TABLE+" AS wu "+
+			"WHERE wu.workspace_id = s.workspace_id AND wu.is_owner AND s.created_by = ?", id).Error
+		if err != nil {
+			return err
+		}
+
+		var clientIds []string
+		if err := tx.Model(&types.OAuthClient{}).Where("created_by=?", id).Pluck("client_id", &clientIds).Error; err != nil {
+			return err
+		}
+		if len(clientIds) > 0 {
+			for _, model := range []interface{}{&types.OAuthRefreshToken{}, &types.OAuthAuthorizationCode{}, &types.OAuthConsent{}, &types.OAuthClient{}} {
+				if err := tx.Where("client_id IN ?", clientIds).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		cleanups := []interface{}{
+			&types.WorkspaceUser{},
+			&types.SessionCollaborator{},
+			&types.OAuthRefreshToken{},
+			&types.OAuthAuthorizationCode{},
+			&types.OAuthConsent{},
+			&types.PasswordResetToken{},
+			&types.CalendarFeed{},
+			&types.DataExport{},
+		}
+		for _, model := range cleanups {
+			if err := tx.Where("user_id=?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// Files go last so a failed transaction never leaves rows pointing at
+	// missing objects.
+	if len(objectKeys) > 0 {
+		failed, err := utils.DeleteFromS3(objectKeys)
+		if err != nil {
+			log.Printf("delete user: failed to delete files of user %d: %v", id, err)
+		}
+		for _, key := range failed {
+			log.Printf("delete user: file left behind for user %d: %s", id, key)
+		}
+	}
+	return nil
+}
+
+// updateUserAccount applies admin changes to a user. Whenever the user's
+// tokens are revoked, their OAuth refresh tokens are revoked as well.
+func updateUserAccount(id int, updates map[string]interface{}) (*types.User, error) {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&types.User{}).Where("id=?", id).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if _, ok := updates["tokens_revoked_at"]; ok {
+			return tx.Model(&types.OAuthRefreshToken{}).Where("user_id=? AND revoked=?", id, false).Update("revoked", true).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return FetchUser(id)
+}
+
+// escapeLike escapes the LIKE wildcards in user supplied search text.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
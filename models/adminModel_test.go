@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"testing"
+	"time"
+)
+
+func TestDeleteUserKeepsWorkspacesOwned(t *testing.T) {
+	testDB(t)
+
+	owner := types.User{Name: "Owner", Email: testEmail(t, "owner")}
+	member := types.User{Name: "Member", Email: testEmail(t, "member")}
+	for _, user := range []*types.User{&owner, &member} {
+		if err := config.DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	workspace := types.Workspace{Name: "Delete user", Role: "test", Status: true}
+	if err := config.DB.Create(&workspace).Error; err != nil {
+		t.Fatal(err)
+	}
+	memberships := []types.WorkspaceUser{
+		{WorkspaceId: workspace.ID, UserId: owner.ID, IsOwner: true},
+		{WorkspaceId: workspace.ID, UserId: member.ID},
+	}
+	if err := config.DB.Create(&memberships).Error; err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().UTC()
+	session := types.Session{Title: "Interview", WorkspaceId: workspace.ID, CreatedBy: member.ID, Status: true,
+		Datetime: start, EndsAt: start.Add(time.Hour), DurationMinutes: 60}
+	if err := config.DB.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	client := types.OAuthClient{ClientId: "delete-user-" + member.Email, Name: "Client", CreatedBy: member.ID, Status: true}
+	if err := config.DB.Create(&client).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	export := types.DataExport{UserId: member.ID, Status: utils.DATA_EXPORT_STATUS_FAILED}
+	if err := config.DB.Create(&export).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteUser(owner.ID); !errors.Is(err, ErrUserOwnsWorkspaces) {
+		t.Fatalf("deleting the owner: error = %v, want ErrUserOwnsWorkspaces", err)
+	}
+	if err := DeleteUser(member.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := config.DB.First(&session, session.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if session.CreatedBy != owner.ID {
+		t.Errorf("session created_by = %d, want the owner %d", session.CreatedBy, owner.ID)
+	}
+	var clients int64
+	config.DB.Model(&types.OAuthClient{}).Where("created_by=?", member.ID).Count(&clients)
+	if clients != 0 {
+		t.Error("OAuth clients of the deleted user were left behind")
+	}
+	var exports int64
+	config.DB.Model(&types.DataExport{}).Where("user_id=?", member.ID).Count(&exports)
+	if exports != 0 {
+		t.Error("data exports of the deleted user were left behind")
+	}
+}
+
+func TestRevokedTokensOfTheSameSecondAreRejected(t *testing.T) {
+	testDB(t)
+	t.Setenv("SECRET", "test-secret")
+
+	user := types.User{Name: "Revoked", Email: testEmail(t, "revoked")}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	token, err := CreateJWTToken(user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RevokeUserTokens(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := AuthenticateJWTToken(token); err == nil {
+		t.Fatal("token issued before the revocation was accepted")
+	}
+}
@@ -2,15 +2,26 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"server/config"
 	"server/types"
+	"server/utils"
+	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	dummyPasswordHash     []byte
+	dummyPasswordHashOnce sync.Once
 )
 
 // SocialLoginWithGoogle authenticates a user with Google using an OAuth token.
@@ -57,24 +68,62 @@ func SocialLoginWithGoogle(token string) (*types.User, error) {
 
 	var user types.User
 	config.DB.Where("email=?", googleUser.Email).First(&user)
+	if user.ID == 0 {
+		user.Provider = utils.USER_PROVIDER_GOOGLE
+	}
 	user.Name = googleUser.Name
 	user.Email = googleUser.Email
-	user.Avatar = googleUser.Picture
+	// Keep avatars uploaded through PUT /user/avatar
+	if len(user.AvatarSizes) == 0 {
+		user.Avatar = googleUser.Picture
+	}
 
 	// Add or Update user data
 	config.DB.Save(&user)
 	return &user, nil
 }
 
-// VerifyJWTToken validates the JWT token from the jwtToken string param.
+// AuthenticateJWTToken validates the JWT token and loads the user it was
+// issued for. Tokens of suspended users and tokens issued before the user's
+// tokens were revoked are rejected.
 //
 // Parameters:
 //   - jwtToken: The string containing the JWT token.
 //
 // Returns:
-//   - *types.User: An User object if the token is valid.
-//   - error: An error object if there is an issue validating the token.
-func VerifyJWTToken(jwtToken string) (*types.User, error) {
+//   - *types.User: The current User object, without the password hash.
+//   - int: The workspace_id claim of the token, or 0 when it has none.
+//   - error: An error object if the token is invalid or no longer accepted.
+func AuthenticateJWTToken(jwtToken string) (*types.User, int, error) {
+	claims, err := parseJWTToken(jwtToken)
+	if err != nil {
+		return nil, 0, err
+	}
+	id, ok := claims["id"].(float64)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid token claims")
+	}
+	user, err := FetchUser(int(id))
+	if err != nil {
+		return nil, 0, fmt.Errorf("user no longer exists")
+	}
+	if user.SuspendedAt != nil {
+		return nil, 0, fmt.Errorf("user is suspended")
+	}
+	if user.TokensRevokedAt != nil {
+		issuedAt, _ := claims["iat"].(float64)
+		// iat has whole seconds, so tokens issued in the second of the
+		// revocation are rejected too.
+		if int64(issuedAt) <= user.TokensRevokedAt.Unix() {
+			return nil, 0, fmt.Errorf("token has been revoked")
+		}
+	}
+	user.Password = ""
+	workspaceId, _ := claims["workspace_id"].(float64)
+	return user, int(workspaceId), nil
+}
+
+func parseJWTToken(jwtToken string) (jwt.MapClaims, error) {
 	secret := []byte(os.Getenv("SECRET"))
 	token, err := jwt.Parse(jwtToken, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -85,33 +134,44 @@ func VerifyJWTToken(jwtToken string) (*types.User, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid or expired token")
 	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		user := &types.User{
-			ID:    int(claims["id"].(float64)),
-			Name:  claims["name"].(string),
-			Email: claims["email"].(string),
-		}
-		return user, nil
-	} else {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	if _, ok := claims["id"].(float64); !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	if _, ok := claims["name"].(string); !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	if _, ok := claims["email"].(string); !ok {
 		return nil, fmt.Errorf("invalid token claims")
 	}
+	return claims, nil
 }
 
 // CreateJWTToken creates a new JWT token for the given user.
 //
 // Parameters:
 //   - user: An User object representing the user for whom the token is being created.
+//   - workspaceUser: The membership of the active workspace, recorded in the
+//     workspace_id claim. Nil leaves it out. The role isn't recorded: it can
+//     change while the token is valid, so it is read from the membership.
 //
 // Returns:
 //   - string: The JWT token string.
 //   - error: An error object if there is an issue creating the token.
-func CreateJWTToken(user types.User) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":       user.ID,
-		"name":     user.Name,
-		"email":    user.Email,
-		"password": user.Password,
-	})
+func CreateJWTToken(user types.User, workspaceUser *types.WorkspaceUser) (string, error) {
+	claims := jwt.MapClaims{
+		"id":    user.ID,
+		"name":  user.Name,
+		"email": user.Email,
+		"iat":   time.Now().Unix(),
+	}
+	if workspaceUser != nil {
+		claims["workspace_id"] = workspaceUser.WorkspaceId
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	secret := []byte(os.Getenv("SECRET"))
 	tokenString, err := token.SignedString(secret)
 	return tokenString, err
@@ -143,3 +203,156 @@ func CheckHashPassword(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// CheckDummyPassword runs a bcrypt comparison against a throwaway hash with
+// the same cost as real password hashes. It is called when there is no
+// password to check so that the response takes as long as a failed login.
+//
+// Parameters:
+//   - password: The string representing the password that was submitted.
+func CheckDummyPassword(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), 10)
+	})
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+}
+
+// CreatePasswordResetToken issues a single-use password reset token for a user.
+//
+// Parameters:
+//   - userId: The ID of the user requesting the reset.
+//
+// Returns:
+//   - string: The plain reset token to send to the user.
+//   - error: An error object if there is an issue saving the token.
+func CreatePasswordResetToken(userId int) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	expiresAt := time.Now().Add(utils.PASSWORD_RESET_TOKEN_TTL)
+	resetToken := types.PasswordResetToken{
+		UserId:    userId,
+		TokenHash: hashToken(token),
+		ExpiresAt: &expiresAt,
+	}
+	result := config.DB.Create(&resetToken)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return token, nil
+}
+
+// ResetPassword sets a new password for the user a reset token was issued to
+// and marks the token as used.
+//
+// Parameters:
+//   - token: The plain reset token.
+//   - password: The new password.
+//
+// Returns:
+//   - error: An error object if the token is invalid, expired or already used.
+func ResetPassword(token, password string) error {
+	hashPassword, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var resetToken types.PasswordResetToken
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash=?", hashToken(token)).First(&resetToken)
+		if result.Error != nil || resetToken.UsedAt != nil || resetToken.ExpiresAt.Before(time.Now()) {
+			return fmt.Errorf("invalid or expired reset token")
+		}
+		now := time.Now()
+		if err := tx.Model(&resetToken).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&types.User{}).Where("id=?", resetToken.UserId).Updates(map[string]interface{}{
+			"password":                hashPassword,
+			"password_reset_required": false,
+		}).Error
+	})
+}
+
+// CreatePendingRegistration stores a registration that becomes a user once
+// the email address is verified.
+//
+// Parameters:
+//   - name: The name of the user.
+//   - email: The email address to verify.
+//   - hashPassword: The already hashed password.
+//
+// Returns:
+//   - string: The plain verification token to send to the email address.
+//   - error: An error object if there is an issue saving the registration.
+func CreatePendingRegistration(name, email, hashPassword string) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	expiresAt := time.Now().Add(utils.EMAIL_VERIFICATION_TOKEN_TTL)
+	registration := types.PendingRegistration{
+		Name:      name,
+		Email:     email,
+		Password:  hashPassword,
+		TokenHash: hashToken(token),
+		ExpiresAt: &expiresAt,
+	}
+	result := config.DB.Create(&registration)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return token, nil
+}
+
+// CompletePendingRegistration creates the user for a verified registration.
+// Every pending registration for the same email address is removed.
+//
+// Parameters:
+//   - token: The plain verification token.
+//
+// Returns:
+//   - *types.User: A pointer to the created user.
+//   - error: An error object if the token is invalid or the email is already registered.
+func CompletePendingRegistration(token string) (*types.User, error) {
+	var user types.User
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var registration types.PendingRegistration
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash=?", hashToken(token)).First(&registration)
+		if result.Error != nil || registration.ExpiresAt.Before(time.Now()) {
+			return fmt.Errorf("invalid or expired verification token")
+		}
+		if err := tx.Where("email=?", registration.Email).Delete(&types.PendingRegistration{}).Error; err != nil {
+			return err
+		}
+
+		result = tx.Where("email=?", registration.Email).First(&types.User{})
+		if result.Error == nil {
+			return fmt.Errorf("user with same email already exists")
+		}
+		if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return result.Error
+		}
+
+		user = types.User{
+			Name:     registration.Name,
+			Email:    registration.Email,
+			Password: registration.Password,
+			Provider: utils.USER_PROVIDER_PASSWORD,
+		}
+		return tx.Create(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GenerateCSRFToken creates a random token for double-submit CSRF protection.
+//
+// Returns:
+//   - string: The CSRF token.
+//   - error: An error object if there is an issue reading random bytes.
+func GenerateCSRFToken() (string, error) {
+	return randomToken(32)
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"server/types"
+	"testing"
+)
+
+func TestCreateJWTTokenLeavesOutSecretsAndRoles(t *testing.T) {
+	t.Setenv("SECRET", "test-secret")
+	user := types.User{ID: 1, Name: "Alice", Email: "alice@example.com", Password: "correct horse battery staple"}
+
+	token, err := CreateJWTToken(user, &types.WorkspaceUser{WorkspaceId: 3, UserId: 1, Role: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := parseJWTToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, claim := range []string{"password", "workspace_role"} {
+		if _, ok := claims[claim]; ok {
+			t.Errorf("token has a %s claim", claim)
+		}
+	}
+	if workspaceId, _ := claims["workspace_id"].(float64); workspaceId != 3 {
+		t.Errorf("workspace_id claim = %v, want 3", claims["workspace_id"])
+	}
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"fmt"
+	"log"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UploadAvatar turns an uploaded image into square thumbnails and stores them
+// in S3 under the given key prefix.
+//
+// Parameters:
+//   - keyPrefix: The S3 key prefix, e.g. "avatars/users/1".
+//   - data: The raw uploaded file.
+//
+// Returns:
+//   - string: The URL of the AVATAR_DEFAULT_SIZE thumbnail.
+//   - map[string]string: The URL of every thumbnail keyed by its edge length.
+//   - error: An error object if the file is not a supported image or the upload fails.
+func UploadAvatar(keyPrefix string, data []byte) (string, map[string]string, error) {
+	images, err := utils.ProcessAvatar(data)
+	if err != nil {
+		return "", nil, err
+	}
+	version, err := randomToken(8)
+	if err != nil {
+		return "", nil, err
+	}
+
+	avatar := ""
+	sizes := map[string]string{}
+	for _, processed := range images {
+		objectKey := fmt.Sprintf("%s/%s-%d.%s", keyPrefix, version, processed.Size, processed.Extension)
+		if err := utils.UploadBytesToS3(objectKey, processed.Data, processed.ContentType); err != nil {
+			return "", nil, err
+		}
+		url := utils.GetS3Url(objectKey)
+		sizes[strconv.Itoa(processed.Size)] = url
+		if processed.Size == utils.AVATAR_DEFAULT_SIZE {
+			avatar = url
+		}
+	}
+	return avatar, sizes, nil
+}
+
+// SaveUserAvatar stores the avatar URLs of a user and deletes the files of
+// the avatar they replace.
+//
+// Parameters:
+//   - id: An integer representing the user's unique identifier.
+//   - avatar: The URL of the default avatar thumbnail.
+//   - sizes: The URL of every thumbnail keyed by its edge length.
+//
+// Returns:
+//   - *types.User: A pointer to the updated User object.
+//   - error: An error object if there is an issue updating the user.
+func SaveUserAvatar(id int, avatar string, sizes map[string]string) (*types.User, error) {
+	var user types.User
+	var replaced []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so two uploads can't both see the same previous avatar.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+			return err
+		}
+		replaced = avatarObjectKeys(user.Avatar, user.AvatarSizes)
+		user.Avatar = avatar
+		user.AvatarSizes = sizes
+		return tx.Model(&user).Select("avatar", "avatar_sizes").Updates(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	deleteAvatarObjects(fmt.Sprintf("user %d", id), replaced)
+	return &user, nil
+}
+
+// SaveWorkspaceAvatar stores the avatar URLs of a workspace and deletes the
+// files of the avatar they replace.
+//
+// Parameters:
+//   - id: An integer representing the workspace's unique identifier.
+//   - avatar: The URL of the default avatar thumbnail.
+//   - sizes: The URL of every thumbnail keyed by its edge length.
+//
+// Returns:
+//   - *types.Workspace: A pointer to the updated Workspace object.
+//   - error: An error object if there is an issue updating the workspace.
+func SaveWorkspaceAvatar(id int, avatar string, sizes map[string]string) (*types.Workspace, error) {
+	var workspace types.Workspace
+	var replaced []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&workspace, id).Error; err != nil {
+			return err
+		}
+		replaced = avatarObjectKeys(workspace.Avatar, workspace.AvatarSizes)
+		workspace.Avatar = avatar
+		workspace.AvatarSizes = sizes
+		return tx.Model(&workspace).Select("avatar", "avatar_sizes").Updates(&workspace).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	deleteAvatarObjects(fmt.Sprintf("workspace %d", id), replaced)
+	return &workspace, nil
+}
+
+// deleteAvatarObjects removes the files of a replaced avatar once the new one
+// is saved. Failures are logged; the new avatar stays in place.
+func deleteAvatarObjects(owner string, objectKeys []string) {
+	if len(objectKeys) == 0 {
+		return
+	}
+	failed, err := utils.DeleteFromS3(objectKeys)
+	if err != nil {
+		log.Printf("avatar: failed to delete the previous avatar of %s: %v", owner, err)
+	}
+	for _, key := range failed {
+		log.Printf("avatar: file left behind for %s: %s", owner, key)
+	}
+}
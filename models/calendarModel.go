@@ -0,0 +1,338 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"slices"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrCalendarFeedNotFound = errors.New("calendar feed not found")
+
+// CreateCalendarFeed issues a calendar feed token for a user. A token issued
+// before stops working.
+//
+// Parameters:
+//   - userId: The ID of the user.
+//
+// Returns:
+//   - *types.CalendarFeedResponse: The feed URL. It is only available now.
+//   - error: An error object if there is an issue saving the feed.
+func CreateCalendarFeed(userId int) (*types.CalendarFeedResponse, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	feed := types.CalendarFeed{UserId: userId, TokenHash: hashToken(token)}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id=?", userId).Delete(&types.CalendarFeed{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&feed).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &types.CalendarFeedResponse{
+		URL:       fmt.Sprintf("%s/calendar/%s.ics", config.ServerURL(), token),
+		CreatedAt: feed.CreatedAt,
+	}, nil
+}
+
+// DeleteCalendarFeed revokes a user's calendar feed token.
+//
+// Parameters:
+//   - userId: The ID of the user.
+//
+// Returns:
+//   - error: ErrCalendarFeedNotFound, or an error object if there is an issue
+//     deleting the feed.
+func DeleteCalendarFeed(userId int) error {
+	result := config.DB.Where("user_id=?", userId).Delete(&types.CalendarFeed{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCalendarFeedNotFound
+	}
+	return nil
+}
+
+// FetchCalendarFeed renders the calendar feed a token belongs to: the
+// sessions its user created or collaborates on in the workspaces they are
+// still a member of, starting CALENDAR_FEED_PAST_WINDOW ago. When there are
+// more than CALENDAR_FEED_MAX_EVENTS, upcoming sessions are kept and the
+// oldest ones left out. Inactive sessions are listed as cancelled.
+//
+// Parameters:
+//   - ctx: The request context.
+//   - token: The feed token from the URL.
+//
+// Returns:
+//   - []byte: The iCalendar object.
+//   - error: ErrCalendarFeedNotFound when the token is unknown or its user is
+//     suspended, or an error object if there is an issue retrieving the sessions.
+func FetchCalendarFeed(ctx context.Context, token string) ([]byte, error) {
+	var feed types.CalendarFeed
+	err := config.DB.Where("token_hash=?", hashToken(token)).First(&feed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCalendarFeedNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := FetchUser(feed.UserId)
+	if err != nil || user.SuspendedAt != nil {
+		return nil, ErrCalendarFeedNotFound
+	}
+
+	var workspaceIds []int
+	err = config.DB.Table(utils.WORKSPACE_USERS_TABLE+" AS wu").
+		Joins("JOIN "+utils.WORKSPACES_TABLE+" AS w ON w.id = wu.workspace_id").
+		Where("wu.user_id = ? AND w.deleted_at IS NULL", user.ID).
+		Pluck("wu.workspace_id", &workspaceIds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Each workspace is read in its own tenant transaction, so the feed
+	// never sees more than a member of that workspace could.
+	var upcoming, past []utils.CalendarEvent
+	now := time.Now()
+	since := now.Add(-utils.CALENDAR_FEED_PAST_WINDOW)
+	for _, workspaceId := range workspaceIds {
+		tenantCtx := WithTenantUser(WithTenant(ctx, workspaceId), user.ID)
+		err := TenantTransaction(tenantCtx, func(tx *gorm.DB) error {
+			participant := func(db *gorm.DB) *gorm.DB {
+				return db.Where("created_by = ? OR id IN (?)", user.ID,
+					tx.Model(&types.SessionCollaborator{}).Select("session_id").Where("user_id = ?", user.ID))
+			}
+			var upcomingSessions, pastSessions []types.Session
+			err := tx.Scopes(participant).Where("ends_at >= ?", now).
+				Order("datetime").Limit(utils.CALENDAR_FEED_MAX_EVENTS).Find(&upcomingSessions).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Scopes(participant).Where("datetime >= ? AND ends_at < ?", since, now).
+				Order("datetime DESC").Limit(utils.CALENDAR_FEED_MAX_EVENTS).Find(&pastSessions).Error
+			if err != nil {
+				return err
+			}
+			upcomingEvents, _, err := sessionCalendarEvents(tx, upcomingSessions)
+			if err != nil {
+				return err
+			}
+			pastEvents, _, err := sessionCalendarEvents(tx, pastSessions)
+			if err != nil {
+				return err
+			}
+			upcoming = append(upcoming, upcomingEvents...)
+			past = append(past, pastEvents...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	events := calendarFeedEvents(upcoming, past)
+
+	config.DB.Model(&feed).UpdateColumn("last_used_at", time.Now())
+	return utils.BuildCalendar("Sessions", "", events), nil
+}
+
+// FetchSessionCalendar renders a single session as an iCalendar object to
+// import into a calendar app.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - id: The ID of the session.
+//   - userId: The ID of the current user, who must be its creator or a collaborator.
+//
+// Returns:
+//   - []byte: The iCalendar object.
+//   - error: ErrSessionNotFound, ErrSessionAccessDenied, or an error object
+//     if there is an issue retrieving the session.
+func FetchSessionCalendar(ctx context.Context, id, userId int) ([]byte, error) {
+	var calendar []byte
+	err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+		var session types.Session
+		if err := findSession(tx, id, userId, &session, false); err != nil {
+			return err
+		}
+		events, _, err := sessionCalendarEvents(tx, []types.Session{session})
+		if err != nil {
+			return err
+		}
+		calendar = utils.BuildCalendar("", utils.CALENDAR_METHOD_PUBLISH, events)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return calendar, nil
+}
+
+// calendarFeedEvents keeps at most CALENDAR_FEED_MAX_EVENTS events for a
+// feed: the soonest upcoming ones first, then as many of the most recent past
+// ones as still fit. They are returned in chronological order.
+func calendarFeedEvents(upcoming, past []utils.CalendarEvent) []utils.CalendarEvent {
+	sort.SliceStable(upcoming, func(i, j int) bool { return upcoming[i].Start.Before(upcoming[j].Start) })
+	if len(upcoming) > utils.CALENDAR_FEED_MAX_EVENTS {
+		upcoming = upcoming[:utils.CALENDAR_FEED_MAX_EVENTS]
+	}
+	sort.SliceStable(past, func(i, j int) bool { return past[i].Start.After(past[j].Start) })
+	if room := utils.CALENDAR_FEED_MAX_EVENTS - len(upcoming); len(past) > room {
+		past = past[:room]
+	}
+	events := append(slices.Clone(past), upcoming...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events
+}
+
+// sessionCalendarMail is a calendar email about a session, prepared inside a
+// transaction and sent once it has committed.
+type sessionCalendarMail struct {
+	event      utils.CalendarEvent
+	method     string
+	invite     bool
+	recipients []types.User
+}
+
+// newSessionCalendarMail prepares a REQUEST or CANCEL for the collaborators
+// of a session, or only for those in userIds when it isn't nil. The creator
+// organizes the event and is never mailed. An invite is sent to people who
+// want session invites, anything else to people who want session updates.
+func newSessionCalendarMail(tx *gorm.DB, session *types.Session, method string, invite bool, userIds []int) (*sessionCalendarMail, error) {
+	events, collaborators, err := sessionCalendarEvents(tx, []types.Session{*session})
+	if err != nil {
+		return nil, err
+	}
+	mail := sessionCalendarMail{event: events[0], method: method, invite: invite}
+	if method == utils.CALENDAR_METHOD_CANCEL {
+		mail.event.Cancelled = true
+	}
+	for _, user := range collaborators[session.ID] {
+		if userIds == nil || slices.Contains(userIds, user.ID) {
+			mail.recipients = append(mail.recipients, user)
+		}
+	}
+	return &mail, nil
+}
+
+func (m *sessionCalendarMail) send() {
+	if m == nil {
+		return
+	}
+	calendar := utils.BuildCalendar("", m.method, []utils.CalendarEvent{m.event})
+	subject := "Updated: " + m.event.Summary
+	if m.invite {
+		subject = "Invitation: " + m.event.Summary
+	}
+	if m.method == utils.CALENDAR_METHOD_CANCEL {
+		subject = "Cancelled: " + m.event.Summary
+	}
+
+	for _, user := range m.recipients {
+		settings := UserSettingsFor(&user)
+		if m.invite && !settings.Notifications.SessionInvites || !m.invite && !settings.Notifications.SessionUpdates {
+			continue
+		}
+		location := sessionLocation(settings.Timezone)
+		when := fmt.Sprintf("%s - %s", m.event.Start.In(location).Format("Mon, 2 Jan 2006 15:04"), m.event.End.In(location).Format("15:04 MST"))
+		var body string
+		switch {
+		case m.method == utils.CALENDAR_METHOD_CANCEL:
+			body = fmt.Sprintf("%s on %s has been cancelled or you are no longer part of it.", m.event.Summary, when)
+		case m.invite:
+			body = fmt.Sprintf("%s has added you to %s on %s.", m.event.Organizer.Name, m.event.Summary, when)
+		default:
+			body = fmt.Sprintf("%s has been updated. It now takes place on %s.", m.event.Summary, when)
+		}
+		if m.event.URL != "" {
+			body += "\n\n" + m.event.URL
+		}
+		utils.SendCalendarMailAsync(user.Email, subject, body, m.method, calendar)
+	}
+}
+
+// sessionCalendarEvents builds the calendar events of sessions of one
+// workspace. It also returns the collaborators of each session by session ID.
+func sessionCalendarEvents(tx *gorm.DB, sessions []types.Session) ([]utils.CalendarEvent, map[int][]types.User, error) {
+	events := make([]utils.CalendarEvent, 0, len(sessions))
+	collaborators := map[int][]types.User{}
+	if len(sessions) == 0 {
+		return events, collaborators, nil
+	}
+
+	sessionIds := make([]int, 0, len(sessions))
+	userIds := []int{}
+	for _, session := range sessions {
+		sessionIds = append(sessionIds, session.ID)
+		userIds = append(userIds, session.CreatedBy)
+	}
+	var rows []types.SessionCollaborator
+	if err := tx.Where("session_id IN ?", sessionIds).Order("id").Find(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, row := range rows {
+		userIds = append(userIds, row.UserId)
+	}
+	var users []types.User
+	if err := tx.Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return nil, nil, err
+	}
+	usersById := map[int]types.User{}
+	for _, user := range users {
+		usersById[user.ID] = user
+	}
+	for _, row := range rows {
+		if user, ok := usersById[row.UserId]; ok {
+			collaborators[row.SessionId] = append(collaborators[row.SessionId], user)
+		}
+	}
+
+	for _, session := range sessions {
+		event := utils.CalendarEvent{
+			UID:         sessionEventUID(session.ID),
+			Sequence:    session.Sequence,
+			Start:       session.Datetime,
+			End:         session.EndsAt,
+			Summary:     session.Title,
+			Description: session.Objective,
+			Cancelled:   !session.Status,
+			Updated:     time.Now(),
+		}
+		if session.UpdatedAt != nil {
+			event.Updated = *session.UpdatedAt
+		}
+		if appURL := utils.AppURL(); appURL != "" {
+			event.URL = fmt.Sprintf("%s/sessions/%d", appURL, session.ID)
+		}
+		if creator, ok := usersById[session.CreatedBy]; ok {
+			event.Organizer = utils.CalendarPerson{Name: creator.Name, Email: creator.Email}
+		}
+		for _, user := range collaborators[session.ID] {
+			event.Attendees = append(event.Attendees, utils.CalendarPerson{Name: user.Name, Email: user.Email})
+		}
+		events = append(events, event)
+	}
+	return events, collaborators, nil
+}
+
+// sessionEventUID is the calendar UID of a session. It never changes, so
+// updates and cancellations replace the event calendars already have.
+func sessionEventUID(sessionId int) string {
+	domain := "localhost"
+	if server, err := url.Parse(config.ServerURL()); err == nil && server.Hostname() != "" {
+		domain = server.Hostname()
+	}
+	return fmt.Sprintf("session-%d@%s", sessionId, domain)
+}
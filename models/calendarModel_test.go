@@ -0,0 +1,38 @@
+package models
+
+import (
+	"server/utils"
+	"testing"
+	"time"
+)
+
+func TestCalendarFeedEventsKeepUpcomingSessions(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var upcoming, past []utils.CalendarEvent
+	for i := 0; i < utils.CALENDAR_FEED_MAX_EVENTS; i++ {
+		past = append(past, utils.CalendarEvent{UID: "past", Start: now.Add(-time.Duration(i+1) * time.Hour)})
+	}
+	for i := 0; i < 10; i++ {
+		upcoming = append(upcoming, utils.CalendarEvent{UID: "upcoming", Start: now.Add(time.Duration(10-i) * time.Hour)})
+	}
+
+	events := calendarFeedEvents(upcoming, past)
+	if len(events) != utils.CALENDAR_FEED_MAX_EVENTS {
+		t.Fatalf("feed has %d events, want %d", len(events), utils.CALENDAR_FEED_MAX_EVENTS)
+	}
+	upcomingCount := 0
+	for i, event := range events {
+		if event.UID == "upcoming" {
+			upcomingCount++
+		}
+		if i > 0 && event.Start.Before(events[i-1].Start) {
+			t.Fatal("feed is not in chronological order")
+		}
+	}
+	if upcomingCount != 10 {
+		t.Errorf("feed kept %d of 10 upcoming sessions", upcomingCount)
+	}
+	if oldest := now.Add(-time.Duration(utils.CALENDAR_FEED_MAX_EVENTS-10) * time.Hour); !events[0].Start.Equal(oldest) {
+		t.Errorf("oldest event starts at %v, want %v", events[0].Start, oldest)
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"os"
+	"server/config"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var (
+	testDBOnce sync.Once
+	testDBErr  error
+)
+
+// testDB connects config.DB to the Postgres database in TEST_DATABASE_URL and
+// migrates it, or skips the test when it isn't set. The database should be a
+// throwaway one: tests add rows and don't remove them.
+func testDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	testDBOnce.Do(func() {
+		config.DB, testDBErr = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		if testDBErr == nil {
+			testDBErr = Migrate()
+		}
+	})
+	if testDBErr != nil {
+		t.Fatalf("failed to set up test database: %v", testDBErr)
+	}
+}
+
+// testEmail returns an email address no other test run uses.
+func testEmail(t *testing.T, name string) string {
+	t.Helper()
+	token, err := randomToken(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.ToLower(name + "-" + token + "@example.com")
+}
@@ -0,0 +1,364 @@
+package models
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"runtime/debug"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrDataExportInProgress = errors.New("a data export is already in progress")
+
+// CreateDataExport queues a personal data export for a user. A user can only
+// have one export queued or running at a time.
+//
+// Parameters:
+//   - userId: The ID of the user requesting the export.
+//
+// Returns:
+//   - *types.DataExport: The queued export.
+//   - error: ErrDataExportInProgress, or an error object if there is an issue saving the export.
+func CreateDataExport(userId int) (*types.DataExport, error) {
+	export := types.DataExport{UserId: userId, Status: utils.DATA_EXPORT_STATUS_PENDING}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the user row so concurrent requests can't both pass the check.
+		var user types.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userId).Error; err != nil {
+			return err
+		}
+		// Jobs lost to a restart would otherwise block new exports forever.
+		err := tx.Model(&types.DataExport{}).
+			Where("user_id=? AND status IN ? AND updated_at < ?", userId, []string{utils.DATA_EXPORT_STATUS_PENDING, utils.DATA_EXPORT_STATUS_PROCESSING}, time.Now().Add(-utils.DATA_EXPORT_STALE_AFTER)).
+			Updates(map[string]interface{}{"status": utils.DATA_EXPORT_STATUS_FAILED, "error": "export did not finish"}).Error
+		if err != nil {
+			return err
+		}
+		var running int64
+		err = tx.Model(&types.DataExport{}).
+			Where("user_id=? AND status IN ?", userId, []string{utils.DATA_EXPORT_STATUS_PENDING, utils.DATA_EXPORT_STATUS_PROCESSING}).
+			Count(&running).Error
+		if err != nil {
+			return err
+		}
+		if running > 0 {
+			return ErrDataExportInProgress
+		}
+		return tx.Create(&export).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// FetchDataExport fetches an export that belongs to the given user.
+//
+// Parameters:
+//   - id: The ID of the export.
+//   - userId: The ID of the user who requested it.
+//
+// Returns:
+//   - *types.DataExport: A pointer to the DataExport object if found.
+//   - error: An error object if there is an issue retrieving the export.
+func FetchDataExport(id, userId int) (*types.DataExport, error) {
+	var export types.DataExport
+	result := config.DB.Where("id=? AND user_id=?", id, userId).First(&export)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &export, nil
+}
+
+// RunDataExport builds the archive of a queued export, stores it privately in
+// S3 and marks the export completed, or failed when anything goes wrong. It is
+// meant to run in the background, so panics are recovered and fail the export
+// instead of the server.
+//
+// Parameters:
+//   - id: The ID of the queued export.
+func RunDataExport(id int) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("data export %d: panic: %v\n%s", id, r, debug.Stack())
+			failDataExport(id, fmt.Sprintf("panic: %v", r))
+		}
+	}()
+
+	var export types.DataExport
+	if err := config.DB.First(&export, id).Error; err != nil {
+		fmt.Println(err)
+		return
+	}
+	config.DB.Model(&export).Update("status", utils.DATA_EXPORT_STATUS_PROCESSING)
+
+	objectKey, err := storeDataExport(&export)
+	if err != nil {
+		fmt.Println(err)
+		failDataExport(id, err.Error())
+		return
+	}
+
+	now := time.Now()
+	result := config.DB.Model(&export).Updates(map[string]interface{}{
+		"status":       utils.DATA_EXPORT_STATUS_COMPLETED,
+		"object_key":   objectKey,
+		"completed_at": now,
+		"expires_at":   now.Add(utils.DATA_EXPORT_TTL),
+	})
+	if result.Error == nil && result.RowsAffected == 0 {
+		// The user was deleted while the archive was built.
+		if _, err := utils.DeleteFromS3([]string{objectKey}); err != nil {
+			log.Printf("data export %d: failed to delete the archive of a deleted user: %v", id, err)
+		}
+	}
+}
+
+func failDataExport(id int, message string) {
+	config.DB.Model(&types.DataExport{ID: id}).Updates(map[string]interface{}{
+		"status": utils.DATA_EXPORT_STATUS_FAILED,
+		"error":  message,
+	})
+}
+
+// RunDataExportCleanup deletes the archives of expired exports, then again
+// every interval. It never returns and is meant to run in the background.
+//
+// Parameters:
+//   - interval: The time between two cleanups.
+func RunDataExportCleanup(interval time.Duration) {
+	for {
+		DeleteExpiredDataExports()
+		time.Sleep(interval)
+	}
+}
+
+// DeleteExpiredDataExports removes the archives of expired exports from S3
+// and forgets their object keys. Failures are logged and retried on the next
+// run.
+func DeleteExpiredDataExports() {
+	var exports []types.DataExport
+	err := config.DB.Select("id", "object_key").
+		Where("object_key <> '' AND expires_at <= ?", time.Now()).
+		Order("id").
+		Find(&exports).Error
+	if err != nil {
+		log.Printf("data export cleanup: failed to list expired exports: %v", err)
+		return
+	}
+	if len(exports) == 0 {
+		return
+	}
+	objectKeys := make([]string, 0, len(exports))
+	for _, export := range exports {
+		objectKeys = append(objectKeys, export.ObjectKey)
+	}
+	failed, err := utils.DeleteFromS3(objectKeys)
+	if err != nil {
+		log.Printf("data export cleanup: failed to delete archives: %v", err)
+	}
+	if len(failed) > 0 {
+		log.Printf("data export cleanup: could not delete %d archives: %v", len(failed), failed)
+	}
+
+	kept := map[string]bool{}
+	for _, key := range failed {
+		kept[key] = true
+	}
+	var ids []int
+	for _, export := range exports {
+		if !kept[export.ObjectKey] {
+			ids = append(ids, export.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := config.DB.Model(&types.DataExport{}).Where("id IN ?", ids).Update("object_key", "").Error; err != nil {
+		log.Printf("data export cleanup: failed to update exports: %v", err)
+		return
+	}
+	log.Printf("data export cleanup: deleted %d expired archives", len(ids))
+}
+
+// DataExportDownloadURL returns a short-lived link to a completed export, or
+// an empty string when the export isn't ready or has expired.
+//
+// Parameters:
+//   - export: The export to link to.
+//
+// Returns:
+//   - string: The download link.
+//   - error: An error object if there is an issue signing the link.
+func DataExportDownloadURL(export *types.DataExport) (string, error) {
+	if export.Status != utils.DATA_EXPORT_STATUS_COMPLETED || export.ExpiresAt == nil {
+		return "", nil
+	}
+	remaining := time.Until(*export.ExpiresAt)
+	if remaining <= 0 {
+		return "", nil
+	}
+	return utils.PresignS3Download(export.ObjectKey, path.Base(export.ObjectKey), min(remaining, utils.DATA_EXPORT_LINK_TTL))
+}
+
+// storeDataExport streams the archive of an export to S3 while it is being
+// written, so neither the archive nor the attachments are held in memory.
+func storeDataExport(export *types.DataExport) (string, error) {
+	suffix, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	objectKey := fmt.Sprintf("exports/users/%d/export-%d-%s.zip", export.UserId, export.ID, suffix)
+
+	reader, writer := io.Pipe()
+	go func() {
+		// A panic here would not reach the recover in RunDataExport.
+		defer func() {
+			if r := recover(); r != nil {
+				writer.CloseWithError(fmt.Errorf("panic: %v", r))
+			}
+		}()
+		writer.CloseWithError(writeDataExportArchive(writer, export.UserId))
+	}()
+	err = utils.UploadPrivateMultipartToS3(objectKey, reader, "application/zip")
+	// Unblocks the archive writer when the upload stopped early.
+	reader.CloseWithError(err)
+	if err != nil {
+		return "", err
+	}
+	return objectKey, nil
+}
+
+// writeDataExportArchive writes everything tied to a user to w as a ZIP of
+// JSON files plus the original attachment files.
+func writeDataExportArchive(w io.Writer, userId int) error {
+	user, err := FetchUser(userId)
+	if err != nil {
+		return err
+	}
+	// Shadow the password hash so it is left out of the archive.
+	exportedUser := struct {
+		*types.User
+		Password string `json:"password,omitempty"`
+	}{User: user}
+
+	settings, err := FetchUserSettings(userId)
+	if err != nil {
+		return err
+	}
+
+	workspaces := []types.DataExportWorkspace{}
+	err = config.DB.Table(utils.WORKSPACES_TABLE+" AS w").
+		Select("w.*, wu.is_owner, wu.is_primary, wu.created_at AS joined_at").
+		Joins("JOIN "+utils.WORKSPACE_USERS_TABLE+" AS wu ON wu.workspace_id = w.id").
+		Where("wu.user_id = ?", userId).
+		Order("w.id").
+		Scan(&workspaces).Error
+	if err != nil {
+		return err
+	}
+
+	// The user's sessions span workspaces.
+	collaborations := []types.SessionCollaborator{}
+	sessions := []types.Session{}
+	var sessionAttachments []types.SessionAttachment
+	err = SystemTransaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id=?", userId).Order("id").Find(&collaborations).Error; err != nil {
+			return err
+		}
+		err := tx.Where("created_by = ? OR id IN (?)", userId,
+			tx.Model(&types.SessionCollaborator{}).Select("session_id").Where("user_id=?", userId)).
+			Order("id").Find(&sessions).Error
+		if err != nil {
+			return err
+		}
+		sessionIds := make([]int, 0, len(sessions))
+		for _, session := range sessions {
+			sessionIds = append(sessionIds, session.ID)
+		}
+		if len(sessionIds) == 0 {
+			return nil
+		}
+		return tx.Where("session_id IN ?", sessionIds).Order("id").Find(&sessionAttachments).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	attachments := make([]types.DataExportAttachment, 0, len(sessionAttachments))
+	for _, attachment := range sessionAttachments {
+		exported := types.DataExportAttachment{SessionAttachment: attachment}
+		if objectKey, ok := utils.S3KeyFromUrl(attachment.Url); ok {
+			exported.File = fmt.Sprintf("attachments/%d/%d-%s", attachment.SessionId, attachment.ID, path.Base(objectKey))
+			if err := copyAttachmentToZip(archive, exported.File, objectKey); err != nil {
+				return fmt.Errorf("failed to export attachment %d: %w", attachment.ID, err)
+			}
+		}
+		attachments = append(attachments, exported)
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"user.json", exportedUser},
+		{"settings.json", settings},
+		{"workspaces.json", workspaces},
+		{"sessions.json", sessions},
+		{"session_collaborations.json", collaborations},
+		{"attachments.json", attachments},
+	}
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeZipFile(archive, file.name, data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func writeZipFile(archive *zip.Writer, name string, data []byte) error {
+	writer, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// copyAttachmentToZip streams a file from S3 into the archive. Files larger
+// than DATA_EXPORT_MAX_ATTACHMENT_BYTES fail the export.
+func copyAttachmentToZip(archive *zip.Writer, name, objectKey string) error {
+	file, err := utils.OpenFromS3(objectKey)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(writer, io.LimitReader(file, utils.DATA_EXPORT_MAX_ATTACHMENT_BYTES+1))
+	if err != nil {
+		return err
+	}
+	if written > utils.DATA_EXPORT_MAX_ATTACHMENT_BYTES {
+		return fmt.Errorf("file is larger than %d bytes", utils.DATA_EXPORT_MAX_ATTACHMENT_BYTES)
+	}
+	return nil
+}
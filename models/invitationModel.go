@@ -0,0 +1,248 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvitationPending = errors.New("an invitation for this email is already pending")
+var ErrInvalidInvitation = errors.New("invalid or expired invitation")
+var ErrInvitationEmailMismatch = errors.New("invitation was sent to a different email address")
+
+// CreateWorkspaceInvitation invites an email address to a workspace and
+// emails the invitation link.
+//
+// Parameters:
+//   - workspace: The workspace to invite to.
+//   - inviter: The user sending the invitation.
+//   - email: The email address to invite.
+//   - role: The role the invitee gets on accepting.
+//
+// Returns:
+//   - *types.WorkspaceInvitation: The new invitation.
+//   - error: ErrAlreadyWorkspaceMember, ErrInvitationPending, a
+//     *QuotaExceededError when the plan has no seats left, or an error object
+//     if there is an issue saving the invitation.
+func CreateWorkspaceInvitation(workspace *types.Workspace, inviter *types.User, email, role string) (*types.WorkspaceInvitation, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	var invitation types.WorkspaceInvitation
+	var token string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockWorkspace(tx, workspace.ID); err != nil {
+			return err
+		}
+
+		var members int64
+		err := tx.Model(&types.WorkspaceUser{}).
+			Joins("JOIN "+utils.USERS_TABLE+" ON "+utils.USERS_TABLE+".id = "+utils.WORKSPACE_USERS_TABLE+".user_id").
+			Where(utils.WORKSPACE_USERS_TABLE+".workspace_id = ? AND lower("+utils.USERS_TABLE+".email) = ?", workspace.ID, email).
+			Count(&members).Error
+		if err != nil {
+			return err
+		}
+		if members > 0 {
+			return ErrAlreadyWorkspaceMember
+		}
+
+		var pending int64
+		if err := pendingInvitations(tx.Model(&types.WorkspaceInvitation{}), workspace.ID).Where("email=?", email).Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrInvitationPending
+		}
+		if err := checkSeatAvailable(tx, workspace.ID, 0); err != nil {
+			return err
+		}
+
+		token, err = randomToken(32)
+		if err != nil {
+			return err
+		}
+		expiresAt := time.Now().Add(utils.WORKSPACE_INVITATION_TTL)
+		invitation = types.WorkspaceInvitation{
+			WorkspaceId: workspace.ID,
+			Email:       email,
+			Role:        role,
+			InvitedBy:   inviter.ID,
+			TokenHash:   hashToken(token),
+			ExpiresAt:   &expiresAt,
+		}
+		return tx.Create(&invitation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sendWorkspaceInvitationEmail(&invitation, workspace, inviter, token)
+	return &invitation, nil
+}
+
+// FetchPendingInvitations lists the invitations of a workspace that can
+// still be accepted.
+//
+// Parameters:
+//   - workspaceId: The ID of the workspace.
+//
+// Returns:
+//   - []types.WorkspaceInvitation: The pending invitations, newest first.
+//   - error: An error object if there is an issue retrieving the invitations.
+func FetchPendingInvitations(workspaceId int) ([]types.WorkspaceInvitation, error) {
+	invitations := []types.WorkspaceInvitation{}
+	result := pendingInvitations(config.DB, workspaceId).Order("created_at DESC, id DESC").Find(&invitations)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return invitations, nil
+}
+
+// ResendWorkspaceInvitation replaces the token of a pending invitation,
+// extends its expiry and emails the new link. The old link stops working.
+//
+// Parameters:
+//   - workspace: The workspace of the invitation.
+//   - inviter: The user resending the invitation.
+//   - id: The ID of the invitation.
+//
+// Returns:
+//   - *types.WorkspaceInvitation: The updated invitation.
+//   - error: ErrInvalidInvitation when it was accepted or revoked, or an
+//     error object if there is an issue saving the invitation.
+func ResendWorkspaceInvitation(workspace *types.Workspace, inviter *types.User, id int) (*types.WorkspaceInvitation, error) {
+	var invitation types.WorkspaceInvitation
+	var token string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockInvitation(tx, workspace.ID, id, &invitation); err != nil {
+			return err
+		}
+		var err error
+		token, err = randomToken(32)
+		if err != nil {
+			return err
+		}
+		expiresAt := time.Now().Add(utils.WORKSPACE_INVITATION_TTL)
+		invitation.TokenHash = hashToken(token)
+		invitation.ExpiresAt = &expiresAt
+		return tx.Model(&invitation).Select("token_hash", "expires_at").Updates(&invitation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sendWorkspaceInvitationEmail(&invitation, workspace, inviter, token)
+	return &invitation, nil
+}
+
+// RevokeWorkspaceInvitation cancels an invitation that hasn't been accepted.
+//
+// Parameters:
+//   - workspaceId: The ID of the workspace.
+//   - id: The ID of the invitation.
+//
+// Returns:
+//   - error: ErrInvalidInvitation when it was accepted or revoked, or an
+//     error object if there is an issue saving the invitation.
+func RevokeWorkspaceInvitation(workspaceId, id int) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var invitation types.WorkspaceInvitation
+		if err := lockInvitation(tx, workspaceId, id, &invitation); err != nil {
+			return err
+		}
+		return tx.Model(&invitation).Update("revoked_at", time.Now()).Error
+	})
+}
+
+// AcceptWorkspaceInvitation adds a user to the workspace of an invitation.
+// The user's email must be the one the invitation was sent to.
+//
+// Parameters:
+//   - token: The plain invitation token.
+//   - user: The user accepting the invitation.
+//
+// Returns:
+//   - *types.WorkspaceUser: The user's membership in the workspace.
+//   - error: ErrInvalidInvitation, ErrInvitationEmailMismatch,
+//     ErrWorkspaceArchived, a *QuotaExceededError when the plan has no seats left, or an error object
+//     if there is an issue saving the membership.
+func AcceptWorkspaceInvitation(token string, user *types.User) (*types.WorkspaceUser, error) {
+	var workspaceUser *types.WorkspaceUser
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var invitation types.WorkspaceInvitation
+		result := tx.Where("token_hash=?", hashToken(token)).First(&invitation)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return ErrInvalidInvitation
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if err := lockInvitation(tx, invitation.WorkspaceId, invitation.ID, &invitation); err != nil {
+			return err
+		}
+		if invitation.ExpiresAt.Before(time.Now()) {
+			return ErrInvalidInvitation
+		}
+		if !strings.EqualFold(invitation.Email, strings.TrimSpace(user.Email)) {
+			return ErrInvitationEmailMismatch
+		}
+		var workspace types.Workspace
+		if err := tx.Select("id", "archived_at").First(&workspace, invitation.WorkspaceId).Error; err != nil {
+			return err
+		}
+		if workspace.ArchivedAt != nil {
+			return ErrWorkspaceArchived
+		}
+
+		var err error
+		workspaceUser, err = addWorkspaceMember(tx, invitation.WorkspaceId, user.ID, invitation.Role, invitation.ID)
+		if errors.Is(err, ErrAlreadyWorkspaceMember) {
+			workspaceUser = &types.WorkspaceUser{}
+			err = tx.Where("workspace_id=? AND user_id=?", invitation.WorkspaceId, user.ID).First(workspaceUser).Error
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&invitation).Updates(map[string]interface{}{
+			"accepted_at": time.Now(),
+			"accepted_by": user.ID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return workspaceUser, nil
+}
+
+// pendingInvitations filters to invitations that are neither accepted,
+// revoked nor expired.
+func pendingInvitations(db *gorm.DB, workspaceId int) *gorm.DB {
+	return db.Where("workspace_id=? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", workspaceId, time.Now())
+}
+
+// lockInvitation locks the workspace and loads an invitation that hasn't been
+// accepted or revoked.
+func lockInvitation(tx *gorm.DB, workspaceId, id int, invitation *types.WorkspaceInvitation) error {
+	if err := lockWorkspace(tx, workspaceId); err != nil {
+		return err
+	}
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id=? AND workspace_id=? AND accepted_at IS NULL AND revoked_at IS NULL", id, workspaceId).
+		First(invitation)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return ErrInvalidInvitation
+	}
+	return result.Error
+}
+
+func sendWorkspaceInvitationEmail(invitation *types.WorkspaceInvitation, workspace *types.Workspace, inviter *types.User, token string) {
+	utils.SendMailAsync(invitation.Email, fmt.Sprintf("%s invited you to %s", inviter.Name, workspace.Name),
+		fmt.Sprintf("%s has invited you to join the %s workspace. Use the link below to accept. It expires in 7 days.\n\n%s/invitations/accept?token=%s\n\nIf you don't have an account yet you can create one from the same link.",
+			inviter.Name, workspace.Name, utils.AppURL(), token))
+}
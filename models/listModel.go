@@ -0,0 +1,182 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"server/types"
+	"server/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidCursor = errors.New("invalid or outdated cursor")
+
+// listCursor is the position after the last row of a page: the values of its
+// sort fields, and the sort order they belong to.
+type listCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// ListPage runs a list query with keyset pagination. It applies the filters
+// on fields that have a column, sorts with id as the final tie-breaker and
+// continues after the query's cursor.
+//
+// Parameters:
+//   - db: The base query, already limited to the rows the user may see.
+//   - query: The parsed list query.
+//   - spec: The fields of the endpoint. It must include an "id" field.
+//   - values: Returns the value of a field for a row, used for the next cursor.
+//
+// Returns:
+//   - []T: The rows of the page.
+//   - string: The cursor of the next page, or an empty string on the last page.
+//   - error: ErrInvalidCursor, or an error object if there is an issue running the query.
+func ListPage[T any](db *gorm.DB, query types.ListQuery, spec types.ListQuerySpec, values func(row *T, field string) interface{}) ([]T, string, error) {
+	for _, filter := range query.Filters {
+		column := spec.Fields[filter.Field].Column
+		if column == "" {
+			continue
+		}
+		switch filter.Op {
+		case utils.LIST_OP_GTE:
+			db = db.Where(column+" >= ?", filter.Value)
+		case utils.LIST_OP_LT:
+			db = db.Where(column+" < ?", filter.Value)
+		default:
+			db = db.Where(column+" = ?", filter.Value)
+		}
+	}
+
+	sorts := query.Sort
+	if len(sorts) == 0 || sorts[len(sorts)-1].Field != "id" {
+		sorts = append(append([]types.ListSort{}, sorts...), types.ListSort{Field: "id", Desc: len(sorts) > 0 && sorts[len(sorts)-1].Desc})
+	}
+	signature := listSortSignature(sorts)
+
+	if query.Cursor != "" {
+		cursor, err := decodeListCursor(query.Cursor, signature, sorts, spec)
+		if err != nil {
+			return nil, "", err
+		}
+		db = db.Where(keysetCondition(sorts, cursor.Values, spec))
+	}
+
+	orderBy := clause.OrderBy{}
+	for _, sort := range sorts {
+		orderBy.Columns = append(orderBy.Columns, clause.OrderByColumn{Column: clause.Column{Name: spec.Fields[sort.Field].Column}, Desc: sort.Desc})
+	}
+
+	var rows []T
+	if err := db.Clauses(orderBy).Limit(query.Limit + 1).Find(&rows).Error; err != nil {
+		return nil, "", err
+	}
+	if len(rows) <= query.Limit {
+		return rows, "", nil
+	}
+
+	rows = rows[:query.Limit]
+	next := listCursor{Sort: signature}
+	for _, sort := range sorts {
+		next.Values = append(next.Values, values(&rows[len(rows)-1], sort.Field))
+	}
+	cursor, err := encodeListCursor(next)
+	if err != nil {
+		return nil, "", err
+	}
+	return rows, cursor, nil
+}
+
+// keysetCondition matches the rows that sort after the cursor values:
+// (a > ?) OR (a = ? AND b > ?) OR ..., with < for descending fields.
+func keysetCondition(sorts []types.ListSort, values []interface{}, spec types.ListQuerySpec) clause.Expr {
+	var sql strings.Builder
+	var vars []interface{}
+	for i, sort := range sorts {
+		if i > 0 {
+			sql.WriteString(" OR ")
+		}
+		sql.WriteString("(")
+		for j := 0; j < i; j++ {
+			sql.WriteString(spec.Fields[sorts[j].Field].Column + " = ? AND ")
+			vars = append(vars, values[j])
+		}
+		op := " > ?"
+		if sort.Desc {
+			op = " < ?"
+		}
+		sql.WriteString(spec.Fields[sort.Field].Column + op + ")")
+		vars = append(vars, values[i])
+	}
+	return clause.Expr{SQL: "(" + sql.String() + ")", Vars: vars}
+}
+
+func encodeListCursor(cursor listCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeListCursor(raw, signature string, sorts []types.ListSort, spec types.ListQuerySpec) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor listCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	// A cursor only makes sense for the sort order it was made for.
+	if cursor.Sort != signature || len(cursor.Values) != len(sorts) {
+		return nil, ErrInvalidCursor
+	}
+	for i, sort := range sorts {
+		value, ok := listCursorValue(spec.Fields[sort.Field].Type, cursor.Values[i])
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		cursor.Values[i] = value
+	}
+	return &cursor, nil
+}
+
+// listCursorValue turns a value decoded from JSON back into the type of its
+// field.
+func listCursorValue(fieldType string, value interface{}) (interface{}, bool) {
+	switch fieldType {
+	case utils.LIST_FIELD_INT:
+		number, ok := value.(float64)
+		return int64(number), ok
+	case utils.LIST_FIELD_BOOL:
+		flag, ok := value.(bool)
+		return flag, ok
+	case utils.LIST_FIELD_TIME:
+		text, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, text)
+		return parsed, err == nil
+	default:
+		text, ok := value.(string)
+		return text, ok
+	}
+}
+
+func listSortSignature(sorts []types.ListSort) string {
+	parts := make([]string, 0, len(sorts))
+	for _, sort := range sorts {
+		if sort.Desc {
+			parts = append(parts, "-"+sort.Field)
+		} else {
+			parts = append(parts, sort.Field)
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"server/config"
+	"server/types"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestKeysetCondition(t *testing.T) {
+	sorts := []types.ListSort{{Field: "datetime", Desc: true}, {Field: "stage"}, {Field: "id", Desc: true}}
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	expr := keysetCondition(sorts, []interface{}{at, "review", int64(4)}, SessionListSpec)
+
+	wantSQL := "((datetime < ?) OR (datetime = ? AND stage > ?) OR (datetime = ? AND stage = ? AND id < ?))"
+	if expr.SQL != wantSQL {
+		t.Errorf("SQL = %s, want %s", expr.SQL, wantSQL)
+	}
+	wantVars := []interface{}{at, at, "review", at, "review", int64(4)}
+	if !reflect.DeepEqual(expr.Vars, wantVars) {
+		t.Errorf("vars = %v, want %v", expr.Vars, wantVars)
+	}
+}
+
+func TestListCursorRoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 1, 9, 0, 0, 123456000, time.UTC)
+	tests := []struct {
+		name   string
+		sorts  []types.ListSort
+		values []interface{}
+		want   []interface{}
+	}{
+		{
+			name:   "descending time, ascending string",
+			sorts:  []types.ListSort{{Field: "datetime", Desc: true}, {Field: "stage"}, {Field: "id"}},
+			values: []interface{}{at, "review", 12},
+			want:   []interface{}{at, "review", int64(12)},
+		},
+		{
+			name:   "ascending int, descending time",
+			sorts:  []types.ListSort{{Field: "created_by"}, {Field: "created_at", Desc: true}, {Field: "id", Desc: true}},
+			values: []interface{}{3, &at, 40},
+			want:   []interface{}{int64(3), at, int64(40)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signature := listSortSignature(test.sorts)
+			raw, err := encodeListCursor(listCursor{Sort: signature, Values: test.values})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cursor, err := decodeListCursor(raw, signature, test.sorts, SessionListSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range test.want {
+				if wantTime, ok := want.(time.Time); ok {
+					if got, _ := cursor.Values[i].(time.Time); !got.Equal(wantTime) {
+						t.Errorf("value %d = %v, want %v", i, cursor.Values[i], want)
+					}
+				} else if cursor.Values[i] != want {
+					t.Errorf("value %d = %#v, want %#v", i, cursor.Values[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeListCursorRejectsInvalidCursors(t *testing.T) {
+	sorts := []types.ListSort{{Field: "datetime", Desc: true}, {Field: "id", Desc: true}}
+	signature := listSortSignature(sorts)
+	encode := func(cursor listCursor) string {
+		raw, err := encodeListCursor(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	at := "2026-03-01T09:00:00Z"
+
+	tests := map[string]string{
+		"not base64":          "%%%",
+		"not json":            base64.RawURLEncoding.EncodeToString([]byte("{")),
+		"other direction":     encode(listCursor{Sort: "datetime,id", Values: []interface{}{at, 1}}),
+		"other fields":        encode(listCursor{Sort: "-created_at,-id", Values: []interface{}{at, 1}}),
+		"too few values":      encode(listCursor{Sort: signature, Values: []interface{}{at}}),
+		"too many values":     encode(listCursor{Sort: signature, Values: []interface{}{at, 1, 2}}),
+		"wrong value type":    encode(listCursor{Sort: signature, Values: []interface{}{at, "1"}}),
+		"invalid time":        encode(listCursor{Sort: signature, Values: []interface{}{"yesterday", 1}}),
+		"time is not text":    encode(listCursor{Sort: signature, Values: []interface{}{1, 1}}),
+		"missing sort values": encode(listCursor{Sort: signature}),
+	}
+	for name, raw := range tests {
+		if _, err := decodeListCursor(raw, signature, sorts, SessionListSpec); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: error = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
+
+func TestFetchSessionsPagesThroughMixedSorts(t *testing.T) {
+	testDB(t)
+
+	user := types.User{Name: "Pager", Email: testEmail(t, "pager")}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	workspace := types.Workspace{Name: "Paging", Role: "test", Status: true}
+	if err := config.DB.Create(&workspace).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Create(&types.WorkspaceUser{WorkspaceId: workspace.ID, UserId: user.ID, IsOwner: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Repeated stages and times so the later sort fields and the id
+	// tie-breaker decide the order.
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	var sessions []types.Session
+	for i, stage := range []string{"review", "draft", "review", "draft", "review", "final", "draft"} {
+		at := start.Add(time.Duration(i%3) * time.Hour)
+		sessions = append(sessions, types.Session{Title: "Session", Stage: stage, WorkspaceId: workspace.ID, CreatedBy: user.ID,
+			Status: true, Datetime: at, EndsAt: at.Add(time.Hour), DurationMinutes: 60})
+	}
+	if err := config.DB.Create(&sessions).Error; err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithTenant(context.Background(), workspace.ID)
+
+	tests := []struct {
+		sort []types.ListSort
+		less func(a, b types.Session) bool
+	}{
+		{
+			sort: []types.ListSort{{Field: "stage"}, {Field: "datetime", Desc: true}},
+			less: func(a, b types.Session) bool {
+				if a.Stage != b.Stage {
+					return a.Stage < b.Stage
+				}
+				if !a.Datetime.Equal(b.Datetime) {
+					return a.Datetime.After(b.Datetime)
+				}
+				return a.ID > b.ID
+			},
+		},
+		{
+			sort: []types.ListSort{{Field: "datetime", Desc: true}, {Field: "stage"}},
+			less: func(a, b types.Session) bool {
+				if !a.Datetime.Equal(b.Datetime) {
+					return a.Datetime.After(b.Datetime)
+				}
+				if a.Stage != b.Stage {
+					return a.Stage < b.Stage
+				}
+				return a.ID < b.ID
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(listSortSignature(test.sort), func(t *testing.T) {
+			expected := append([]types.Session{}, sessions...)
+			sort.Slice(expected, func(i, j int) bool { return test.less(expected[i], expected[j]) })
+			var want []int
+			for _, session := range expected {
+				want = append(want, session.ID)
+			}
+
+			var got []int
+			query := types.ListQuery{Sort: test.sort, Limit: 2}
+			for page := 0; ; page++ {
+				if page > len(sessions) {
+					t.Fatal("pagination did not end")
+				}
+				response, err := FetchSessions(ctx, user.ID, query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, session := range response.Sessions {
+					got = append(got, session.ID)
+				}
+				if response.NextCursor == "" {
+					break
+				}
+				query.Cursor = response.NextCursor
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ids = %v, want %v", got, want)
+			}
+		})
+	}
+
+	first, err := FetchSessions(ctx, user.ID, types.ListQuery{Sort: tests[0].sort, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := types.ListQuery{Sort: tests[1].sort, Limit: 2, Cursor: first.NextCursor}
+	if _, err := FetchSessions(ctx, user.ID, replayed); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor replayed under another sort: error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestListSortSignature(t *testing.T) {
+	sorts := []types.ListSort{{Field: "datetime", Desc: true}, {Field: "stage"}, {Field: "id", Desc: true}}
+	if got := listSortSignature(sorts); got != "-datetime,stage,-id" {
+		t.Errorf("signature = %q", got)
+	}
+}
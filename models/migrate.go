@@ -0,0 +1,99 @@
+package models
+
+import (
+	"log"
+	"server/config"
+	"server/types"
+	"server/utils"
+)
+
+// Migrate creates or updates the tables for every model the server owns.
+//
+// Returns:
+//   - error: An error object if there is an issue migrating the schema.
+func Migrate() error {
+	trigramSearch = enableTrigramSearch()
+
+	if err := migrateSessionSchedule(); err != nil {
+		return err
+	}
+
+	err := config.DB.AutoMigrate(
+		&types.User{},
+		&types.Workspace{},
+		&types.WorkspaceUser{},
+		&types.Session{},
+		&types.SessionAttachment{},
+		&types.SessionCollaborator{},
+		&types.OAuthClient{},
+		&types.OAuthAuthorizationCode{},
+		&types.OAuthRefreshToken{},
+		&types.OAuthConsent{},
+		&types.OAuthSigningKey{},
+		&types.SAMLAssertionReplay{},
+		&types.SAMLPendingRequest{},
+		&types.PasswordResetToken{},
+		&types.PendingRegistration{},
+		&types.DataExport{},
+		&types.WorkspaceInvitation{},
+		&types.WorkspaceUsage{},
+		&types.CalendarFeed{},
+	)
+	if err != nil {
+		return err
+	}
+	if err := dropRefreshTokenNonce(); err != nil {
+		return err
+	}
+
+	if trigramSearch {
+		indexes := []string{
+			"CREATE INDEX IF NOT EXISTS idx_users_name_trgm ON " + utils.USERS_TABLE + " USING gin (name gin_trgm_ops)",
+			"CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON " + utils.USERS_TABLE + " USING gin (email gin_trgm_ops)",
+		}
+		for _, index := range indexes {
+			if err := config.DB.Exec(index).Error; err != nil {
+				return err
+			}
+		}
+	}
+	if err := backfillSessionEnds(); err != nil {
+		return err
+	}
+	if err := backfillWorkspaceUsage(); err != nil {
+		return err
+	}
+	return applyRowLevelSecurity()
+}
+
+// dropRefreshTokenNonce removes the nonce column early versions of the OIDC
+// provider stored on refresh tokens. AutoMigrate never drops columns, and
+// refreshed ID tokens no longer carry a nonce.
+func dropRefreshTokenNonce() error {
+	migrator := config.DB.Migrator()
+	if !migrator.HasColumn(&types.OAuthRefreshToken{}, "nonce") {
+		return nil
+	}
+	return migrator.DropColumn(&types.OAuthRefreshToken{}, "nonce")
+}
+
+// trigramSearch is set when the pg_trgm extension is available, see
+// enableTrigramSearch.
+var trigramSearch bool
+
+// enableTrigramSearch makes sure pg_trgm, which backs the fuzzy user search,
+// is installed. Creating an extension needs more privileges than the server
+// usually has, so a missing extension is logged and user search falls back to
+// substring matches instead of failing the startup.
+func enableTrigramSearch() bool {
+	var installed bool
+	err := config.DB.Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')").Scan(&installed).Error
+	if err == nil && installed {
+		return true
+	}
+	if err := config.DB.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		log.Printf("pg_trgm is not installed and could not be created, user search only matches substrings: %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,731 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OAuthError is returned by the OAuth/OIDC model functions. Code is one of the
+// error codes defined by RFC 6749 so controllers can pass it straight through
+// to the client.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+func newOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+var (
+	signingKeyCache   = map[string]*rsa.PrivateKey{}
+	signingKeyCacheMu sync.RWMutex
+)
+
+// OIDCIssuer returns the issuer identifier configured through OIDC_ISSUER
+// without a trailing slash.
+func OIDCIssuer() string {
+	return strings.TrimRight(os.Getenv("OIDC_ISSUER"), "/")
+}
+
+// RegisterOAuthClient creates a new OAuth client owned by the given user.
+//
+// Parameters:
+//   - payload: The client registration payload.
+//   - userId: The ID of the user registering the client.
+//
+// Returns:
+//   - *types.OAuthClient: A pointer to the created client.
+//   - string: The plain client secret, empty for public clients. It is only available at creation time.
+//   - error: An error object if there is an issue saving the client.
+func RegisterOAuthClient(payload types.OAuthClientPayload, userId int) (*types.OAuthClient, string, error) {
+	clientId, err := randomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+	client := types.OAuthClient{
+		ClientId:     clientId,
+		Name:         payload.Name,
+		RedirectURIs: strings.Join(payload.RedirectURIs, " "),
+		Scopes:       strings.Join(utils.OAUTH_SUPPORTED_SCOPES, " "),
+		IsPublic:     payload.IsPublic,
+		CreatedBy:    userId,
+		Status:       true,
+	}
+
+	var secret string
+	if !payload.IsPublic {
+		secret, err = randomToken(32)
+		if err != nil {
+			return nil, "", err
+		}
+		client.ClientSecret, err = HashPassword(secret)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	result := config.DB.Create(&client)
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+	return &client, secret, nil
+}
+
+// FetchOAuthClient fetches an active OAuth client by its public client ID.
+//
+// Parameters:
+//   - clientId: The client identifier.
+//
+// Returns:
+//   - *types.OAuthClient: A pointer to the client if found.
+//   - error: An error object if there is an issue retrieving the client.
+func FetchOAuthClient(clientId string) (*types.OAuthClient, error) {
+	var client types.OAuthClient
+	result := config.DB.Where("client_id=? AND status=?", clientId, true).First(&client)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &client, nil
+}
+
+// FetchOAuthClientsByUser fetches the OAuth clients registered by a user.
+//
+// Parameters:
+//   - userId: The ID of the user.
+//
+// Returns:
+//   - []types.OAuthClient: The clients registered by the user.
+//   - error: An error object if there is an issue retrieving the clients.
+func FetchOAuthClientsByUser(userId int) ([]types.OAuthClient, error) {
+	var clients []types.OAuthClient
+	result := config.DB.Where("created_by=? AND status=?", userId, true).Order("id").Find(&clients)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return clients, nil
+}
+
+// AuthenticateOAuthClient resolves the client for a token request. Confidential
+// clients must present their secret, public clients must not have one.
+//
+// Parameters:
+//   - clientId: The client identifier.
+//   - clientSecret: The client secret, empty for public clients.
+//
+// Returns:
+//   - *types.OAuthClient: A pointer to the authenticated client.
+//   - error: An *OAuthError with code invalid_client if authentication fails.
+func AuthenticateOAuthClient(clientId, clientSecret string) (*types.OAuthClient, error) {
+	client, err := FetchOAuthClient(clientId)
+	if err != nil {
+		return nil, newOAuthError("invalid_client", "unknown client")
+	}
+	if client.IsPublic {
+		if clientSecret != "" {
+			return nil, newOAuthError("invalid_client", "public clients must not send a secret")
+		}
+		return client, nil
+	}
+	if clientSecret == "" || !CheckHashPassword(clientSecret, client.ClientSecret) {
+		return nil, newOAuthError("invalid_client", "client authentication failed")
+	}
+	return client, nil
+}
+
+// ValidateAuthorizeRequest checks an authorization request against the
+// registered client and returns the scopes that may be granted.
+//
+// Parameters:
+//   - payload: The authorization request parameters.
+//
+// Returns:
+//   - *types.OAuthClient: A pointer to the requesting client.
+//   - []string: The requested scopes allowed for the client.
+//   - error: An *OAuthError describing why the request is invalid.
+func ValidateAuthorizeRequest(payload types.OAuthAuthorizePayload) (*types.OAuthClient, []string, error) {
+	client, err := FetchOAuthClient(payload.ClientId)
+	if err != nil {
+		return nil, nil, newOAuthError("invalid_client", "unknown client")
+	}
+	if !slices.Contains(strings.Fields(client.RedirectURIs), payload.RedirectURI) {
+		return nil, nil, newOAuthError("invalid_request", "redirect_uri is not registered for this client")
+	}
+	if payload.ResponseType != "code" {
+		return client, nil, newOAuthError("unsupported_response_type", "only the code response type is supported")
+	}
+
+	requested := strings.Fields(payload.Scope)
+	if !slices.Contains(requested, utils.OAUTH_SCOPE_OPENID) {
+		return client, nil, newOAuthError("invalid_scope", "the openid scope is required")
+	}
+	allowed := strings.Fields(client.Scopes)
+	var scopes []string
+	for _, scope := range requested {
+		if slices.Contains(allowed, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	switch payload.CodeChallengeMethod {
+	case "", utils.OAUTH_PKCE_METHOD_S256, utils.OAUTH_PKCE_METHOD_PLAIN:
+	default:
+		return client, nil, newOAuthError("invalid_request", "unsupported code_challenge_method")
+	}
+	if client.IsPublic && payload.CodeChallenge == "" {
+		return client, nil, newOAuthError("invalid_request", "public clients must use PKCE")
+	}
+	return client, scopes, nil
+}
+
+// HasOAuthConsent reports whether the user already granted the client every
+// scope in the list.
+//
+// Parameters:
+//   - userId: The ID of the user.
+//   - clientId: The client identifier.
+//   - scopes: The scopes to check.
+//
+// Returns:
+//   - bool: True if all scopes were previously granted.
+func HasOAuthConsent(userId int, clientId string, scopes []string) bool {
+	var consent types.OAuthConsent
+	result := config.DB.Where("user_id=? AND client_id=?", userId, clientId).First(&consent)
+	if result.Error != nil {
+		return false
+	}
+	granted := strings.Fields(consent.Scope)
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveOAuthConsent records that the user granted the client the given scopes,
+// merging them with any previously granted scopes.
+//
+// Parameters:
+//   - userId: The ID of the user.
+//   - clientId: The client identifier.
+//   - scopes: The granted scopes.
+//
+// Returns:
+//   - error: An error object if there is an issue saving the consent.
+func SaveOAuthConsent(userId int, clientId string, scopes []string) error {
+	var consent types.OAuthConsent
+	config.DB.Where("user_id=? AND client_id=?", userId, clientId).First(&consent)
+	granted := strings.Fields(consent.Scope)
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	consent.UserId = userId
+	consent.ClientId = clientId
+	consent.Scope = strings.Join(granted, " ")
+	return config.DB.Save(&consent).Error
+}
+
+// CreateAuthorizationCode issues a single-use authorization code for an
+// approved authorization request.
+//
+// Parameters:
+//   - payload: The validated authorization request parameters.
+//   - userId: The ID of the authenticated user.
+//   - scopes: The granted scopes.
+//
+// Returns:
+//   - string: The plain authorization code.
+//   - error: An error object if there is an issue saving the code.
+func CreateAuthorizationCode(payload types.OAuthAuthorizePayload, userId int, scopes []string) (string, error) {
+	code, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	method := payload.CodeChallengeMethod
+	if payload.CodeChallenge != "" && method == "" {
+		method = utils.OAUTH_PKCE_METHOD_PLAIN
+	}
+	now := time.Now()
+	expiresAt := now.Add(utils.OAUTH_CODE_TTL)
+	authCode := types.OAuthAuthorizationCode{
+		CodeHash:            hashToken(code),
+		ClientId:            payload.ClientId,
+		UserId:              userId,
+		RedirectURI:         payload.RedirectURI,
+		Scope:               strings.Join(scopes, " "),
+		Nonce:               payload.Nonce,
+		CodeChallenge:       payload.CodeChallenge,
+		CodeChallengeMethod: method,
+		AuthTime:            &now,
+		ExpiresAt:           &expiresAt,
+	}
+	result := config.DB.Create(&authCode)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return code, nil
+}
+
+// ExchangeAuthorizationCode redeems an authorization code for tokens. The code
+// is marked as used inside the same transaction so it can only be redeemed once.
+//
+// Parameters:
+//   - client: The authenticated client.
+//   - code: The plain authorization code.
+//   - redirectURI: The redirect URI sent with the authorization request.
+//   - codeVerifier: The PKCE code verifier, if a challenge was sent.
+//
+// Returns:
+//   - *types.OAuthTokenResponse: The issued tokens.
+//   - error: An *OAuthError if the code cannot be redeemed.
+func ExchangeAuthorizationCode(client *types.OAuthClient, code, redirectURI, codeVerifier string) (*types.OAuthTokenResponse, error) {
+	var authCode types.OAuthAuthorizationCode
+	replayed := false
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code_hash=?", hashToken(code)).First(&authCode)
+		if result.Error != nil {
+			return newOAuthError("invalid_grant", "invalid authorization code")
+		}
+		if authCode.Used {
+			replayed = true
+			return newOAuthError("invalid_grant", "authorization code has already been used")
+		}
+		if authCode.ExpiresAt.Before(time.Now()) {
+			return newOAuthError("invalid_grant", "authorization code has expired")
+		}
+		if authCode.ClientId != client.ClientId || authCode.RedirectURI != redirectURI {
+			return newOAuthError("invalid_grant", "authorization code was not issued to this client")
+		}
+		if !verifyCodeChallenge(authCode.CodeChallenge, authCode.CodeChallengeMethod, codeVerifier) {
+			return newOAuthError("invalid_grant", "code_verifier does not match the code challenge")
+		}
+		return tx.Model(&authCode).Update("used", true).Error
+	})
+	if replayed {
+		// A replayed code revokes the refresh tokens issued to that client for the user.
+		config.DB.Model(&types.OAuthRefreshToken{}).Where("client_id=? AND user_id=?", authCode.ClientId, authCode.UserId).Update("revoked", true)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := FetchUser(authCode.UserId)
+	if err != nil {
+		return nil, newOAuthError("invalid_grant", "user no longer exists")
+	}
+	if user.SuspendedAt != nil {
+		return nil, newOAuthError("invalid_grant", "user is suspended")
+	}
+	return issueOAuthTokens(client, user, strings.Fields(authCode.Scope), authCode.Nonce, *authCode.AuthTime)
+}
+
+// RefreshOAuthToken exchanges a refresh token for a new set of tokens. Refresh
+// tokens are rotated, the presented token is revoked once it has been used.
+// The nonce belongs to the original authentication, so refreshed ID tokens
+// don't carry one.
+//
+// Parameters:
+//   - client: The authenticated client.
+//   - refreshToken: The plain refresh token.
+//   - scope: An optional space separated subset of the originally granted scopes.
+//
+// Returns:
+//   - *types.OAuthTokenResponse: The issued tokens.
+//   - error: An *OAuthError if the refresh token cannot be used.
+func RefreshOAuthToken(client *types.OAuthClient, refreshToken, scope string) (*types.OAuthTokenResponse, error) {
+	var stored types.OAuthRefreshToken
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash=?", hashToken(refreshToken)).First(&stored)
+		if result.Error != nil || stored.Revoked || stored.ExpiresAt.Before(time.Now()) {
+			return newOAuthError("invalid_grant", "invalid refresh token")
+		}
+		if stored.ClientId != client.ClientId {
+			return newOAuthError("invalid_grant", "refresh token was not issued to this client")
+		}
+		return tx.Model(&stored).Update("revoked", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	granted := strings.Fields(stored.Scope)
+	scopes := granted
+	if scope != "" {
+		scopes = strings.Fields(scope)
+		for _, s := range scopes {
+			if !slices.Contains(granted, s) {
+				return nil, newOAuthError("invalid_scope", "requested scope exceeds the original grant")
+			}
+		}
+	}
+
+	user, err := FetchUser(stored.UserId)
+	if err != nil {
+		return nil, newOAuthError("invalid_grant", "user no longer exists")
+	}
+	if user.SuspendedAt != nil {
+		return nil, newOAuthError("invalid_grant", "user is suspended")
+	}
+	return issueOAuthTokens(client, user, scopes, "", *stored.AuthTime)
+}
+
+// VerifyOAuthAccessToken validates an access token issued by the token endpoint.
+//
+// Parameters:
+//   - accessToken: The access token string.
+//
+// Returns:
+//   - *types.User: The user the token was issued for.
+//   - []string: The scopes granted to the token.
+//   - error: An error object if the token is invalid or expired.
+func VerifyOAuthAccessToken(accessToken string) (*types.User, []string, error) {
+	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["typ"] != "at+jwt" {
+			return nil, fmt.Errorf("unexpected token type: %v", token.Header["typ"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, err := fetchPublishedSigningKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		return &key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithIssuer(OIDCIssuer()), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid or expired token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid token claims")
+	}
+	sub, _ := claims.GetSubject()
+	userId, err := strconv.Atoi(sub)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid token subject")
+	}
+	user, err := FetchUser(userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.SuspendedAt != nil {
+		return nil, nil, fmt.Errorf("user is suspended")
+	}
+	if issuedAt, err := claims.GetIssuedAt(); user.TokensRevokedAt != nil && (err != nil || issuedAt == nil || issuedAt.Unix() <= user.TokensRevokedAt.Unix()) {
+		return nil, nil, fmt.Errorf("token has been revoked")
+	}
+	scope, _ := claims["scope"].(string)
+	return user, strings.Fields(scope), nil
+}
+
+// OIDCUserInfoForScopes builds the userinfo claims released for the given scopes.
+//
+// Parameters:
+//   - user: The user the claims describe.
+//   - scopes: The scopes granted to the client.
+//
+// Returns:
+//   - types.OIDCUserInfo: The released claims.
+func OIDCUserInfoForScopes(user *types.User, scopes []string) types.OIDCUserInfo {
+	info := types.OIDCUserInfo{Sub: strconv.Itoa(user.ID)}
+	if slices.Contains(scopes, utils.OAUTH_SCOPE_PROFILE) {
+		info.Name = user.Name
+		info.Picture = user.Avatar
+	}
+	if slices.Contains(scopes, utils.OAUTH_SCOPE_EMAIL) {
+		info.Email = user.Email
+	}
+	return info
+}
+
+// FetchJWKS returns the public half of every signing key that may still have
+// valid tokens in circulation.
+//
+// Returns:
+//   - types.JSONWebKeySet: The published key set.
+//   - error: An error object if there is an issue loading the keys.
+func FetchJWKS() (types.JSONWebKeySet, error) {
+	jwks := types.JSONWebKeySet{Keys: []types.JSONWebKey{}}
+	if _, _, err := activeSigningKey(); err != nil {
+		return jwks, err
+	}
+
+	var keys []types.OAuthSigningKey
+	result := config.DB.Where("retired_at IS NULL").Order("id DESC").Find(&keys)
+	if result.Error != nil {
+		return jwks, result.Error
+	}
+	for _, key := range keys {
+		privateKey, err := parseSigningKey(key)
+		if err != nil {
+			return jwks, err
+		}
+		jwks.Keys = append(jwks.Keys, types.JSONWebKey{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			Kid: key.Kid,
+			N:   base64.RawURLEncoding.EncodeToString(privateKey.PublicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.PublicKey.E)).Bytes()),
+		})
+	}
+	return jwks, nil
+}
+
+func issueOAuthTokens(client *types.OAuthClient, user *types.User, scopes []string, nonce string, authTime time.Time) (*types.OAuthTokenResponse, error) {
+	kid, key, err := activeSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	scope := strings.Join(scopes, " ")
+	jti, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":       OIDCIssuer(),
+		"sub":       strconv.Itoa(user.ID),
+		"aud":       client.ClientId,
+		"client_id": client.ClientId,
+		"scope":     scope,
+		"jti":       jti,
+		"iat":       now.Unix(),
+		"exp":       now.Add(utils.OAUTH_ACCESS_TOKEN_TTL).Unix(),
+	})
+	accessToken.Header["kid"] = kid
+	accessToken.Header["typ"] = "at+jwt"
+	accessTokenString, err := accessToken.SignedString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &types.OAuthTokenResponse{
+		AccessToken: accessTokenString,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(utils.OAUTH_ACCESS_TOKEN_TTL.Seconds()),
+		Scope:       scope,
+	}
+
+	if slices.Contains(scopes, utils.OAUTH_SCOPE_OPENID) {
+		idClaims := jwt.MapClaims{
+			"iss":       OIDCIssuer(),
+			"sub":       strconv.Itoa(user.ID),
+			"aud":       client.ClientId,
+			"azp":       client.ClientId,
+			"iat":       now.Unix(),
+			"exp":       now.Add(utils.OAUTH_ID_TOKEN_TTL).Unix(),
+			"auth_time": authTime.Unix(),
+			"at_hash":   halfHash(accessTokenString),
+		}
+		if nonce != "" {
+			idClaims["nonce"] = nonce
+		}
+		info := OIDCUserInfoForScopes(user, scopes)
+		if info.Name != "" {
+			idClaims["name"] = info.Name
+		}
+		if info.Picture != "" {
+			idClaims["picture"] = info.Picture
+		}
+		if info.Email != "" {
+			idClaims["email"] = info.Email
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, idClaims)
+		idToken.Header["kid"] = kid
+		response.IdToken, err = idToken.SignedString(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if slices.Contains(scopes, utils.OAUTH_SCOPE_OFFLINE_ACCESS) {
+		refreshToken, err := randomToken(32)
+		if err != nil {
+			return nil, err
+		}
+		expiresAt := now.Add(utils.OAUTH_REFRESH_TOKEN_TTL)
+		stored := types.OAuthRefreshToken{
+			TokenHash: hashToken(refreshToken),
+			ClientId:  client.ClientId,
+			UserId:    user.ID,
+			Scope:     scope,
+			AuthTime:  &authTime,
+			ExpiresAt: &expiresAt,
+		}
+		if result := config.DB.Create(&stored); result.Error != nil {
+			return nil, result.Error
+		}
+		response.RefreshToken = refreshToken
+	}
+	return response, nil
+}
+
+// activeSigningKey returns the key new tokens are signed with, rotating it
+// when it is older than OIDC_KEY_ROTATION_HOURS. Rotated keys stay published
+// for another rotation period so tokens signed with them can still be verified.
+func activeSigningKey() (string, *rsa.PrivateKey, error) {
+	rotation := keyRotationPeriod()
+	var active types.OAuthSigningKey
+	// Most calls find a current key and don't need the lock.
+	result := config.DB.Where("rotated_at IS NULL").Order("id DESC").Limit(1).Find(&active)
+	if result.Error != nil {
+		return "", nil, result.Error
+	}
+	if result.RowsAffected == 0 || !active.CreatedAt.Add(rotation).After(time.Now()) {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			return rotateSigningKey(tx, rotation, &active)
+		})
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	privateKey, err := parseSigningKey(active)
+	if err != nil {
+		return "", nil, err
+	}
+	return active.Kid, privateKey, nil
+}
+
+// rotateSigningKey replaces the active key with a new one unless another
+// request already did. The advisory lock serializes rotations, including the
+// creation of the first key when there is no row to lock yet.
+func rotateSigningKey(tx *gorm.DB, rotation time.Duration, active *types.OAuthSigningKey) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", utils.OAUTH_SIGNING_KEY_LOCK_ID).Error; err != nil {
+		return err
+	}
+	*active = types.OAuthSigningKey{}
+	result := tx.Where("rotated_at IS NULL").Order("id DESC").Limit(1).Find(active)
+	if result.Error != nil {
+		return result.Error
+	}
+	now := time.Now()
+	if result.RowsAffected > 0 && active.CreatedAt.Add(rotation).After(now) {
+		return nil
+	}
+	if result.RowsAffected > 0 {
+		if err := tx.Model(active).Update("rotated_at", now).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&types.OAuthSigningKey{}).Where("retired_at IS NULL AND rotated_at < ?", now.Add(-rotation)).Update("retired_at", now).Error; err != nil {
+		return err
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	kid, err := randomToken(8)
+	if err != nil {
+		return err
+	}
+	*active = types.OAuthSigningKey{
+		Kid:        kid,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})),
+	}
+	return tx.Create(active).Error
+}
+
+func fetchPublishedSigningKey(kid string) (*rsa.PrivateKey, error) {
+	var key types.OAuthSigningKey
+	result := config.DB.Where("kid=? AND retired_at IS NULL", kid).First(&key)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return parseSigningKey(key)
+}
+
+func parseSigningKey(key types.OAuthSigningKey) (*rsa.PrivateKey, error) {
+	signingKeyCacheMu.RLock()
+	privateKey, ok := signingKeyCache[key.Kid]
+	signingKeyCacheMu.RUnlock()
+	if ok {
+		return privateKey, nil
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("signing key %q is not PEM encoded", key.Kid)
+	}
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %q: %w", key.Kid, err)
+	}
+
+	signingKeyCacheMu.Lock()
+	signingKeyCache[key.Kid] = privateKey
+	signingKeyCacheMu.Unlock()
+	return privateKey, nil
+}
+
+func keyRotationPeriod() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("OIDC_KEY_ROTATION_HOURS"))
+	if err != nil || hours <= 0 {
+		return utils.OAUTH_DEFAULT_KEY_ROTATION
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func verifyCodeChallenge(challenge, method, verifier string) bool {
+	if challenge == "" {
+		return verifier == ""
+	}
+	if verifier == "" {
+		return false
+	}
+	expected := verifier
+	if method == utils.OAUTH_PKCE_METHOD_S256 {
+		sum := sha256.Sum256([]byte(verifier))
+		expected = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// halfHash computes the at_hash claim: the left half of the SHA-256 digest of
+// the access token, base64url encoded.
+func halfHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
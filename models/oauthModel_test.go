@@ -0,0 +1,93 @@
+package models
+
+import (
+	"server/config"
+	"server/types"
+	"server/utils"
+	"sync"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestActiveSigningKeyIsSharedByConcurrentCalls(t *testing.T) {
+	testDB(t)
+
+	kids := make([]string, 5)
+	errs := make([]error, 5)
+	var wg sync.WaitGroup
+	for i := range kids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			kids[i], _, errs[i] = activeSigningKey()
+		}(i)
+	}
+	wg.Wait()
+	for i := range kids {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if kids[i] != kids[0] {
+			t.Fatalf("concurrent calls returned keys %q and %q", kids[0], kids[i])
+		}
+	}
+}
+
+func TestRefreshedIdTokensHaveNoNonce(t *testing.T) {
+	testDB(t)
+
+	user := types.User{Name: "OIDC", Email: testEmail(t, "oidc")}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	client := types.OAuthClient{ClientId: "nonce-" + user.Email, Name: "Client", RedirectURIs: "https://client.example.com/cb",
+		Scopes: "openid offline_access", IsPublic: true, CreatedBy: user.ID, Status: true}
+	if err := config.DB.Create(&client).Error; err != nil {
+		t.Fatal(err)
+	}
+	scopes := []string{utils.OAUTH_SCOPE_OPENID, utils.OAUTH_SCOPE_OFFLINE_ACCESS}
+	code, err := CreateAuthorizationCode(types.OAuthAuthorizePayload{ClientId: client.ClientId, RedirectURI: "https://client.example.com/cb",
+		Nonce: "n-0S6_WzA2Mj", CodeChallenge: "verifier", CodeChallengeMethod: utils.OAUTH_PKCE_METHOD_PLAIN}, user.ID, scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := ExchangeAuthorizationCode(&client, code, "https://client.example.com/cb", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce := idTokenClaims(t, tokens.IdToken)["nonce"]; nonce != "n-0S6_WzA2Mj" {
+		t.Fatalf("nonce of the first ID token = %v", nonce)
+	}
+
+	refreshed, err := RefreshOAuthToken(&client, tokens.RefreshToken, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce, ok := idTokenClaims(t, refreshed.IdToken)["nonce"]; ok {
+		t.Errorf("refreshed ID token has nonce %v", nonce)
+	}
+}
+
+func idTokenClaims(t *testing.T, idToken string) jwt.MapClaims {
+	t.Helper()
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(idToken, claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestMigrateDropsRefreshTokenNonce(t *testing.T) {
+	testDB(t)
+
+	if err := config.DB.Exec("ALTER TABLE " + utils.OAUTH_REFRESH_TOKENS_TABLE + " ADD COLUMN IF NOT EXISTS nonce text").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if config.DB.Migrator().HasColumn(&types.OAuthRefreshToken{}, "nonce") {
+		t.Fatal("nonce column is still on the refresh tokens table")
+	}
+}
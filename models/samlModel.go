@@ -0,0 +1,535 @@
+package models
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+	"gorm.io/gorm"
+)
+
+var ErrSAMLAccountNotLinked = errors.New("an account with this email exists and is not a member of the workspace")
+
+// samlNow is the clock used to check assertion validity windows. It is a
+// variable so fixture assertions can be validated at a fixed point in time.
+var samlNow = time.Now
+
+// samlStore remembers the AuthnRequests sent and the assertions consumed. It
+// is a variable so fixture assertions can be validated without a database.
+var samlStore samlStateStore = dbSAMLStore{}
+
+type samlStateStore interface {
+	// saveRequest records an AuthnRequest sent for a workspace.
+	saveRequest(workspaceId int, requestId string, expiresAt time.Time) error
+	// consumeRequest fails unless the request was sent for the workspace,
+	// hasn't expired and hasn't been answered before.
+	consumeRequest(workspaceId int, requestId string) error
+	// consumeAssertion fails if the assertion has been used before.
+	consumeAssertion(workspaceId int, assertionId string, expiresAt time.Time) error
+}
+
+// SAMLIdPMetadata holds the parts of an identity provider's metadata the
+// service provider needs.
+type SAMLIdPMetadata struct {
+	EntityID     string
+	SSOURL       string
+	Certificates []*x509.Certificate
+}
+
+// ParseSAMLIdPMetadata parses an IdP EntityDescriptor document.
+//
+// Parameters:
+//   - metadata: The IdP metadata XML.
+//
+// Returns:
+//   - *SAMLIdPMetadata: The entity ID, SSO URL and signing certificates of the IdP.
+//   - error: An error object if the metadata is invalid or incomplete.
+func ParseSAMLIdPMetadata(metadata string) (*SAMLIdPMetadata, error) {
+	var descriptor types.SAMLEntityDescriptor
+	if err := xml.Unmarshal([]byte(metadata), &descriptor); err != nil {
+		return nil, fmt.Errorf("failed to decode IdP metadata: %w", err)
+	}
+	if descriptor.EntityID == "" || descriptor.IDPSSODescriptor == nil {
+		return nil, fmt.Errorf("metadata does not describe an identity provider")
+	}
+
+	idp := &SAMLIdPMetadata{EntityID: descriptor.EntityID}
+	for _, service := range descriptor.IDPSSODescriptor.SingleSignOnServices {
+		if service.Binding == utils.SAML_BINDING_HTTP_REDIRECT {
+			idp.SSOURL = service.Location
+			break
+		}
+	}
+	if idp.SSOURL == "" {
+		return nil, fmt.Errorf("metadata has no HTTP-Redirect SingleSignOnService")
+	}
+
+	for _, key := range descriptor.IDPSSODescriptor.KeyDescriptors {
+		if key.Use != "" && key.Use != "signing" {
+			continue
+		}
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(key.X509Certificate), ""))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode IdP certificate: %w", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse IdP certificate: %w", err)
+		}
+		idp.Certificates = append(idp.Certificates, cert)
+	}
+	if len(idp.Certificates) == 0 {
+		return nil, fmt.Errorf("metadata has no signing certificate")
+	}
+	return idp, nil
+}
+
+// SAMLServiceProviderEntityID returns the SP entity ID for a workspace. It is
+// also the URL the SP metadata is served from.
+func SAMLServiceProviderEntityID(workspaceId int) string {
+	return fmt.Sprintf("%s/saml/%d/metadata", config.ServerURL(), workspaceId)
+}
+
+// SAMLServiceProviderACSURL returns the assertion consumer service URL for a workspace.
+func SAMLServiceProviderACSURL(workspaceId int) string {
+	return fmt.Sprintf("%s/saml/%d/acs", config.ServerURL(), workspaceId)
+}
+
+// BuildSAMLServiceProviderMetadata renders the SP metadata document for a workspace.
+//
+// Parameters:
+//   - workspaceId: The ID of the workspace.
+//
+// Returns:
+//   - []byte: The metadata XML.
+//   - error: An error object if there is an issue encoding the metadata.
+func BuildSAMLServiceProviderMetadata(workspaceId int) ([]byte, error) {
+	descriptor := types.SAMLEntityDescriptor{
+		EntityID: SAMLServiceProviderEntityID(workspaceId),
+		SPSSODescriptor: &types.SAMLSPSSODescriptor{
+			WantAssertionsSigned:       true,
+			ProtocolSupportEnumeration: utils.SAML_PROTOCOL_NAMESPACE,
+			NameIDFormats:              []string{utils.SAML_NAMEID_FORMAT_EMAIL},
+			AssertionConsumerServices: []types.SAMLIndexedEndpoint{{
+				Binding:   utils.SAML_BINDING_HTTP_POST,
+				Location:  SAMLServiceProviderACSURL(workspaceId),
+				Index:     0,
+				IsDefault: true,
+			}},
+		},
+	}
+	body, err := xml.MarshalIndent(descriptor, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// UpdateWorkspaceSAMLConfig validates and stores the IdP metadata of a workspace.
+//
+// Parameters:
+//   - workspace: A pointer to the workspace to update.
+//   - payload: The IdP metadata and whether SSO is enabled.
+//
+// Returns:
+//   - *SAMLIdPMetadata: The parsed IdP metadata.
+//   - error: An error object if the metadata is invalid or cannot be saved.
+func UpdateWorkspaceSAMLConfig(workspace *types.Workspace, payload types.SAMLIdPPayload) (*SAMLIdPMetadata, error) {
+	idp, err := ParseSAMLIdPMetadata(payload.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	result := config.DB.Model(workspace).Updates(map[string]interface{}{
+		"saml_idp_metadata": payload.Metadata,
+		"saml_enabled":      payload.Enabled,
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return idp, nil
+}
+
+// BuildSAMLAuthnRequestURL builds the HTTP-Redirect URL that starts an SP
+// initiated login at the workspace's IdP.
+//
+// Parameters:
+//   - workspace: A pointer to the workspace with SAML enabled.
+//   - relayState: An opaque value the IdP returns with the response.
+//
+// Returns:
+//   - string: The IdP URL to redirect the browser to.
+//   - error: An error object if the workspace is not configured for SAML.
+func BuildSAMLAuthnRequestURL(workspace *types.Workspace, relayState string) (string, error) {
+	idp, err := workspaceIdP(workspace)
+	if err != nil {
+		return "", err
+	}
+	id, err := randomToken(20)
+	if err != nil {
+		return "", err
+	}
+
+	request := types.SAMLAuthnRequest{
+		ID:                          "_" + id,
+		Version:                     "2.0",
+		IssueInstant:                samlNow().UTC().Format(time.RFC3339),
+		Destination:                 idp.SSOURL,
+		ProtocolBinding:             utils.SAML_BINDING_HTTP_POST,
+		AssertionConsumerServiceURL: SAMLServiceProviderACSURL(workspace.ID),
+		Issuer:                      SAMLServiceProviderEntityID(workspace.ID),
+	}
+	body, err := xml.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	if err := samlStore.saveRequest(workspace.ID, request.ID, samlNow().Add(utils.SAML_REQUEST_TTL)); err != nil {
+		return "", err
+	}
+
+	var deflated bytes.Buffer
+	writer, err := flate.NewWriter(&deflated, flate.DefaultCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := writer.Write(body); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	redirect, err := url.Parse(idp.SSOURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid IdP SSO URL: %w", err)
+	}
+	query := redirect.Query()
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	if relayState != "" {
+		query.Set("RelayState", relayState)
+	}
+	redirect.RawQuery = query.Encode()
+	return redirect.String(), nil
+}
+
+// ValidateSAMLResponse verifies a base64 encoded SAML Response posted to the
+// ACS endpoint of a workspace. Either the Response or the Assertion must carry
+// a valid signature from a certificate in the IdP metadata, and only the
+// signed content is used afterwards. The response must answer an
+// AuthnRequest built by BuildSAMLAuthnRequestURL, so IdP initiated logins
+// are refused.
+//
+// Parameters:
+//   - workspace: A pointer to the workspace the response was posted for.
+//   - samlResponse: The SAMLResponse form value.
+//
+// Returns:
+//   - *types.SAMLAssertion: The validated assertion.
+//   - error: An error object if the response is invalid, unsigned, expired or replayed.
+func ValidateSAMLResponse(workspace *types.Workspace, samlResponse string) (*types.SAMLAssertion, error) {
+	idp, err := workspaceIdP(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, fmt.Errorf("SAMLResponse is not base64 encoded")
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		return nil, fmt.Errorf("SAMLResponse is not valid XML")
+	}
+	response := doc.Root()
+	if response == nil || response.Tag != "Response" || response.NamespaceURI() != utils.SAML_PROTOCOL_NAMESPACE {
+		return nil, fmt.Errorf("document is not a SAML Response")
+	}
+
+	statusCode := response.FindElement("./Status/StatusCode")
+	if statusCode == nil || statusCode.SelectAttrValue("Value", "") != utils.SAML_STATUS_SUCCESS {
+		return nil, fmt.Errorf("identity provider returned an unsuccessful status")
+	}
+	if response.SelectElement("EncryptedAssertion") != nil {
+		return nil, fmt.Errorf("encrypted assertions are not supported")
+	}
+
+	validation := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: idp.Certificates})
+	validation.Clock = dsig.NewFakeClockAt(samlNow())
+
+	// Destination and InResponseTo are only read from a signed Response. When
+	// just the assertion is signed, its subject confirmation carries the same
+	// recipient and request checks.
+	responseSigned := response.SelectElement("Signature") != nil
+	if responseSigned {
+		response, err = validation.Validate(response)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response signature: %w", err)
+		}
+		acsURL := SAMLServiceProviderACSURL(workspace.ID)
+		if destination := response.SelectAttrValue("Destination", ""); destination != "" && destination != acsURL {
+			return nil, fmt.Errorf("response destination %q does not match %q", destination, acsURL)
+		}
+	}
+	assertions := response.SelectElements("Assertion")
+	if len(assertions) != 1 {
+		return nil, fmt.Errorf("response must contain exactly one assertion")
+	}
+	assertionElement := assertions[0]
+	if assertionElement.SelectElement("Signature") != nil {
+		nsContext, err := etreeutils.NSBuildParentContext(assertionElement)
+		if err != nil {
+			return nil, err
+		}
+		detached, err := etreeutils.NSDetatch(nsContext, assertionElement)
+		if err != nil {
+			return nil, err
+		}
+		assertionElement, err = validation.Validate(detached)
+		if err != nil {
+			return nil, fmt.Errorf("invalid assertion signature: %w", err)
+		}
+	} else if !responseSigned {
+		return nil, fmt.Errorf("neither the response nor the assertion is signed")
+	}
+
+	assertionDoc := etree.NewDocument()
+	assertionDoc.SetRoot(assertionElement.Copy())
+	assertionXML, err := assertionDoc.WriteToBytes()
+	if err != nil {
+		return nil, err
+	}
+	var assertion types.SAMLAssertion
+	if err := xml.Unmarshal(assertionXML, &assertion); err != nil {
+		return nil, fmt.Errorf("failed to decode assertion: %w", err)
+	}
+
+	expiresAt, inResponseTo, err := checkSAMLAssertion(&assertion, idp, workspace.ID)
+	if err != nil {
+		return nil, err
+	}
+	if responseSigned {
+		if responseTo := response.SelectAttrValue("InResponseTo", ""); responseTo != "" && responseTo != inResponseTo {
+			return nil, fmt.Errorf("response and assertion answer different requests")
+		}
+	}
+
+	if err := samlStore.consumeAssertion(workspace.ID, assertion.ID, expiresAt); err != nil {
+		return nil, err
+	}
+	if err := samlStore.consumeRequest(workspace.ID, inResponseTo); err != nil {
+		return nil, err
+	}
+	return &assertion, nil
+}
+
+// SAMLAssertionIdentity extracts the email and display name of the subject of
+// an assertion. The NameID is used when it is an email address, otherwise the
+// common email attributes are checked.
+//
+// Parameters:
+//   - assertion: A pointer to a validated assertion.
+//
+// Returns:
+//   - string: The email address of the subject.
+//   - string: The display name of the subject, falling back to the email.
+//   - error: An error object if the assertion carries no email address.
+func SAMLAssertionIdentity(assertion *types.SAMLAssertion) (string, string, error) {
+	email := ""
+	nameID := strings.TrimSpace(assertion.Subject.NameID.Value)
+	if assertion.Subject.NameID.Format == utils.SAML_NAMEID_FORMAT_EMAIL || strings.Contains(nameID, "@") {
+		email = nameID
+	}
+	name := ""
+	for _, attribute := range assertion.Attributes {
+		if len(attribute.Values) == 0 {
+			continue
+		}
+		if email == "" && slices.Contains(utils.SAML_EMAIL_ATTRIBUTES, attribute.Name) {
+			email = strings.TrimSpace(attribute.Values[0])
+		}
+		if name == "" && slices.Contains(utils.SAML_NAME_ATTRIBUTES, attribute.Name) {
+			name = strings.TrimSpace(attribute.Values[0])
+		}
+	}
+	if email == "" {
+		return "", "", fmt.Errorf("assertion does not contain an email address")
+	}
+	if name == "" {
+		name = email
+	}
+	return strings.ToLower(email), name, nil
+}
+
+// ProvisionSAMLUser finds or creates the user for a SAML login, in a single
+// transaction. A new user is created and added to the workspace. An existing
+// account is only signed in when it is already a member of the workspace:
+// the IdP is configured by the workspace owner, so it can't vouch for anyone
+// else's account.
+//
+// Parameters:
+//   - workspaceId: The ID of the workspace the user signed in to.
+//   - email: The email address asserted by the IdP.
+//   - name: The display name asserted by the IdP.
+//
+// Returns:
+//   - *types.User: A pointer to the provisioned user.
+//   - error: ErrSAMLAccountNotLinked, a *QuotaExceededError when the plan has
+//     no seats left for a new user, or an error object if there is an issue
+//     saving the user or membership.
+func ProvisionSAMLUser(workspaceId int, email, name string) (*types.User, error) {
+	var user types.User
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("email=?", email).First(&user)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return provisionSAMLMember(tx, workspaceId, &user, email, name)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var count int64
+		if err := tx.Model(&types.WorkspaceUser{}).Where("workspace_id=? AND user_id=?", workspaceId, user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrSAMLAccountNotLinked
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// provisionSAMLMember creates a user signing in for the first time and adds
+// them to the workspace. A pending invitation for their email is accepted, so
+// they take the seat it holds.
+func provisionSAMLMember(tx *gorm.DB, workspaceId int, user *types.User, email, name string) error {
+	if err := lockWorkspace(tx, workspaceId); err != nil {
+		return err
+	}
+	*user = types.User{Name: name, Email: email, Provider: utils.USER_PROVIDER_SAML}
+	if err := tx.Create(user).Error; err != nil {
+		return err
+	}
+
+	role := utils.WORKSPACE_ROLE_MEMBER
+	var invitation types.WorkspaceInvitation
+	result := pendingInvitations(tx, workspaceId).Where("email=?", email).Limit(1).Find(&invitation)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		role = invitation.Role
+	}
+	if _, err := addWorkspaceMember(tx, workspaceId, user.ID, role, invitation.ID); err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+	return tx.Model(&invitation).Updates(map[string]interface{}{
+		"accepted_at": time.Now(),
+		"accepted_by": user.ID,
+	}).Error
+}
+
+func workspaceIdP(workspace *types.Workspace) (*SAMLIdPMetadata, error) {
+	if !workspace.SamlEnabled || workspace.SamlIdpMetadata == "" {
+		return nil, fmt.Errorf("SAML SSO is not enabled for this workspace")
+	}
+	return ParseSAMLIdPMetadata(workspace.SamlIdpMetadata)
+}
+
+// checkSAMLAssertion checks issuer, audience, validity window and subject
+// confirmation of a validated assertion. It returns when the assertion stops
+// being valid and the ID of the AuthnRequest it answers.
+func checkSAMLAssertion(assertion *types.SAMLAssertion, idp *SAMLIdPMetadata, workspaceId int) (time.Time, string, error) {
+	now := samlNow()
+	if assertion.ID == "" {
+		return now, "", fmt.Errorf("assertion has no ID")
+	}
+	if strings.TrimSpace(assertion.Issuer) != idp.EntityID {
+		return now, "", fmt.Errorf("assertion issuer %q does not match the IdP", assertion.Issuer)
+	}
+
+	expiresAt := now.Add(utils.SAML_CLOCK_SKEW)
+	if assertion.Conditions.NotBefore != "" {
+		notBefore, err := time.Parse(time.RFC3339, assertion.Conditions.NotBefore)
+		if err != nil || now.Add(utils.SAML_CLOCK_SKEW).Before(notBefore) {
+			return now, "", fmt.Errorf("assertion is not yet valid")
+		}
+	}
+	if assertion.Conditions.NotOnOrAfter != "" {
+		notOnOrAfter, err := time.Parse(time.RFC3339, assertion.Conditions.NotOnOrAfter)
+		if err != nil || !now.Add(-utils.SAML_CLOCK_SKEW).Before(notOnOrAfter) {
+			return now, "", fmt.Errorf("assertion has expired")
+		}
+		expiresAt = notOnOrAfter.Add(utils.SAML_CLOCK_SKEW)
+	}
+	if !slices.Contains(assertion.Conditions.Audiences, SAMLServiceProviderEntityID(workspaceId)) {
+		return now, "", fmt.Errorf("assertion is not intended for this service provider")
+	}
+
+	acsURL := SAMLServiceProviderACSURL(workspaceId)
+	for _, confirmation := range assertion.Subject.SubjectConfirmations {
+		if confirmation.Method != utils.SAML_CONFIRMATION_METHOD_BEARER {
+			continue
+		}
+		if confirmation.Data.Recipient != "" && confirmation.Data.Recipient != acsURL {
+			continue
+		}
+		if confirmation.Data.InResponseTo == "" {
+			continue
+		}
+		notOnOrAfter, err := time.Parse(time.RFC3339, confirmation.Data.NotOnOrAfter)
+		if err != nil || !now.Add(-utils.SAML_CLOCK_SKEW).Before(notOnOrAfter) {
+			continue
+		}
+		return expiresAt, confirmation.Data.InResponseTo, nil
+	}
+	return now, "", fmt.Errorf("assertion has no valid bearer subject confirmation")
+}
+
+// dbSAMLStore keeps SAML state in the database.
+type dbSAMLStore struct{}
+
+func (dbSAMLStore) saveRequest(workspaceId int, requestId string, expiresAt time.Time) error {
+	config.DB.Where("expires_at < ?", samlNow()).Delete(&types.SAMLPendingRequest{})
+	return config.DB.Create(&types.SAMLPendingRequest{RequestId: requestId, WorkspaceId: workspaceId, ExpiresAt: &expiresAt}).Error
+}
+
+func (dbSAMLStore) consumeRequest(workspaceId int, requestId string) error {
+	result := config.DB.Where("request_id=? AND workspace_id=? AND expires_at > ?", requestId, workspaceId, samlNow()).
+		Delete(&types.SAMLPendingRequest{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("response does not answer a pending request of this workspace")
+	}
+	return nil
+}
+
+func (dbSAMLStore) consumeAssertion(workspaceId int, assertionId string, expiresAt time.Time) error {
+	replay := types.SAMLAssertionReplay{AssertionId: assertionId, WorkspaceId: workspaceId, ExpiresAt: &expiresAt}
+	config.DB.Where("expires_at < ?", samlNow()).Delete(&types.SAMLAssertionReplay{})
+	if result := config.DB.Create(&replay); result.Error != nil {
+		return fmt.Errorf("assertion %q has already been used", assertionId)
+	}
+	return nil
+}
@@ -0,0 +1,457 @@
+package models
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const testIdPEntityID = "https://idp.example.com/metadata"
+
+var testSAMLTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// memorySAMLStore keeps SAML state in memory for tests.
+type memorySAMLStore struct {
+	requests   map[string]int
+	assertions map[string]bool
+}
+
+func (s *memorySAMLStore) saveRequest(workspaceId int, requestId string, expiresAt time.Time) error {
+	s.requests[requestId] = workspaceId
+	return nil
+}
+
+func (s *memorySAMLStore) consumeRequest(workspaceId int, requestId string) error {
+	if id, ok := s.requests[requestId]; !ok || id != workspaceId {
+		return fmt.Errorf("response does not answer a pending request of this workspace")
+	}
+	delete(s.requests, requestId)
+	return nil
+}
+
+func (s *memorySAMLStore) consumeAssertion(workspaceId int, assertionId string, expiresAt time.Time) error {
+	if s.assertions[assertionId] {
+		return fmt.Errorf("assertion %q has already been used", assertionId)
+	}
+	s.assertions[assertionId] = true
+	return nil
+}
+
+// testIdP is an identity provider with a locally generated certificate.
+type testIdP struct {
+	cert     tls.Certificate
+	metadata string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test IdP"},
+		NotBefore:    testSAMLTime.Add(-24 * time.Hour),
+		NotAfter:     testSAMLTime.Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := fmt.Sprintf(`<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" entityID="%s">
+  <IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <KeyDescriptor use="signing">
+      <KeyInfo xmlns="http://www.w3.org/2000/09/xmldsig#"><X509Data><X509Certificate>%s</X509Certificate></X509Data></KeyInfo>
+    </KeyDescriptor>
+    <SingleSignOnService Binding="%s" Location="https://idp.example.com/sso"/>
+  </IDPSSODescriptor>
+</EntityDescriptor>`, testIdPEntityID, base64.StdEncoding.EncodeToString(der), utils.SAML_BINDING_HTTP_REDIRECT)
+
+	return &testIdP{
+		cert:     tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+		metadata: metadata,
+	}
+}
+
+// testAssertion describes the assertion a fixture response carries.
+type testAssertion struct {
+	ID           string
+	Issuer       string
+	Audience     string
+	Email        string
+	InResponseTo string
+	NotOnOrAfter time.Time
+}
+
+func (idp *testIdP) assertion(workspaceId int, inResponseTo string) testAssertion {
+	return testAssertion{
+		ID:           "_assertion-" + inResponseTo,
+		Issuer:       testIdPEntityID,
+		Audience:     SAMLServiceProviderEntityID(workspaceId),
+		Email:        "alice@example.com",
+		InResponseTo: inResponseTo,
+		NotOnOrAfter: testSAMLTime.Add(5 * time.Minute),
+	}
+}
+
+// response builds a base64 encoded SAML Response with an assertion signed by
+// the IdP. tamper changes the assertion after it was signed.
+func (idp *testIdP) response(t *testing.T, workspaceId int, a testAssertion, tamper func(*etree.Element)) string {
+	t.Helper()
+	acsURL := SAMLServiceProviderACSURL(workspaceId)
+	issued := testSAMLTime.Add(-time.Minute).Format(time.RFC3339)
+	expires := a.NotOnOrAfter.Format(time.RFC3339)
+
+	assertionXML := fmt.Sprintf(`<saml:Assertion xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s">
+  <saml:Issuer>%s</saml:Issuer>
+  <saml:Subject>
+    <saml:NameID Format="%s">%s</saml:NameID>
+    <saml:SubjectConfirmation Method="%s">
+      <saml:SubjectConfirmationData InResponseTo="%s" NotOnOrAfter="%s" Recipient="%s"/>
+    </saml:SubjectConfirmation>
+  </saml:Subject>
+  <saml:Conditions NotBefore="%s" NotOnOrAfter="%s">
+    <saml:AudienceRestriction><saml:Audience>%s</saml:Audience></saml:AudienceRestriction>
+  </saml:Conditions>
+  <saml:AttributeStatement>
+    <saml:Attribute Name="displayName"><saml:AttributeValue>Alice</saml:AttributeValue></saml:Attribute>
+  </saml:AttributeStatement>
+</saml:Assertion>`, utils.SAML_ASSERTION_NAMESPACE, a.ID, issued, a.Issuer, utils.SAML_NAMEID_FORMAT_EMAIL, a.Email,
+		utils.SAML_CONFIRMATION_METHOD_BEARER, a.InResponseTo, expires, acsURL, issued, expires, a.Audience)
+
+	assertionDoc := etree.NewDocument()
+	if err := assertionDoc.ReadFromString(assertionXML); err != nil {
+		t.Fatal(err)
+	}
+	signing := dsig.NewDefaultSigningContext(dsig.TLSCertKeyStore(idp.cert))
+	signing.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	signed, err := signing.SignEnveloped(assertionDoc.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tamper != nil {
+		tamper(signed)
+	}
+
+	responseDoc := etree.NewDocument()
+	response := responseDoc.CreateElement("samlp:Response")
+	response.CreateAttr("xmlns:samlp", utils.SAML_PROTOCOL_NAMESPACE)
+	response.CreateAttr("xmlns:saml", utils.SAML_ASSERTION_NAMESPACE)
+	response.CreateAttr("ID", "_response-"+a.ID)
+	response.CreateAttr("Version", "2.0")
+	response.CreateAttr("IssueInstant", issued)
+	response.CreateAttr("Destination", acsURL)
+	response.CreateAttr("InResponseTo", a.InResponseTo)
+	response.CreateElement("saml:Issuer").SetText(a.Issuer)
+	response.CreateElement("samlp:Status").CreateElement("samlp:StatusCode").CreateAttr("Value", utils.SAML_STATUS_SUCCESS)
+	response.AddChild(signed)
+
+	raw, err := responseDoc.WriteToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// setupSAMLTest enables SAML for a workspace with a fresh IdP, freezes the
+// clock and keeps SAML state in memory.
+func setupSAMLTest(t *testing.T) (*testIdP, *types.Workspace) {
+	t.Helper()
+	t.Setenv("SERVER_URL", "https://sp.example.com")
+
+	previousNow, previousStore := samlNow, samlStore
+	samlNow = func() time.Time { return testSAMLTime }
+	samlStore = &memorySAMLStore{requests: map[string]int{}, assertions: map[string]bool{}}
+	t.Cleanup(func() { samlNow, samlStore = previousNow, previousStore })
+
+	idp := newTestIdP(t)
+	workspace := &types.Workspace{ID: 7, SamlEnabled: true, SamlIdpMetadata: idp.metadata}
+	return idp, workspace
+}
+
+// startSAMLLogin builds an AuthnRequest for the workspace and returns its ID.
+func startSAMLLogin(t *testing.T, workspace *types.Workspace) string {
+	t.Helper()
+	redirectURL, err := BuildSAMLAuthnRequestURL(workspace, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect, err := url.Parse(redirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deflated, err := base64.StdEncoding.DecodeString(redirect.Query().Get("SAMLRequest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var request types.SAMLAuthnRequest
+	if err := xml.Unmarshal(body, &request); err != nil {
+		t.Fatal(err)
+	}
+	return request.ID
+}
+
+func TestValidateSAMLResponseAcceptsSignedAssertion(t *testing.T) {
+	idp, workspace := setupSAMLTest(t)
+	requestId := startSAMLLogin(t, workspace)
+
+	assertion, err := ValidateSAMLResponse(workspace, idp.response(t, workspace.ID, idp.assertion(workspace.ID, requestId), nil))
+	if err != nil {
+		t.Fatalf("valid response was rejected: %v", err)
+	}
+	email, name, err := SAMLAssertionIdentity(assertion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email != "alice@example.com" || name != "Alice" {
+		t.Errorf("identity = %q, %q, want alice@example.com, Alice", email, name)
+	}
+}
+
+func TestValidateSAMLResponseRejectsInvalidAssertions(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(a *testAssertion)
+		tamper func(*etree.Element)
+		now    time.Time
+		want   string
+	}{
+		{
+			name: "tampered signature",
+			want: "invalid assertion signature",
+			tamper: func(assertion *etree.Element) {
+				assertion.FindElement("./Subject/NameID").SetText("mallory@example.com")
+			},
+		},
+		{
+			name:   "wrong issuer",
+			want:   "does not match the IdP",
+			modify: func(a *testAssertion) { a.Issuer = "https://other-idp.example.com/metadata" },
+		},
+		{
+			name:   "wrong audience",
+			want:   "not intended for this service provider",
+			modify: func(a *testAssertion) { a.Audience = SAMLServiceProviderEntityID(8) },
+		},
+		{
+			name: "expired",
+			want: "assertion has expired",
+			now:  testSAMLTime.Add(5*time.Minute + utils.SAML_CLOCK_SKEW),
+		},
+		{
+			name:   "unknown request",
+			want:   "pending request",
+			modify: func(a *testAssertion) { a.InResponseTo = "_never-sent" },
+		},
+		{
+			name:   "unsolicited",
+			want:   "no valid bearer subject confirmation",
+			modify: func(a *testAssertion) { a.InResponseTo = "" },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			idp, workspace := setupSAMLTest(t)
+			requestId := startSAMLLogin(t, workspace)
+			assertion := idp.assertion(workspace.ID, requestId)
+			if test.modify != nil {
+				test.modify(&assertion)
+			}
+			response := idp.response(t, workspace.ID, assertion, test.tamper)
+			if !test.now.IsZero() {
+				samlNow = func() time.Time { return test.now }
+			}
+
+			_, err := ValidateSAMLResponse(workspace, response)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateSAMLResponseRejectsReplay(t *testing.T) {
+	idp, workspace := setupSAMLTest(t)
+	requestId := startSAMLLogin(t, workspace)
+	response := idp.response(t, workspace.ID, idp.assertion(workspace.ID, requestId), nil)
+
+	if _, err := ValidateSAMLResponse(workspace, response); err != nil {
+		t.Fatalf("valid response was rejected: %v", err)
+	}
+	if _, err := ValidateSAMLResponse(workspace, response); err == nil {
+		t.Fatal("replayed response was accepted")
+	}
+}
+
+func TestValidateSAMLResponseRejectsRequestOfOtherWorkspace(t *testing.T) {
+	idp, workspace := setupSAMLTest(t)
+	other := &types.Workspace{ID: 8, SamlEnabled: true, SamlIdpMetadata: idp.metadata}
+	requestId := startSAMLLogin(t, other)
+
+	if _, err := ValidateSAMLResponse(workspace, idp.response(t, workspace.ID, idp.assertion(workspace.ID, requestId), nil)); err == nil {
+		t.Fatal("response to a request of another workspace was accepted")
+	}
+}
+
+func TestProvisionSAMLUserOnlyLinksMembers(t *testing.T) {
+	testDB(t)
+
+	workspace := types.Workspace{Name: "SAML test", Role: "test", Status: true}
+	if err := config.DB.Create(&workspace).Error; err != nil {
+		t.Fatal(err)
+	}
+	member := types.User{Name: "Member", Email: testEmail(t, "member")}
+	outsider := types.User{Name: "Outsider", Email: testEmail(t, "outsider")}
+	if err := config.DB.Create(&member).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Create(&outsider).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Create(&types.WorkspaceUser{WorkspaceId: workspace.ID, UserId: member.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := ProvisionSAMLUser(workspace.ID, member.Email, "Member")
+	if err != nil || user.ID != member.ID {
+		t.Errorf("member sign in = %v, %v, want user %d", user, err, member.ID)
+	}
+
+	if _, err := ProvisionSAMLUser(workspace.ID, outsider.Email, "Outsider"); !errors.Is(err, ErrSAMLAccountNotLinked) {
+		t.Errorf("outsider sign in error = %v, want ErrSAMLAccountNotLinked", err)
+	}
+	var count int64
+	config.DB.Model(&types.WorkspaceUser{}).Where("workspace_id=? AND user_id=?", workspace.ID, outsider.ID).Count(&count)
+	if count != 0 {
+		t.Error("outsider was added to the workspace")
+	}
+
+	email := testEmail(t, "new")
+	user, err = ProvisionSAMLUser(workspace.ID, email, "New")
+	if err != nil {
+		t.Fatalf("new user sign in failed: %v", err)
+	}
+	config.DB.Model(&types.WorkspaceUser{}).Where("workspace_id=? AND user_id=?", workspace.ID, user.ID).Count(&count)
+	if count != 1 || !strings.EqualFold(user.Email, email) {
+		t.Error("new user was not provisioned into the workspace")
+	}
+}
+
+// signResponse signs the Response of an encoded fixture when sign is set.
+// modify changes the Response before it is signed, tamper after.
+func (idp *testIdP) signResponse(t *testing.T, encoded string, sign bool, modify, tamper func(*etree.Element)) string {
+	t.Helper()
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		t.Fatal(err)
+	}
+	if modify != nil {
+		modify(doc.Root())
+	}
+	if sign {
+		signing := dsig.NewDefaultSigningContext(dsig.TLSCertKeyStore(idp.cert))
+		signing.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+		signed, err := signing.SignEnveloped(doc.Root())
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc.SetRoot(signed)
+	}
+	if tamper != nil {
+		tamper(doc.Root())
+	}
+	raw, err = doc.WriteToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestValidateSAMLResponseChecksSignedResponseAttributes(t *testing.T) {
+	setAttr := func(name, value string) func(*etree.Element) {
+		return func(response *etree.Element) { response.CreateAttr(name, value) }
+	}
+	tests := []struct {
+		name   string
+		signed bool
+		modify func(*etree.Element)
+		tamper func(*etree.Element)
+		want   string
+	}{
+		{name: "signed response", signed: true},
+		{
+			name:   "signed wrong destination",
+			signed: true,
+			modify: setAttr("Destination", "https://evil.example.com/acs"),
+			want:   "response destination",
+		},
+		{
+			name:   "signed other request",
+			signed: true,
+			modify: setAttr("InResponseTo", "_other-request"),
+			want:   "answer different requests",
+		},
+		{
+			name:   "destination changed after signing",
+			signed: true,
+			tamper: setAttr("Destination", SAMLServiceProviderACSURL(7)+"?"),
+			want:   "invalid response signature",
+		},
+		{
+			// Unsigned Response attributes can't be trusted, so the signed
+			// assertion decides.
+			name:   "unsigned wrong destination",
+			modify: setAttr("Destination", "https://evil.example.com/acs"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			idp, workspace := setupSAMLTest(t)
+			requestId := startSAMLLogin(t, workspace)
+			response := idp.response(t, workspace.ID, idp.assertion(workspace.ID, requestId), nil)
+			response = idp.signResponse(t, response, test.signed, test.modify, test.tamper)
+
+			_, err := ValidateSAMLResponse(workspace, response)
+			if test.want == "" {
+				if err != nil {
+					t.Fatalf("valid response was rejected: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"path"
+	"server/types"
+	"server/utils"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrAttachmentNotFound = errors.New("attachment not found")
+var ErrAttachmentUploaderRequired = errors.New("only the uploader or the creator of the session can delete the attachment")
+
+// FetchSessionAttachments lists the attachments of a session, each with a
+// short-lived download link.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - sessionId: The ID of the session.
+//   - userId: The ID of the current user, who must be its creator or a collaborator.
+//   - category: Only list attachments of this category, or all when empty.
+//
+// Returns:
+//   - []types.SessionAttachmentResponse: The attachments, oldest first.
+//   - error: ErrSessionNotFound, ErrSessionAccessDenied, or an error object
+//     if there is an issue retrieving the attachments.
+func FetchSessionAttachments(ctx context.Context, sessionId, userId int, category string) ([]types.SessionAttachmentResponse, error) {
+	var attachments []types.SessionAttachment
+	err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+		var session types.Session
+		if err := findSession(tx, sessionId, userId, &session, false); err != nil {
+			return err
+		}
+		db := tx.Where("session_id=?", session.ID)
+		if category != "" {
+			db = db.Where("category=?", category)
+		}
+		return db.Order("id").Find(&attachments).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]types.SessionAttachmentResponse, 0, len(attachments))
+	for _, attachment := range attachments {
+		response, err := sessionAttachmentResponse(attachment)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, *response)
+	}
+	return responses, nil
+}
+
+// AddSessionAttachments streams uploaded files to S3 and records them as
+// attachments of a session, counting their size against the workspace's
+// storage. Either all files are added or none are.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - sessionId: The ID of the session.
+//   - userId: The ID of the current user, who must be its creator or a collaborator.
+//   - category: One of the SESSION_CATEGORIES.
+//   - files: The uploaded files.
+//
+// Returns:
+//   - []types.SessionAttachmentResponse: The new attachments.
+//   - error: ErrSessionNotFound, ErrSessionAccessDenied, a *QuotaExceededError
+//     when the files don't fit the plan's storage, or an error object if
+//     there is an issue uploading or saving the files.
+func AddSessionAttachments(ctx context.Context, sessionId, userId int, category string, files []*multipart.FileHeader) ([]types.SessionAttachmentResponse, error) {
+	workspaceId, _ := TenantFromContext(ctx)
+	var total int64
+	for _, file := range files {
+		total += file.Size
+	}
+
+	// Fail early, before anything is uploaded. The quota is reserved for
+	// real once the files are stored.
+	err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+		var session types.Session
+		if err := findSession(tx, sessionId, userId, &session, false); err != nil {
+			return err
+		}
+		_, err := checkStorageQuota(tx, workspaceId, total)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attachments := make([]types.SessionAttachment, 0, len(files))
+	objectKeys := make([]string, 0, len(files))
+	err = func() error {
+		for _, file := range files {
+			attachment, objectKey, err := uploadSessionAttachment(workspaceId, sessionId, userId, category, file)
+			if objectKey != "" {
+				objectKeys = append(objectKeys, objectKey)
+			}
+			if err != nil {
+				return err
+			}
+			attachments = append(attachments, *attachment)
+		}
+
+		return TenantTransaction(ctx, func(tx *gorm.DB) error {
+			var session types.Session
+			if err := findSession(tx, sessionId, userId, &session, true); err != nil {
+				return err
+			}
+			if err := ReserveStorageQuota(tx, workspaceId, total); err != nil {
+				return err
+			}
+			return tx.Create(&attachments).Error
+		})
+	}()
+	if err != nil {
+		deleteSessionAttachmentFiles(sessionId, objectKeys)
+		return nil, err
+	}
+
+	responses := make([]types.SessionAttachmentResponse, 0, len(attachments))
+	for _, attachment := range attachments {
+		response, err := sessionAttachmentResponse(attachment)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, *response)
+	}
+	return responses, nil
+}
+
+// DeleteSessionAttachment deletes an attachment and its file, giving its size
+// back to the workspace's storage.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - sessionId: The ID of the session.
+//   - userId: The ID of the current user, who must be the uploader or the creator of the session.
+//   - attachmentId: The ID of the attachment.
+//
+// Returns:
+//   - error: ErrSessionNotFound, ErrSessionAccessDenied, ErrAttachmentNotFound,
+//     ErrAttachmentUploaderRequired, or an error object if there is an issue
+//     deleting the attachment.
+func DeleteSessionAttachment(ctx context.Context, sessionId, userId, attachmentId int) error {
+	var attachment types.SessionAttachment
+	err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+		var session types.Session
+		if err := findSession(tx, sessionId, userId, &session, true); err != nil {
+			return err
+		}
+		err := tx.Where("id=? AND session_id=?", attachmentId, session.ID).First(&attachment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAttachmentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if session.CreatedBy != userId && attachment.UploadedBy != userId {
+			return ErrAttachmentUploaderRequired
+		}
+		if err := tx.Delete(&attachment).Error; err != nil {
+			return err
+		}
+		return ReleaseStorageQuota(tx, session.WorkspaceId, attachment.Size)
+	})
+	if err != nil {
+		return err
+	}
+
+	if key, ok := utils.S3KeyFromUrl(attachment.Url); ok {
+		deleteSessionAttachmentFiles(sessionId, []string{key})
+	}
+	return nil
+}
+
+// uploadSessionAttachment streams one file to S3 under a random key. The key
+// is returned as soon as the upload was attempted so it can be cleaned up.
+func uploadSessionAttachment(workspaceId, sessionId, userId int, category string, file *multipart.FileHeader) (*types.SessionAttachment, string, error) {
+	name := sessionAttachmentName(file.Filename)
+	token, err := randomToken(12)
+	if err != nil {
+		return nil, "", err
+	}
+	contentType := file.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	body, err := file.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer body.Close()
+
+	objectKey := fmt.Sprintf("attachments/workspaces/%d/sessions/%d/%s-%s", workspaceId, sessionId, token, name)
+	if err := utils.UploadPrivateStreamToS3(objectKey, body, file.Size, contentType); err != nil {
+		return nil, objectKey, err
+	}
+	return &types.SessionAttachment{
+		SessionId:   sessionId,
+		WorkspaceId: workspaceId,
+		Url:         utils.GetS3Url(objectKey),
+		Name:        name,
+		Category:    category,
+		Size:        file.Size,
+		ContentType: contentType,
+		UploadedBy:  userId,
+		Status:      true,
+	}, objectKey, nil
+}
+
+// sessionAttachmentName keeps the base name of an uploaded file, replacing
+// characters that don't belong in an S3 key or a download header.
+func sessionAttachmentName(filename string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, path.Base(strings.ReplaceAll(filename, "\\", "/")))
+	if name == "" || name == "." || name == ".." || name == "_" {
+		return "file"
+	}
+	if len(name) > 200 {
+		name = name[len(name)-200:]
+	}
+	return name
+}
+
+// sessionAttachmentResponse signs a download link for an attachment.
+func sessionAttachmentResponse(attachment types.SessionAttachment) (*types.SessionAttachmentResponse, error) {
+	response := types.SessionAttachmentResponse{SessionAttachment: attachment}
+	key, ok := utils.S3KeyFromUrl(attachment.Url)
+	if !ok {
+		return &response, nil
+	}
+	url, err := utils.PresignS3Download(key, attachment.Name, utils.SESSION_ATTACHMENT_LINK_TTL)
+	if err != nil {
+		return nil, err
+	}
+	response.DownloadURL = url
+	return &response, nil
+}
+
+func deleteSessionAttachmentFiles(sessionId int, objectKeys []string) {
+	if len(objectKeys) == 0 {
+		return
+	}
+	failed, err := utils.DeleteFromS3(objectKeys)
+	if err != nil {
+		log.Printf("failed to delete attachment files of session %d: %v", sessionId, err)
+	}
+	for _, key := range failed {
+		log.Printf("attachment file left behind for session %d: %s", sessionId, key)
+	}
+}
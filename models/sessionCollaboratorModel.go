@@ -0,0 +1,215 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"server/types"
+	"server/utils"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrAlreadySessionCollaborator = errors.New("user is already a collaborator of the session")
+var ErrNotSessionCollaborator = errors.New("user is not a collaborator of the session")
+var ErrCollaboratorNotMember = errors.New("collaborators must be members of the workspace")
+
+// FetchSessionCollaborators lists the collaborators of a session with their
+// profile.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - sessionId: The ID of the session.
+//   - userId: The ID of the current user, who must be its creator or a collaborator.
+//
+// Returns:
+//   - []types.ProcessedSessionCollaborator: The collaborators in the order they were added.
+//   - error: ErrSessionNotFound, ErrSessionAccessDenied, or an error object
+//     if there is an issue retrieving the collaborators.
+func FetchSessionCollaborators(ctx context.Context, sessionId, userId int) ([]types.ProcessedSessionCollaborator, error) {
+	collaborators := []types.ProcessedSessionCollaborator{}
+	err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+		var session types.Session
+		if err := findSession(tx, sessionId, userId, &session, false); err != nil {
+			return err
+		}
+		return sessionCollaborators(tx, &session).Scan(&collaborators).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return collaborators, nil
+}
+
+// AddSessionCollaborator adds a member of the workspace to a session. When
+// the session is active, the member must not have another session at the
+// same time unless input.AllowConflicts is set.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - sessionId: The ID of the session.
+//   - userId: The ID of the current user, who must be its creator or a collaborator.
+//   - input: The user ID or email of the member to add.
+//
+// Returns:
+//   - *types.ProcessedSessionCollaborator: The new collaborator.
+//   - error: ErrSessionNotFound, ErrSessionAccessDenied,
+//     ErrCollaboratorNotMember, ErrAlreadySessionCollaborator,
+//     *SessionConflictError, or an error object if there is an issue saving
+//     the collaborator.
+func AddSessionCollaborator(ctx context.Context, sessionId, userId int, input types.AddSessionCollaboratorPayload) (*types.ProcessedSessionCollaborator, error) {
+	var collaborator types.ProcessedSessionCollaborator
+	var invite *sessionCalendarMail
+	err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+		var session types.Session
+		if err := findSession(tx, sessionId, userId, &session, true); err != nil {
+			return err
+		}
+		userIds, err := resolveSessionCollaborators(tx, session.WorkspaceId, []types.SessionCollaboratorInput{input.SessionCollaboratorInput})
+		if err != nil {
+			return err
+		}
+		if userIds[0] == session.CreatedBy {
+			return ErrAlreadySessionCollaborator
+		}
+		var count int64
+		if err := tx.Model(&types.SessionCollaborator{}).Where("session_id=? AND user_id=?", session.ID, userIds[0]).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadySessionCollaborator
+		}
+		if session.Status && !input.AllowConflicts {
+			schedule := sessionSchedule{Start: session.Datetime, End: session.EndsAt}
+			if err := checkSessionConflicts(tx, session.WorkspaceId, session.ID, schedule, userIds); err != nil {
+				return err
+			}
+		}
+		if err := addSessionCollaborators(tx, &session, userIds); err != nil {
+			return err
+		}
+		if session.Status {
+			if invite, err = newSessionCalendarMail(tx, &session, utils.CALENDAR_METHOD_REQUEST, true, userIds); err != nil {
+				return err
+			}
+		}
+		return sessionCollaborators(tx, &session).Where("sc.user_id = ?", userIds[0]).Scan(&collaborator).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	invite.send()
+	return &collaborator, nil
+}
+
+// RemoveSessionCollaborator removes a collaborator from a session. The
+// creator can remove anyone; collaborators can only remove themselves.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - sessionId: The ID of the session.
+//   - userId: The ID of the current user.
+//   - collaboratorId: The user ID of the collaborator to remove.
+//
+// Returns:
+//   - error: ErrSessionNotFound, ErrSessionAccessDenied,
+//     ErrSessionCreatorRequired, ErrNotSessionCollaborator, or an error
+//     object if there is an issue deleting the collaborator.
+func RemoveSessionCollaborator(ctx context.Context, sessionId, userId, collaboratorId int) error {
+	var cancellation *sessionCalendarMail
+	err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+		var session types.Session
+		if err := findSession(tx, sessionId, userId, &session, true); err != nil {
+			return err
+		}
+		if session.CreatedBy != userId && collaboratorId != userId {
+			return ErrSessionCreatorRequired
+		}
+		// The cancellation is prepared while the collaborator is still an
+		// attendee of the event.
+		if session.Status {
+			session.Sequence++
+			if err := tx.Model(&session).Update("sequence", session.Sequence).Error; err != nil {
+				return err
+			}
+			var err error
+			if cancellation, err = newSessionCalendarMail(tx, &session, utils.CALENDAR_METHOD_CANCEL, false, []int{collaboratorId}); err != nil {
+				return err
+			}
+		}
+		result := tx.Where("session_id=? AND user_id=?", session.ID, collaboratorId).Delete(&types.SessionCollaborator{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotSessionCollaborator
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cancellation.send()
+	return nil
+}
+
+// resolveSessionCollaborators looks up the users named by inputs and makes
+// sure they are all members of the workspace. The IDs are returned in the
+// order of inputs.
+func resolveSessionCollaborators(tx *gorm.DB, workspaceId int, inputs []types.SessionCollaboratorInput) ([]int, error) {
+	userIds := make([]int, 0, len(inputs))
+	for _, input := range inputs {
+		var members []int
+		db := tx.Table(utils.WORKSPACE_USERS_TABLE+" AS wu").
+			Select("wu.user_id").
+			Joins("JOIN "+utils.USERS_TABLE+" AS u ON u.id = wu.user_id").
+			Where("wu.workspace_id = ?", workspaceId)
+		if input.UserId != 0 {
+			db = db.Where("u.id = ?", input.UserId)
+		} else {
+			db = db.Where("lower(u.email) = ?", strings.ToLower(strings.TrimSpace(input.Email)))
+		}
+		if err := db.Limit(1).Pluck("wu.user_id", &members).Error; err != nil {
+			return nil, err
+		}
+		if len(members) == 0 {
+			return nil, ErrCollaboratorNotMember
+		}
+		userIds = append(userIds, members[0])
+	}
+	return userIds, nil
+}
+
+// addSessionCollaborators adds users to a session inside the caller's
+// transaction, skipping the creator and anyone listed twice.
+func addSessionCollaborators(tx *gorm.DB, session *types.Session, userIds []int) error {
+	seen := map[int]bool{session.CreatedBy: true}
+	collaborators := []types.SessionCollaborator{}
+	for _, id := range userIds {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		collaborators = append(collaborators, types.SessionCollaborator{
+			SessionId:   session.ID,
+			WorkspaceId: session.WorkspaceId,
+			UserId:      id,
+			Status:      true,
+		})
+	}
+	if len(collaborators) == 0 {
+		return nil
+	}
+	return tx.Create(&collaborators).Error
+}
+
+// sessionCollaborators selects the collaborators of a session joined with
+// their profile.
+func sessionCollaborators(tx *gorm.DB, session *types.Session) *gorm.DB {
+	return tx.Table(utils.SESSION_COLLABORATORS_TABLE+" AS sc").
+		Select("sc.id, sc.user_id, u.name, u.email, u.avatar, sc.status").
+		Joins("JOIN "+utils.USERS_TABLE+" AS u ON u.id = sc.user_id").
+		Where("sc.session_id = ?", session.ID).
+		Scopes(TenantScope("sc", session.WorkspaceId)).
+		Order("sc.id")
+}
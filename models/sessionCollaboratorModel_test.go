@@ -0,0 +1,58 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"server/config"
+	"server/types"
+	"testing"
+	"time"
+)
+
+func TestAddSessionCollaboratorChecksConflicts(t *testing.T) {
+	testDB(t)
+
+	creator := types.User{Name: "Creator", Email: testEmail(t, "creator")}
+	busy := types.User{Name: "Busy", Email: testEmail(t, "busy")}
+	for _, user := range []*types.User{&creator, &busy} {
+		if err := config.DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	workspace := types.Workspace{Name: "Conflicts", Role: "test", Status: true}
+	if err := config.DB.Create(&workspace).Error; err != nil {
+		t.Fatal(err)
+	}
+	memberships := []types.WorkspaceUser{
+		{WorkspaceId: workspace.ID, UserId: creator.ID, IsOwner: true},
+		{WorkspaceId: workspace.ID, UserId: busy.ID},
+	}
+	if err := config.DB.Create(&memberships).Error; err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().UTC().Add(24 * time.Hour)
+	sessions := []types.Session{
+		{Title: "Booked", WorkspaceId: workspace.ID, CreatedBy: busy.ID, Status: true,
+			Datetime: start, EndsAt: start.Add(time.Hour), DurationMinutes: 60},
+		{Title: "Overlapping", WorkspaceId: workspace.ID, CreatedBy: creator.ID, Status: true,
+			Datetime: start.Add(30 * time.Minute), EndsAt: start.Add(90 * time.Minute), DurationMinutes: 60},
+	}
+	if err := config.DB.Create(&sessions).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithTenant(context.Background(), workspace.ID)
+	input := types.AddSessionCollaboratorPayload{SessionCollaboratorInput: types.SessionCollaboratorInput{UserId: busy.ID}}
+	var conflictErr *SessionConflictError
+	if _, err := AddSessionCollaborator(ctx, sessions[1].ID, creator.ID, input); !errors.As(err, &conflictErr) {
+		t.Fatalf("adding a member with an overlapping session: error = %v, want a SessionConflictError", err)
+	}
+	if len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0].SessionId != sessions[0].ID {
+		t.Errorf("conflicts = %+v, want session %d", conflictErr.Conflicts, sessions[0].ID)
+	}
+
+	input.AllowConflicts = true
+	if _, err := AddSessionCollaborator(ctx, sessions[1].ID, creator.ID, input); err != nil {
+		t.Fatalf("adding with allow_conflicts: %v", err)
+	}
+}
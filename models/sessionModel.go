@@ -0,0 +1,410 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"server/types"
+	"server/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+var ErrSessionAccessDenied = errors.New("user is neither the creator nor a collaborator of the session")
+var ErrSessionCreatorRequired = errors.New("only the creator of the session can do this")
+
+// SessionListSpec holds the fields sessions can be filtered and sorted on.
+// The sortable fields are indexed.
+var SessionListSpec = types.ListQuerySpec{
+	Fields: map[string]types.ListField{
+		"id":           {Column: "id", Type: utils.LIST_FIELD_INT, Sort: true},
+		"stage":        {Column: "stage", Type: utils.LIST_FIELD_STRING, Filter: true, Sort: true},
+		"status":       {Column: "status", Type: utils.LIST_FIELD_BOOL, Filter: true},
+		"datetime":     {Column: "datetime", Type: utils.LIST_FIELD_TIME, Filter: true, Sort: true},
+		"created_by":   {Column: "created_by", Type: utils.LIST_FIELD_INT, Filter: true, Sort: true},
+		"created_at":   {Column: "created_at", Type: utils.LIST_FIELD_TIME, Filter: true, Sort: true},
+		"collaborator": {Type: utils.LIST_FIELD_INT, Filter: true},
+	},
+	DefaultSort:  []types.ListSort{{Field: "datetime", Desc: true}},
+	DefaultLimit: utils.SESSION_LIST_DEFAULT_LIMIT,
+	MaxLimit:     utils.SESSION_LIST_MAX_LIMIT,
+}
+
+// CreateSession creates a session in the workspace of ctx with its
+// collaborators, counting it against the workspace's plan. Unless payload
+// allows conflicts, it fails when the creator or a collaborator is already in
+// another session at that time.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - userId: The ID of the user creating the session.
+//   - payload: The session to create.
+//
+// Returns:
+//   - *types.ProcessedSessionResponse: The new session.
+//   - error: A *QuotaExceededError when the plan has no sessions left,
+//     ErrCollaboratorNotMember, a *SessionConflictError, or an error object
+//     if there is an issue saving the session.
+func CreateSession(ctx context.Context, userId int, payload types.SessionPayload) (*types.ProcessedSessionResponse, error) {
+	workspaceId, _ := TenantFromContext(ctx)
+	var response *types.ProcessedSessionResponse
+	var invite *sessionCalendarMail
+	err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+		if err := ReserveSessionQuota(tx, workspaceId); err != nil {
+			return err
+		}
+		schedule, err := newSessionSchedule(tx, userId, payload.Datetime, payload.DurationMinutes, payload.Timezone)
+		if err != nil {
+			return err
+		}
+		// The session only exists if all of its collaborators could be added.
+		userIds, err := resolveSessionCollaborators(tx, workspaceId, payload.Collaborators)
+		if err != nil {
+			return err
+		}
+		if !payload.AllowConflicts {
+			if err := checkSessionConflicts(tx, workspaceId, 0, schedule, append([]int{userId}, userIds...)); err != nil {
+				return err
+			}
+		}
+		session := types.Session{
+			Title:           payload.Title,
+			Objective:       payload.Objective,
+			Stage:           payload.Stage,
+			Datetime:        schedule.Start,
+			EndsAt:          schedule.End,
+			Timezone:        schedule.Timezone,
+			DurationMinutes: payload.DurationMinutes,
+			Status:          true,
+			CreatedBy:       userId,
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		if err := addSessionCollaborators(tx, &session, userIds); err != nil {
+			return err
+		}
+		if invite, err = newSessionCalendarMail(tx, &session, utils.CALENDAR_METHOD_REQUEST, true, nil); err != nil {
+			return err
+		}
+		response, err = processedSession(tx, &session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	invite.send()
+	return response, nil
+}
+
+// FetchSessions lists the sessions of the workspace of ctx that the user
+// created or collaborates on, one page at a time.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - userId: The ID of the current user.
+//   - query: The filters, sort order and page, parsed with SessionListSpec.
+//
+// Returns:
+//   - *types.SessionListResponse: The page of sessions and the cursor of the next page.
+//   - error: ErrInvalidCursor, or an error object if there is an issue retrieving the sessions.
+func FetchSessions(ctx context.Context, userId int, query types.ListQuery) (*types.SessionListResponse, error) {
+	response := types.SessionListResponse{Sessions: []types.ProcessedSessionResponse{}}
+	err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+		db := tx.Model(&types.Session{}).Where("created_by = ? OR id IN (?)", userId,
+			tx.Model(&types.SessionCollaborator{}).Select("session_id").Where("user_id = ?", userId))
+		if collaborator, ok := query.Filter("collaborator", utils.LIST_OP_EQ); ok {
+			db = db.Where("id IN (?)", tx.Model(&types.SessionCollaborator{}).Select("session_id").Where("user_id = ?", collaborator))
+		}
+
+		sessions, nextCursor, err := ListPage(db, query, SessionListSpec, sessionListValue)
+		if err != nil {
+			return err
+		}
+		response.NextCursor = nextCursor
+		response.Sessions, err = processedSessions(tx, sessions)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// FetchSession fetches a session of the workspace of ctx with its
+// collaborators.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - id: The ID of the session.
+//   - userId: The ID of the current user, who must be its creator or a collaborator.
+//
+// Returns:
+//   - *types.ProcessedSessionResponse: The session.
+//   - error: ErrSessionNotFound, ErrSessionAccessDenied, or an error
+//     object if there is an issue retrieving the session.
+func FetchSession(ctx context.Context, id, userId int) (*types.ProcessedSessionResponse, error) {
+	var response *types.ProcessedSessionResponse
+	err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+		var session types.Session
+		if err := findSession(tx, id, userId, &session, false); err != nil {
+			return err
+		}
+		var err error
+		response, err = processedSession(tx, &session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// UpdateSession updates the fields that are set in the payload. When the
+// session is moved, lengthened or reactivated, it fails on conflicts with the
+// other sessions of its participants unless payload allows them.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - id: The ID of the session.
+//   - userId: The ID of the current user, who must be its creator or a collaborator.
+//   - payload: The fields to change. Nil fields are left untouched.
+//
+// Returns:
+//   - *types.ProcessedSessionResponse: The updated session.
+//   - error: ErrSessionNotFound, ErrSessionAccessDenied, a
+//     *SessionConflictError, or an error object if there is an issue saving
+//     the session.
+func UpdateSession(ctx context.Context, id, userId int, payload types.UpdateSessionPayload) (*types.ProcessedSessionResponse, error) {
+	updates := map[string]interface{}{}
+	if payload.Title != nil {
+		updates["title"] = *payload.Title
+	}
+	if payload.Objective != nil {
+		updates["objective"] = *payload.Objective
+	}
+	if payload.Stage != nil {
+		updates["stage"] = *payload.Stage
+	}
+	if payload.Status != nil {
+		updates["status"] = *payload.Status
+	}
+
+	var response *types.ProcessedSessionResponse
+	var update *sessionCalendarMail
+	err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+		var session types.Session
+		if err := findSession(tx, id, userId, &session, true); err != nil {
+			return err
+		}
+		if err := updateSessionSchedule(tx, &session, payload, updates); err != nil {
+			return err
+		}
+		wasActive := session.Status
+		revised := sessionEventRevised(updates, wasActive)
+		if revised {
+			updates["sequence"] = session.Sequence + 1
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&session).Updates(updates).Error; err != nil {
+				return err
+			}
+			if err := tx.First(&session, session.ID).Error; err != nil {
+				return err
+			}
+		}
+		var err error
+		if revised {
+			method := utils.CALENDAR_METHOD_REQUEST
+			if !session.Status {
+				method = utils.CALENDAR_METHOD_CANCEL
+			}
+			if update, err = newSessionCalendarMail(tx, &session, method, false, nil); err != nil {
+				return err
+			}
+		}
+		response, err = processedSession(tx, &session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	update.send()
+	return response, nil
+}
+
+// DeleteSession deletes a session together with its collaborators and
+// attachments, and gives its quota back to the workspace. Attachment files
+// are removed from S3 once the rows are gone.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - id: The ID of the session.
+//   - userId: The ID of the current user, who must be its creator.
+//
+// Returns:
+//   - error: ErrSessionNotFound, ErrSessionAccessDenied,
+//     ErrSessionCreatorRequired, or an error object if there is an issue
+//     deleting the session.
+func DeleteSession(ctx context.Context, id, userId int) error {
+	var objectKeys []string
+	var cancellation *sessionCalendarMail
+	err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+		var session types.Session
+		if err := findSession(tx, id, userId, &session, true); err != nil {
+			return err
+		}
+		if session.CreatedBy != userId {
+			return ErrSessionCreatorRequired
+		}
+		// Collaborators who still have the session in their calendar get a
+		// cancellation.
+		if session.Status {
+			session.Sequence++
+			var err error
+			if cancellation, err = newSessionCalendarMail(tx, &session, utils.CALENDAR_METHOD_CANCEL, false, nil); err != nil {
+				return err
+			}
+		}
+
+		var attachments []types.SessionAttachment
+		if err := tx.Where("session_id=?", session.ID).Find(&attachments).Error; err != nil {
+			return err
+		}
+		var storageBytes int64
+		for _, attachment := range attachments {
+			storageBytes += attachment.Size
+			if key, ok := utils.S3KeyFromUrl(attachment.Url); ok {
+				objectKeys = append(objectKeys, key)
+			}
+		}
+
+		if err := tx.Where("session_id=?", session.ID).Delete(&types.SessionCollaborator{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id=?", session.ID).Delete(&types.SessionAttachment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&session).Error; err != nil {
+			return err
+		}
+		if err := ReleaseStorageQuota(tx, session.WorkspaceId, storageBytes); err != nil {
+			return err
+		}
+		return ReleaseSessionQuota(tx, session.WorkspaceId, 1)
+	})
+	if err != nil {
+		return err
+	}
+
+	cancellation.send()
+	deleteSessionAttachmentFiles(id, objectKeys)
+	return nil
+}
+
+// findSession loads a session the user created or collaborates on, locking
+// it for the rest of the transaction when lock is set.
+func findSession(tx *gorm.DB, id, userId int, session *types.Session, lock bool) error {
+	query := tx
+	if lock {
+		query = tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	err := query.First(session, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if session.CreatedBy == userId {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&types.SessionCollaborator{}).Where("session_id=? AND user_id=?", session.ID, userId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSessionAccessDenied
+	}
+	return nil
+}
+
+// processedSession loads the collaborators of a session for the response.
+func processedSession(tx *gorm.DB, session *types.Session) (*types.ProcessedSessionResponse, error) {
+	responses, err := processedSessions(tx, []types.Session{*session})
+	if err != nil {
+		return nil, err
+	}
+	return &responses[0], nil
+}
+
+// processedSessions loads the collaborators of sessions of one workspace for
+// the response.
+func processedSessions(tx *gorm.DB, sessions []types.Session) ([]types.ProcessedSessionResponse, error) {
+	responses := make([]types.ProcessedSessionResponse, 0, len(sessions))
+	if len(sessions) == 0 {
+		return responses, nil
+	}
+	sessionIds := make([]int, 0, len(sessions))
+	for _, session := range sessions {
+		sessionIds = append(sessionIds, session.ID)
+	}
+
+	var rows []struct {
+		types.CollaboratorResponse
+		SessionId int
+	}
+	err := tx.Table(utils.SESSION_COLLABORATORS_TABLE+" AS sc").
+		Select("sc.session_id, sc.id, sc.user_id, u.name, u.email, u.avatar, sc.status").
+		Joins("JOIN "+utils.USERS_TABLE+" AS u ON u.id = sc.user_id").
+		Where("sc.session_id IN ?", sessionIds).
+		Scopes(TenantScope("sc", sessions[0].WorkspaceId)).
+		Order("sc.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	collaborators := map[int][]types.CollaboratorResponse{}
+	for _, row := range rows {
+		collaborators[row.SessionId] = append(collaborators[row.SessionId], row.CollaboratorResponse)
+	}
+
+	for _, session := range sessions {
+		sessionCollaborators := collaborators[session.ID]
+		if sessionCollaborators == nil {
+			sessionCollaborators = []types.CollaboratorResponse{}
+		}
+		location := sessionLocation(session.Timezone)
+		responses = append(responses, types.ProcessedSessionResponse{
+			ID:              session.ID,
+			Title:           session.Title,
+			Objective:       session.Objective,
+			Stage:           session.Stage,
+			Datetime:        session.Datetime.In(location),
+			EndsAt:          session.EndsAt.In(location),
+			Timezone:        session.Timezone,
+			DurationMinutes: session.DurationMinutes,
+			Status:          session.Status,
+			CreatedBy:       session.CreatedBy,
+			Collaborators:   sessionCollaborators,
+		})
+	}
+	return responses, nil
+}
+
+// sessionListValue returns the value of a sortable field for the next cursor.
+func sessionListValue(session *types.Session, field string) interface{} {
+	switch field {
+	case "stage":
+		return session.Stage
+	case "datetime":
+		return session.Datetime
+	case "created_by":
+		return session.CreatedBy
+	case "created_at":
+		return session.CreatedAt
+	default:
+		return session.ID
+	}
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"server/config"
+	"server/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// conflictFixture is a workspace whose member busy is booked in an active
+// session from start to start+1h.
+type conflictFixture struct {
+	ctx     context.Context
+	creator types.User
+	busy    types.User
+	booked  types.Session
+	start   time.Time
+}
+
+func newConflictFixture(t *testing.T) *conflictFixture {
+	t.Helper()
+	f := &conflictFixture{
+		creator: types.User{Name: "Creator", Email: testEmail(t, "creator")},
+		busy:    types.User{Name: "Busy", Email: testEmail(t, "busy")},
+		start:   time.Now().UTC().Add(24 * time.Hour).Truncate(time.Minute),
+	}
+	for _, user := range []*types.User{&f.creator, &f.busy} {
+		if err := config.DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	workspace := types.Workspace{Name: "Conflicts", Role: "test", Status: true}
+	if err := config.DB.Create(&workspace).Error; err != nil {
+		t.Fatal(err)
+	}
+	memberships := []types.WorkspaceUser{
+		{WorkspaceId: workspace.ID, UserId: f.creator.ID, IsOwner: true},
+		{WorkspaceId: workspace.ID, UserId: f.busy.ID},
+	}
+	if err := config.DB.Create(&memberships).Error; err != nil {
+		t.Fatal(err)
+	}
+	f.booked = types.Session{Title: "Booked", WorkspaceId: workspace.ID, CreatedBy: f.busy.ID, Status: true,
+		Datetime: f.start, EndsAt: f.start.Add(time.Hour), DurationMinutes: 60}
+	if err := config.DB.Create(&f.booked).Error; err != nil {
+		t.Fatal(err)
+	}
+	f.ctx = WithTenant(context.Background(), workspace.ID)
+	return f
+}
+
+// payload books busy with the creator at the given time.
+func (f *conflictFixture) payload(at time.Time, minutes int) types.SessionPayload {
+	return types.SessionPayload{
+		Title:           "Planning",
+		Objective:       "Plan",
+		Stage:           "draft",
+		Datetime:        at.Format(time.RFC3339),
+		DurationMinutes: minutes,
+		Collaborators:   []types.SessionCollaboratorInput{{UserId: f.busy.ID}},
+	}
+}
+
+func TestCreateSessionChecksConflicts(t *testing.T) {
+	testDB(t)
+
+	tests := []struct {
+		name     string
+		offset   time.Duration
+		minutes  int
+		allow    bool
+		inactive bool
+		conflict bool
+	}{
+		{name: "ends as the booking starts", offset: -time.Hour, minutes: 60},
+		{name: "starts as the booking ends", offset: time.Hour, minutes: 30},
+		{name: "overlaps the start", offset: -30 * time.Minute, minutes: 60, conflict: true},
+		{name: "inside the booking", offset: 15 * time.Minute, minutes: 15, conflict: true},
+		{name: "around the booking", offset: -time.Hour, minutes: 180, conflict: true},
+		{name: "allow_conflicts", offset: 0, minutes: 60, allow: true},
+		{name: "booking is inactive", offset: 0, minutes: 60, inactive: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newConflictFixture(t)
+			if test.inactive {
+				if err := config.DB.Model(&f.booked).Update("status", false).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			payload := f.payload(f.start.Add(test.offset), test.minutes)
+			payload.AllowConflicts = test.allow
+
+			_, err := CreateSession(f.ctx, f.creator.ID, payload)
+			var conflictErr *SessionConflictError
+			if !test.conflict {
+				if err != nil {
+					t.Fatalf("error = %v, want none", err)
+				}
+				return
+			}
+			if !errors.As(err, &conflictErr) {
+				t.Fatalf("error = %v, want a SessionConflictError", err)
+			}
+			if len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0].SessionId != f.booked.ID || conflictErr.Conflicts[0].UserId != f.busy.ID {
+				t.Errorf("conflicts = %+v, want user %d in session %d", conflictErr.Conflicts, f.busy.ID, f.booked.ID)
+			}
+		})
+	}
+}
+
+func TestConcurrentBookingsOfOneUserConflict(t *testing.T) {
+	testDB(t)
+	f := newConflictFixture(t)
+	own := types.Session{Title: "Own", WorkspaceId: f.booked.WorkspaceId, CreatedBy: f.busy.ID, Status: true,
+		Datetime: f.start.Add(4 * time.Hour), EndsAt: f.start.Add(5 * time.Hour), DurationMinutes: 60}
+	if err := config.DB.Create(&own).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Creating reserves quota, which already serializes creates within a
+	// workspace, so race a create against a move of another session.
+	at := f.start.Add(2 * time.Hour)
+	moveTo := at.Format(time.RFC3339)
+
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, errs[0] = CreateSession(f.ctx, f.creator.ID, f.payload(at, 60))
+	}()
+	go func() {
+		defer wg.Done()
+		_, errs[1] = UpdateSession(f.ctx, own.ID, f.busy.ID, types.UpdateSessionPayload{Datetime: &moveTo})
+	}()
+	wg.Wait()
+
+	var succeeded, conflicts int
+	for _, err := range errs {
+		var conflictErr *SessionConflictError
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.As(err, &conflictErr):
+			conflicts++
+		default:
+			t.Fatal(err)
+		}
+	}
+	if succeeded != 1 || conflicts != 1 {
+		t.Errorf("%d bookings succeeded and %d conflicted, want 1 and 1", succeeded, conflicts)
+	}
+}
+
+func TestUpdateSessionChecksConflicts(t *testing.T) {
+	testDB(t)
+
+	title := "Renamed"
+	active, inactive := true, false
+	minutes := func(n int) *int { return &n }
+	after := func(d time.Duration) *time.Duration { return &d }
+	tests := []struct {
+		name string
+		// The session busy owns before the update, relative to the booking.
+		offset   time.Duration
+		inactive bool
+		// The update, with datetime relative to the booking.
+		move     *time.Duration
+		duration *int
+		title    *string
+		status   *bool
+		allow    bool
+		conflict bool
+	}{
+		{name: "moved onto the booking", offset: 2 * time.Hour, move: after(30 * time.Minute), conflict: true},
+		{name: "moved to touch the booking", offset: 2 * time.Hour, move: after(time.Hour)},
+		{name: "lengthened into the booking", offset: -2 * time.Hour, duration: minutes(150), conflict: true},
+		{name: "lengthened over its own time", offset: 2 * time.Hour, duration: minutes(120)},
+		{name: "moved with allow_conflicts", offset: 2 * time.Hour, move: after(0), allow: true},
+		{name: "reactivated over the booking", offset: 30 * time.Minute, inactive: true, status: &active, conflict: true},
+		{name: "reactivated with allow_conflicts", offset: 30 * time.Minute, inactive: true, status: &active, allow: true},
+		{name: "deactivated", offset: 30 * time.Minute, status: &inactive},
+		{name: "renamed while overlapping", offset: 30 * time.Minute, title: &title},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newConflictFixture(t)
+			own := types.Session{Title: "Own", WorkspaceId: f.booked.WorkspaceId, CreatedBy: f.busy.ID, Status: true,
+				Datetime: f.start.Add(test.offset), EndsAt: f.start.Add(test.offset + time.Hour), DurationMinutes: 60}
+			if err := config.DB.Create(&own).Error; err != nil {
+				t.Fatal(err)
+			}
+			if test.inactive {
+				if err := config.DB.Model(&own).Update("status", false).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			payload := types.UpdateSessionPayload{Title: test.title, Status: test.status, DurationMinutes: test.duration, AllowConflicts: test.allow}
+			if test.move != nil {
+				at := f.start.Add(*test.move).Format(time.RFC3339)
+				payload.Datetime = &at
+			}
+			_, err := UpdateSession(f.ctx, own.ID, f.busy.ID, payload)
+			var conflictErr *SessionConflictError
+			if !test.conflict {
+				if err != nil {
+					t.Fatalf("error = %v, want none", err)
+				}
+				return
+			}
+			if !errors.As(err, &conflictErr) {
+				t.Fatalf("error = %v, want a SessionConflictError", err)
+			}
+			for _, conflict := range conflictErr.Conflicts {
+				if conflict.SessionId == own.ID {
+					t.Errorf("session %d conflicts with itself", own.ID)
+				}
+			}
+		})
+	}
+}
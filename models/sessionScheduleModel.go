@@ -0,0 +1,209 @@
+package models
+
+import (
+	"fmt"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SessionConflictError is returned when a session would overlap another
+// active session of one of its participants and conflicts were not allowed.
+type SessionConflictError struct {
+	Conflicts []types.SessionConflict
+}
+
+func (e *SessionConflictError) Error() string {
+	return fmt.Sprintf("session overlaps %d other session bookings of its participants", len(e.Conflicts))
+}
+
+// sessionSchedule is when a session takes place.
+type sessionSchedule struct {
+	Start    time.Time
+	End      time.Time
+	Timezone string
+}
+
+// newSessionSchedule parses an RFC 3339 start time. An empty timezone falls
+// back to the time zone of the user, then to UTC.
+func newSessionSchedule(tx *gorm.DB, userId int, datetime string, durationMinutes int, timezone string) (sessionSchedule, error) {
+	start, err := time.Parse(time.RFC3339, datetime)
+	if err != nil {
+		return sessionSchedule{}, err
+	}
+	if timezone == "" {
+		var user types.User
+		if err := tx.Select("timezone").First(&user, userId).Error; err != nil {
+			return sessionSchedule{}, err
+		}
+		timezone = user.Timezone
+	}
+	if _, err := time.LoadLocation(timezone); err != nil || timezone == "" {
+		timezone = utils.SESSION_DEFAULT_TIMEZONE
+	}
+	start = start.UTC()
+	return sessionSchedule{
+		Start:    start,
+		End:      start.Add(time.Duration(durationMinutes) * time.Minute),
+		Timezone: timezone,
+	}, nil
+}
+
+// checkSessionConflicts fails with a *SessionConflictError when any of the
+// users is already in another active session of the workspace that overlaps
+// the schedule. Sessions that merely touch, one ending as the other starts,
+// don't overlap.
+//
+// The users are locked until tx ends, so two transactions booking the same
+// person check one after the other instead of both finding no conflict. They
+// are locked in id order to keep concurrent bookings from deadlocking.
+func checkSessionConflicts(tx *gorm.DB, workspaceId, sessionId int, schedule sessionSchedule, userIds []int) error {
+	var locked []int
+	err := tx.Model(&types.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", userIds).Order("id").Pluck("id", &locked).Error
+	if err != nil {
+		return err
+	}
+
+	var conflicts []types.SessionConflict
+	err = tx.Table(utils.SESSIONS_TABLE+" AS s").
+		Select("DISTINCT u.id AS user_id, u.name, s.id AS session_id, s.datetime, s.ends_at").
+		Joins("LEFT JOIN "+utils.SESSION_COLLABORATORS_TABLE+" AS sc ON sc.session_id = s.id").
+		Joins("JOIN "+utils.USERS_TABLE+" AS u ON u.id = s.created_by OR u.id = sc.user_id").
+		Where("u.id IN ?", userIds).
+		Where("s.id <> ? AND s.status AND s.datetime < ? AND s.ends_at > ?", sessionId, schedule.End, schedule.Start).
+		Scopes(TenantScope("s", workspaceId)).
+		Order("s.datetime, s.id, u.id").
+		Scan(&conflicts).Error
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &SessionConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// updateSessionSchedule adds the schedule fields of payload to updates. When
+// the session is moved, lengthened or reactivated, the new schedule is
+// checked for conflicts unless payload allows them.
+func updateSessionSchedule(tx *gorm.DB, session *types.Session, payload types.UpdateSessionPayload, updates map[string]interface{}) error {
+	if payload.Timezone != nil {
+		timezone := *payload.Timezone
+		if timezone == "" {
+			timezone = utils.SESSION_DEFAULT_TIMEZONE
+		}
+		updates["timezone"] = timezone
+	}
+
+	schedule := sessionSchedule{Start: session.Datetime, End: session.EndsAt}
+	durationMinutes := session.DurationMinutes
+	if payload.Datetime != nil {
+		start, err := time.Parse(time.RFC3339, *payload.Datetime)
+		if err != nil {
+			return err
+		}
+		schedule.Start = start.UTC()
+	}
+	if payload.DurationMinutes != nil {
+		durationMinutes = *payload.DurationMinutes
+	}
+	schedule.End = schedule.Start.Add(time.Duration(durationMinutes) * time.Minute)
+
+	moved := !schedule.Start.Equal(session.Datetime) || !schedule.End.Equal(session.EndsAt)
+	if moved {
+		updates["datetime"] = schedule.Start
+		updates["ends_at"] = schedule.End
+		updates["duration_minutes"] = durationMinutes
+	}
+	active := session.Status
+	if payload.Status != nil {
+		active = *payload.Status
+	}
+	if !active || payload.AllowConflicts || !(moved || !session.Status) {
+		return nil
+	}
+	userIds, err := sessionParticipants(tx, session)
+	if err != nil {
+		return err
+	}
+	return checkSessionConflicts(tx, session.WorkspaceId, session.ID, schedule, userIds)
+}
+
+// sessionEventRevised reports whether updates change what calendars show
+// for a session, which is sent to them as a new revision. Changes to an
+// inactive session that stays inactive aren't.
+func sessionEventRevised(updates map[string]interface{}, wasActive bool) bool {
+	if status, ok := updates["status"].(bool); ok && status != wasActive {
+		return true
+	}
+	if !wasActive {
+		return false
+	}
+	for _, field := range []string{"title", "objective", "datetime", "ends_at"} {
+		if _, ok := updates[field]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionParticipants returns the creator and the collaborators of a session.
+func sessionParticipants(tx *gorm.DB, session *types.Session) ([]int, error) {
+	var userIds []int
+	if err := tx.Model(&types.SessionCollaborator{}).Where("session_id=?", session.ID).Pluck("user_id", &userIds).Error; err != nil {
+		return nil, err
+	}
+	return append([]int{session.CreatedBy}, userIds...), nil
+}
+
+// sessionLocation returns the location of a session's time zone.
+func sessionLocation(timezone string) *time.Location {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// migrateSessionSchedule converts sessions stored before start times were
+// timestamps: the text datetime column becomes timestamptz, read as UTC when
+// it has no offset, and duration becomes duration_minutes. Values that don't
+// look like a date are cleared. It does nothing on an up to date schema.
+func migrateSessionSchedule() error {
+	migrator := config.DB.Migrator()
+	if !migrator.HasTable(&types.Session{}) {
+		return nil
+	}
+	if migrator.HasColumn(&types.Session{}, "duration") && !migrator.HasColumn(&types.Session{}, "duration_minutes") {
+		if err := migrator.RenameColumn(&types.Session{}, "duration", "duration_minutes"); err != nil {
+			return err
+		}
+	}
+
+	var dataType string
+	err := config.DB.Raw("SELECT data_type FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = 'datetime'", utils.SESSIONS_TABLE).Scan(&dataType).Error
+	if err != nil || dataType == "timestamp with time zone" || dataType == "" {
+		return err
+	}
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SET LOCAL TimeZone = 'UTC'").Error; err != nil {
+			return err
+		}
+		return tx.Exec("ALTER TABLE " + utils.SESSIONS_TABLE + " ALTER COLUMN datetime TYPE timestamptz USING " +
+			"CASE WHEN datetime ~ '^\\d{4}-\\d{2}-\\d{2}([T ]\\d{2}:\\d{2}(:\\d{2}(\\.\\d+)?)?)?(Z|[+-]\\d{2}(:?\\d{2})?)?$' THEN datetime::timestamptz END").Error
+	})
+}
+
+// backfillSessionEnds sets ends_at on sessions migrated by
+// migrateSessionSchedule.
+func backfillSessionEnds() error {
+	return SystemTransaction(func(tx *gorm.DB) error {
+		return tx.Exec("UPDATE " + utils.SESSIONS_TABLE + " SET ends_at = datetime + duration_minutes * interval '1 minute' " +
+			"WHERE ends_at IS NULL AND datetime IS NOT NULL").Error
+	})
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"server/config"
+	"server/types"
+	"server/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNotWorkspaceMember = errors.New("user is not a member of the workspace")
+
+// DefaultUserSettings returns the settings of a user who hasn't changed any.
+//
+// Returns:
+//   - types.UserSettings: The default settings.
+func DefaultUserSettings() types.UserSettings {
+	return types.UserSettings{
+		Timezone:   utils.USER_SETTINGS_DEFAULT_TIMEZONE,
+		DateFormat: utils.USER_SETTINGS_DEFAULT_DATE_FORMAT,
+		TimeFormat: utils.USER_SETTINGS_DEFAULT_TIME_FORMAT,
+		WeekStart:  utils.USER_SETTINGS_DEFAULT_WEEK_START,
+		Notifications: types.NotificationSettings{
+			SessionInvites:   true,
+			SessionReminders: true,
+			SessionUpdates:   true,
+			WorkspaceInvites: true,
+			ProductUpdates:   false,
+		},
+	}
+}
+
+// UserSettingsFor resolves the settings of an already loaded user. The
+// default workspace is the user's primary membership, so it is left unset
+// here; FetchUserSettings fills it in.
+//
+// Parameters:
+//   - user: The user whose settings to resolve.
+//
+// Returns:
+//   - types.UserSettings: The user's settings with defaults for anything unset.
+func UserSettingsFor(user *types.User) types.UserSettings {
+	settings := DefaultUserSettings()
+	if len(user.Settings) > 0 {
+		// Stored values were validated on the way in; anything that no
+		// longer fits the schema keeps its default.
+		if data, err := json.Marshal(user.Settings); err == nil {
+			json.Unmarshal(data, &settings)
+		}
+	}
+	// Earlier versions stored it; the primary membership is what login uses.
+	settings.DefaultWorkspaceId = nil
+	// The timezone lives on the profile so there is one source of truth.
+	settings.Timezone = utils.USER_SETTINGS_DEFAULT_TIMEZONE
+	if user.Timezone != "" {
+		settings.Timezone = user.Timezone
+	}
+	return settings
+}
+
+// FetchUserSettings fetches a user's settings with defaults filled in.
+//
+// Parameters:
+//   - userId: The ID of the user.
+//
+// Returns:
+//   - types.UserSettings: The user's settings.
+//   - error: An error object if there is an issue retrieving the user.
+func FetchUserSettings(userId int) (types.UserSettings, error) {
+	user, err := FetchUser(userId)
+	if err != nil {
+		return types.UserSettings{}, err
+	}
+	settings := UserSettingsFor(user)
+	workspaceUser, err := FetchPrimaryWorkspaceUser(userId)
+	if err != nil {
+		return types.UserSettings{}, err
+	}
+	if workspaceUser != nil {
+		settings.DefaultWorkspaceId = &workspaceUser.WorkspaceId
+	}
+	return settings, nil
+}
+
+// UpdateUserSettings changes the settings that are set in the payload. The
+// default workspace is saved as the user's primary membership, which login
+// signs the user into.
+//
+// Parameters:
+//   - userId: The ID of the user.
+//   - payload: The settings to change. Nil fields are left untouched.
+//
+// Returns:
+//   - types.UserSettings: The user's settings after the change.
+//   - error: ErrNotWorkspaceMember when the default workspace isn't one of the
+//     user's, or an error object if there is an issue saving the settings.
+func UpdateUserSettings(userId int, payload types.UserSettingsPayload) (types.UserSettings, error) {
+	var user types.User
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userId).Error; err != nil {
+			return err
+		}
+
+		if payload.DefaultWorkspaceId != nil {
+			if _, err := setPrimaryWorkspace(tx, userId, *payload.DefaultWorkspaceId); err != nil {
+				return err
+			}
+		}
+
+		changes, err := settingsChanges(payload)
+		if err != nil {
+			return err
+		}
+		if user.Settings == nil {
+			user.Settings = map[string]interface{}{}
+		}
+		mergeSettings(user.Settings, changes)
+		// Stored by earlier versions.
+		delete(user.Settings, "default_workspace_id")
+
+		if payload.Timezone != nil {
+			user.Timezone = *payload.Timezone
+		}
+		return tx.Model(&user).Select("settings", "timezone").Updates(&user).Error
+	})
+	if err != nil {
+		return types.UserSettings{}, err
+	}
+	return FetchUserSettings(userId)
+}
+
+// settingsChanges turns a payload into the nested map of values to store.
+func settingsChanges(payload types.UserSettingsPayload) (map[string]interface{}, error) {
+	// Both are stored outside of the settings.
+	payload.Timezone = nil
+	payload.DefaultWorkspaceId = nil
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]interface{}{}
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// mergeSettings copies changes into stored, merging nested objects key by key.
+func mergeSettings(stored, changes map[string]interface{}) {
+	for key, value := range changes {
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			stored[key] = value
+			continue
+		}
+		existing, ok := stored[key].(map[string]interface{})
+		if !ok {
+			existing = map[string]interface{}{}
+			stored[key] = existing
+		}
+		mergeSettings(existing, nested)
+	}
+}
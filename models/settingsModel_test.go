@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"server/config"
+	"server/types"
+	"testing"
+)
+
+func TestDefaultWorkspaceIsThePrimaryMembership(t *testing.T) {
+	testDB(t)
+
+	user := types.User{Name: "Settings", Email: testEmail(t, "settings")}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	first := types.Workspace{Name: "First", Role: "test", Status: true}
+	second := types.Workspace{Name: "Second", Role: "test", Status: true}
+	other := types.Workspace{Name: "Other", Role: "test", Status: true}
+	for _, w := range []*types.Workspace{&first, &second, &other} {
+		if err := config.DB.Create(w).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	memberships := []types.WorkspaceUser{
+		{WorkspaceId: first.ID, UserId: user.ID, IsPrimary: true},
+		{WorkspaceId: second.ID, UserId: user.ID},
+	}
+	if err := config.DB.Create(&memberships).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := UpdateUserSettings(user.ID, types.UserSettingsPayload{DefaultWorkspaceId: &second.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.DefaultWorkspaceId == nil || *settings.DefaultWorkspaceId != second.ID {
+		t.Errorf("default_workspace_id = %v, want %d", settings.DefaultWorkspaceId, second.ID)
+	}
+	primary, err := FetchPrimaryWorkspaceUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary == nil || primary.WorkspaceId != second.ID {
+		t.Fatalf("login workspace = %+v, want workspace %d", primary, second.ID)
+	}
+
+	if _, err := UpdateUserSettings(user.ID, types.UserSettingsPayload{DefaultWorkspaceId: &other.ID}); !errors.Is(err, ErrNotWorkspaceMember) {
+		t.Errorf("default workspace the user isn't a member of: error = %v, want ErrNotWorkspaceMember", err)
+	}
+
+	none := 0
+	settings, err = UpdateUserSettings(user.ID, types.UserSettingsPayload{DefaultWorkspaceId: &none})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.DefaultWorkspaceId != nil {
+		t.Errorf("default_workspace_id after clearing = %d, want none", *settings.DefaultWorkspaceId)
+	}
+}
@@ -0,0 +1,243 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"server/config"
+	"server/utils"
+	"slices"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type tenantContextKey struct{}
+type tenantUserContextKey struct{}
+
+var ErrMissingTenant = errors.New("no workspace in context")
+
+// WithTenant returns a context that scopes database access to a workspace.
+// Queries run with TenantDB(ctx) on the tables in utils.TENANT_SCOPED_TABLES
+// are filtered to the workspace, and rows created through it are assigned to
+// it.
+//
+// Parameters:
+//   - ctx: The parent context, usually the request context.
+//   - workspaceId: The ID of the active workspace.
+//
+// Returns:
+//   - context.Context: The scoped context.
+func WithTenant(ctx context.Context, workspaceId int) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, workspaceId)
+}
+
+// TenantFromContext returns the workspace a context is scoped to.
+//
+// Parameters:
+//   - ctx: The context.
+//
+// Returns:
+//   - int: The ID of the workspace.
+//   - bool: False when the context isn't scoped to a workspace.
+func TenantFromContext(ctx context.Context) (int, bool) {
+	workspaceId, ok := ctx.Value(tenantContextKey{}).(int)
+	return workspaceId, ok && workspaceId != 0
+}
+
+// WithTenantUser returns a context that records the user acting in the
+// workspace. TenantTransaction passes it on to Postgres.
+//
+// Parameters:
+//   - ctx: The parent context.
+//   - userId: The ID of the current user.
+//
+// Returns:
+//   - context.Context: The context carrying the user.
+func WithTenantUser(ctx context.Context, userId int) context.Context {
+	return context.WithValue(ctx, tenantUserContextKey{}, userId)
+}
+
+// TenantTransaction runs fn in a transaction scoped to the workspace of ctx.
+// The workspace and user are set with SET LOCAL, so the row-level security
+// policies see them for this transaction only. Without a workspace in ctx fn
+// is not run at all.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//   - fn: The work to do in the transaction.
+//
+// Returns:
+//   - error: ErrMissingTenant, or the error returned by fn or the database.
+func TenantTransaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	workspaceId, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrMissingTenant
+	}
+	userId, _ := ctx.Value(tenantUserContextKey{}).(int)
+	return TenantDB(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("SELECT set_config(?, ?, true), set_config(?, ?, true)",
+			utils.TENANT_WORKSPACE_SETTING, strconv.Itoa(workspaceId),
+			utils.TENANT_USER_SETTING, strconv.Itoa(userId)).Error
+		if err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// SystemTransaction runs fn in a transaction that the row-level security
+// policies let through for every workspace. It is meant for work that spans
+// workspaces on purpose, such as data exports and purges, never for request
+// handling.
+//
+// Parameters:
+//   - fn: The work to do in the transaction.
+//
+// Returns:
+//   - error: The error returned by fn or the database.
+func SystemTransaction(fn func(tx *gorm.DB) error) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT set_config(?, 'on', true)", utils.TENANT_BYPASS_SETTING).Error; err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// TenantDB returns a database handle that carries ctx, so the tenant scope
+// callbacks can see its workspace.
+//
+// Parameters:
+//   - ctx: A context created by WithTenant.
+//
+// Returns:
+//   - *gorm.DB: The database handle.
+func TenantDB(ctx context.Context) *gorm.DB {
+	return config.DB.WithContext(ctx)
+}
+
+// TenantScope filters a query to a workspace. Use it with db.Scopes for
+// queries the callbacks can't scope on their own, such as joins through
+// Table("... AS alias").
+//
+// Parameters:
+//   - table: The table or alias holding the workspace_id column.
+//   - workspaceId: The ID of the workspace.
+//
+// Returns:
+//   - func(*gorm.DB) *gorm.DB: The scope.
+func TenantScope(table string, workspaceId int) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Eq{Column: clause.Column{Table: table, Name: "workspace_id"}, Value: workspaceId})
+	}
+}
+
+// RegisterTenantScopes installs the GORM callbacks that scope queries on
+// tenant tables to the workspace in the statement's context. Statements
+// without a workspace in their context are left alone.
+//
+// Returns:
+//   - error: An error object if a callback can't be registered.
+func RegisterTenantScopes() error {
+	callbacks := config.DB.Callback()
+	if err := callbacks.Query().Before("gorm:query").Register("tenant:query", scopeTenantStatement); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("tenant:row", scopeTenantStatement); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenant:update", scopeTenantStatement); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("tenant:delete", scopeTenantStatement); err != nil {
+		return err
+	}
+	return callbacks.Create().Before("gorm:create").Register("tenant:create", assignTenant)
+}
+
+// tenantStatement reports whether a statement targets a tenant table and
+// returns the workspace of its context. Aliased tables are not recognised.
+func tenantStatement(db *gorm.DB) (int, bool) {
+	statement := db.Statement
+	if !slices.Contains(utils.TENANT_SCOPED_TABLES, statement.Table) {
+		return 0, false
+	}
+	return TenantFromContext(statement.Context)
+}
+
+func scopeTenantStatement(db *gorm.DB) {
+	workspaceId, ok := tenantStatement(db)
+	if !ok {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "workspace_id"}, Value: workspaceId},
+	}})
+}
+
+func assignTenant(db *gorm.DB) {
+	workspaceId, ok := tenantStatement(db)
+	if !ok || db.Statement.Schema == nil {
+		return
+	}
+	field := db.Statement.Schema.LookUpField("workspace_id")
+	if field == nil {
+		return
+	}
+
+	assign := func(value reflect.Value) {
+		current, isZero := field.ValueOf(db.Statement.Context, value)
+		if isZero {
+			if err := field.Set(db.Statement.Context, value, workspaceId); err != nil {
+				db.AddError(err)
+			}
+		} else if current != workspaceId {
+			db.AddError(fmt.Errorf("row belongs to workspace %v, not %d", current, workspaceId))
+		}
+	}
+
+	switch db.Statement.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < db.Statement.ReflectValue.Len(); i++ {
+			assign(reflect.Indirect(db.Statement.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		assign(db.Statement.ReflectValue)
+	}
+}
+
+// applyRowLevelSecurity enables or disables the tenant isolation policies on
+// the tables in utils.TENANT_SCOPED_TABLES, following
+// config.RowLevelSecurityEnabled. The policies fail closed: a transaction that
+// hasn't set a workspace sees no rows and can't write any.
+func applyRowLevelSecurity() error {
+	for _, table := range utils.TENANT_SCOPED_TABLES {
+		statements := []string{
+			fmt.Sprintf("ALTER TABLE %s NO FORCE ROW LEVEL SECURITY", table),
+			fmt.Sprintf("ALTER TABLE %s DISABLE ROW LEVEL SECURITY", table),
+			fmt.Sprintf("DROP POLICY IF EXISTS tenant_isolation ON %s", table),
+		}
+		if config.RowLevelSecurityEnabled() {
+			condition := fmt.Sprintf(
+				"current_setting('%s', true) = 'on' OR workspace_id = NULLIF(current_setting('%s', true), '')::integer",
+				utils.TENANT_BYPASS_SETTING, utils.TENANT_WORKSPACE_SETTING)
+			statements = []string{
+				fmt.Sprintf("DROP POLICY IF EXISTS tenant_isolation ON %s", table),
+				fmt.Sprintf("CREATE POLICY tenant_isolation ON %s USING (%s) WITH CHECK (%s)", table, condition, condition),
+				fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", table),
+				// Apply the policies to the table owner too, which is
+				// usually the role the server connects as.
+				fmt.Sprintf("ALTER TABLE %s FORCE ROW LEVEL SECURITY", table),
+			}
+		}
+		for _, statement := range statements {
+			if err := config.DB.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
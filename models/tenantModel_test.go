@@ -0,0 +1,126 @@
+package models
+
+import (
+	"context"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// testRowLevelSecurity turns the tenant isolation policies on for the test
+// and restores them afterwards. Superusers and roles with BYPASSRLS ignore
+// the policies, so the test is skipped when connected as one.
+func testRowLevelSecurity(t *testing.T) {
+	t.Helper()
+	testDB(t)
+
+	var bypass bool
+	err := config.DB.Raw("SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user").Scan(&bypass).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bypass {
+		t.Skip("TEST_DATABASE_URL connects as a role that bypasses row-level security")
+	}
+
+	// Registered before Setenv, so it runs once the variable is restored.
+	t.Cleanup(func() {
+		if err := applyRowLevelSecurity(); err != nil {
+			t.Errorf("failed to restore row-level security: %v", err)
+		}
+	})
+	t.Setenv("DB_ROW_LEVEL_SECURITY", "true")
+	if err := applyRowLevelSecurity(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createTenantFixture creates a workspace with a session, an attachment and a
+// collaborator, bypassing the policies.
+func createTenantFixture(t *testing.T, userId int) int {
+	t.Helper()
+	workspace := types.Workspace{Name: "RLS test", Role: "test", Status: true}
+	if err := config.DB.Create(&workspace).Error; err != nil {
+		t.Fatal(err)
+	}
+	err := SystemTransaction(func(tx *gorm.DB) error {
+		start := time.Now().UTC()
+		session := types.Session{Title: "RLS test", WorkspaceId: workspace.ID, CreatedBy: userId, Status: true,
+			Datetime: start, EndsAt: start.Add(time.Hour), DurationMinutes: 60}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		attachment := types.SessionAttachment{SessionId: session.ID, WorkspaceId: workspace.ID, Name: "notes.txt", UploadedBy: userId, Status: true}
+		if err := tx.Create(&attachment).Error; err != nil {
+			return err
+		}
+		return tx.Create(&types.SessionCollaborator{SessionId: session.ID, WorkspaceId: workspace.ID, UserId: userId, Status: true}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return workspace.ID
+}
+
+// countTenantRows counts the rows of a workspace in a table with raw SQL, so
+// the GORM tenant callbacks don't filter them and only the policies can.
+func countTenantRows(t *testing.T, tx *gorm.DB, table string, workspaceId int) int64 {
+	t.Helper()
+	var count int64
+	if err := tx.Raw("SELECT count(*) FROM "+table+" WHERE workspace_id = ?", workspaceId).Scan(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestRowLevelSecurityIsolatesWorkspaces(t *testing.T) {
+	testRowLevelSecurity(t)
+
+	user := types.User{Name: "RLS", Email: testEmail(t, "rls")}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	workspaceA := createTenantFixture(t, user.ID)
+	workspaceB := createTenantFixture(t, user.ID)
+	ctx := WithTenantUser(WithTenant(context.Background(), workspaceA), user.ID)
+
+	t.Run("tenant transaction sees only its workspace", func(t *testing.T) {
+		err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+			for _, table := range utils.TENANT_SCOPED_TABLES {
+				if count := countTenantRows(t, tx, table, workspaceB); count != 0 {
+					t.Errorf("%s: workspace %d sees %d rows of workspace %d", table, workspaceA, count, workspaceB)
+				}
+				if count := countTenantRows(t, tx, table, workspaceA); count != 1 {
+					t.Errorf("%s: workspace %d sees %d of its own rows, want 1", table, workspaceA, count)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("reads without a workspace fail closed", func(t *testing.T) {
+		for _, table := range utils.TENANT_SCOPED_TABLES {
+			if count := countTenantRows(t, config.DB, table, workspaceA); count != 0 {
+				t.Errorf("%s: plain read sees %d rows", table, count)
+			}
+		}
+	})
+
+	t.Run("inserts into another workspace are rejected", func(t *testing.T) {
+		err := TenantTransaction(ctx, func(tx *gorm.DB) error {
+			return tx.Exec("INSERT INTO "+utils.SESSIONS_TABLE+" (title, workspace_id, created_by, status, datetime, ends_at) VALUES (?, ?, ?, true, now(), now())",
+				"Intruder", workspaceB, user.ID).Error
+		})
+		if err == nil || !strings.Contains(err.Error(), "row-level security") {
+			t.Fatalf("insert into workspace %d from workspace %d: error = %v, want a row-level security violation", workspaceB, workspaceA, err)
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"fmt"
+	"server/config"
+	"server/types"
+	"server/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// QuotaExceededError is returned when an action would take a workspace past a
+// limit of its plan.
+type QuotaExceededError struct {
+	Resource string
+	Limit    int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("workspace plan limit of %d %s reached", e.Limit, e.Resource)
+}
+
+// Resources a plan limits, as reported in QuotaExceededError.
+const (
+	QuotaSeats        = "seats"
+	QuotaSessions     = "sessions"
+	QuotaStorageBytes = "storage_bytes"
+)
+
+// FetchWorkspaceUsage reports how much of its plan a workspace uses.
+//
+// Parameters:
+//   - workspace: The workspace.
+//
+// Returns:
+//   - *types.WorkspaceUsageResponse: The usage and limits of each resource.
+//   - error: An error object if there is an issue retrieving the usage.
+func FetchWorkspaceUsage(workspace *types.Workspace) (*types.WorkspaceUsageResponse, error) {
+	plan := config.PlanByName(workspace.Plan)
+	var seats int64
+	if err := config.DB.Model(&types.WorkspaceUser{}).Where("workspace_id=?", workspace.ID).Count(&seats).Error; err != nil {
+		return nil, err
+	}
+	usage := types.WorkspaceUsage{WorkspaceId: workspace.ID}
+	result := config.DB.Where("workspace_id=?", workspace.ID).Limit(1).Find(&usage)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &types.WorkspaceUsageResponse{
+		Plan:         plan.Name,
+		Seats:        types.UsageLimit{Used: seats, Limit: plan.Seats},
+		Sessions:     types.UsageLimit{Used: usage.Sessions, Limit: plan.MaxSessions},
+		StorageBytes: types.UsageLimit{Used: usage.StorageBytes, Limit: plan.StorageBytes},
+	}, nil
+}
+
+// ReserveSessionQuota counts a new session against the workspace's plan. Call
+// it in the transaction that creates the session.
+//
+// Parameters:
+//   - tx: The transaction creating the session.
+//   - workspaceId: The ID of the workspace.
+//
+// Returns:
+//   - error: A *QuotaExceededError when the plan has no sessions left, or an
+//     error object if there is an issue saving the usage.
+func ReserveSessionQuota(tx *gorm.DB, workspaceId int) error {
+	usage, plan, err := lockWorkspaceUsage(tx, workspaceId)
+	if err != nil {
+		return err
+	}
+	if plan.MaxSessions > 0 && usage.Sessions >= plan.MaxSessions {
+		return &QuotaExceededError{Resource: QuotaSessions, Limit: plan.MaxSessions}
+	}
+	return tx.Model(usage).Update("sessions", gorm.Expr("sessions + 1")).Error
+}
+
+// ReleaseSessionQuota gives back the quota of deleted sessions.
+//
+// Parameters:
+//   - tx: The transaction deleting the sessions.
+//   - workspaceId: The ID of the workspace.
+//   - count: The number of sessions deleted.
+//
+// Returns:
+//   - error: An error object if there is an issue saving the usage.
+func ReleaseSessionQuota(tx *gorm.DB, workspaceId int, count int64) error {
+	return tx.Model(&types.WorkspaceUsage{}).Where("workspace_id=?", workspaceId).
+		Update("sessions", gorm.Expr("GREATEST(sessions - ?, 0)", count)).Error
+}
+
+// ReserveStorageQuota counts an upload against the workspace's storage limit.
+// Call it before storing the file, in the transaction that saves it.
+//
+// Parameters:
+//   - tx: The transaction saving the attachment.
+//   - workspaceId: The ID of the workspace.
+//   - bytes: The size of the upload.
+//
+// Returns:
+//   - error: A *QuotaExceededError when the upload doesn't fit, or an error
+//     object if there is an issue saving the usage.
+func ReserveStorageQuota(tx *gorm.DB, workspaceId int, bytes int64) error {
+	usage, err := checkStorageQuota(tx, workspaceId, bytes)
+	if err != nil {
+		return err
+	}
+	return tx.Model(usage).Update("storage_bytes", gorm.Expr("storage_bytes + ?", bytes)).Error
+}
+
+// checkStorageQuota makes sure an upload fits the workspace's storage limit
+// without reserving the space, and returns the locked usage counters.
+func checkStorageQuota(tx *gorm.DB, workspaceId int, bytes int64) (*types.WorkspaceUsage, error) {
+	usage, plan, err := lockWorkspaceUsage(tx, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	if plan.StorageBytes > 0 && usage.StorageBytes+bytes > plan.StorageBytes {
+		return nil, &QuotaExceededError{Resource: QuotaStorageBytes, Limit: plan.StorageBytes}
+	}
+	return usage, nil
+}
+
+// ReleaseStorageQuota gives back the storage of deleted attachments.
+//
+// Parameters:
+//   - tx: The transaction deleting the attachments.
+//   - workspaceId: The ID of the workspace.
+//   - bytes: The total size of the deleted attachments.
+//
+// Returns:
+//   - error: An error object if there is an issue saving the usage.
+func ReleaseStorageQuota(tx *gorm.DB, workspaceId int, bytes int64) error {
+	return tx.Model(&types.WorkspaceUsage{}).Where("workspace_id=?", workspaceId).
+		Update("storage_bytes", gorm.Expr("GREATEST(storage_bytes - ?, 0)", bytes)).Error
+}
+
+// checkSeatAvailable makes sure a workspace has a seat left for another
+// member. Pending invitations hold on to their seats, except for
+// invitationId, the invitation being accepted, if any. The caller must hold
+// the workspace lock.
+func checkSeatAvailable(tx *gorm.DB, workspaceId, invitationId int) error {
+	var workspace types.Workspace
+	if err := tx.Select("id", "plan").First(&workspace, workspaceId).Error; err != nil {
+		return err
+	}
+	plan := config.PlanByName(workspace.Plan)
+	if plan.Seats == 0 {
+		return nil
+	}
+	var members, reserved int64
+	if err := tx.Model(&types.WorkspaceUser{}).Where("workspace_id=?", workspaceId).Count(&members).Error; err != nil {
+		return err
+	}
+	if err := pendingInvitations(tx.Model(&types.WorkspaceInvitation{}), workspaceId).Where("id<>?", invitationId).Count(&reserved).Error; err != nil {
+		return err
+	}
+	if members+reserved >= plan.Seats {
+		return &QuotaExceededError{Resource: QuotaSeats, Limit: plan.Seats}
+	}
+	return nil
+}
+
+// lockWorkspaceUsage locks the usage counters of a workspace, creating them
+// when missing, and returns them with the workspace's plan.
+func lockWorkspaceUsage(tx *gorm.DB, workspaceId int) (*types.WorkspaceUsage, config.Plan, error) {
+	var workspace types.Workspace
+	if err := tx.Select("id", "plan").First(&workspace, workspaceId).Error; err != nil {
+		return nil, config.Plan{}, err
+	}
+	usage := types.WorkspaceUsage{WorkspaceId: workspaceId}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&usage).Error; err != nil {
+		return nil, config.Plan{}, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("workspace_id=?", workspaceId).First(&usage).Error; err != nil {
+		return nil, config.Plan{}, err
+	}
+	return &usage, config.PlanByName(workspace.Plan), nil
+}
+
+// backfillWorkspaceUsage creates the usage counters of workspaces that don't
+// have any yet from their current sessions and attachments.
+func backfillWorkspaceUsage() error {
+	// The totals span workspaces.
+	return SystemTransaction(func(tx *gorm.DB) error {
+		return tx.Exec("INSERT INTO " + utils.WORKSPACE_USAGE_TABLE + " (workspace_id, sessions, storage_bytes, updated_at) " +
+			"SELECT w.id, " +
+			"(SELECT count(*) FROM " + utils.SESSIONS_TABLE + " AS s WHERE s.workspace_id = w.id), " +
+			"(SELECT COALESCE(sum(a.size), 0) FROM " + utils.SESSION_ATTACHMENTS_TABLE + " AS a WHERE a.workspace_id = w.id), " +
+			"now() FROM " + utils.WORKSPACES_TABLE + " AS w " +
+			"ON CONFLICT (workspace_id) DO NOTHING").Error
+	})
+}
@@ -1,8 +1,13 @@
 package models
 
 import (
+	"fmt"
 	"server/config"
 	"server/types"
+	"server/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // InsertUser inserts a new user into the database.
@@ -54,3 +59,71 @@ func FetchUserByEmail(email string) (*types.User, error) {
 	}
 	return &user, nil
 }
+
+// UpdateUserProfile updates the profile fields that are set in the payload.
+//
+// Parameters:
+//   - id: An integer representing the user's unique identifier.
+//   - payload: The profile fields to change. Nil fields are left untouched.
+//
+// Returns:
+//   - *types.User: A pointer to the updated User object.
+//   - error: An error object if there is an issue updating the user.
+func UpdateUserProfile(id int, payload types.UpdateUserPayload) (*types.User, error) {
+	updates := map[string]interface{}{}
+	if payload.Name != nil {
+		updates["name"] = *payload.Name
+	}
+	if payload.Avatar != nil {
+		updates["avatar"] = *payload.Avatar
+		// The thumbnails belong to the previous avatar.
+		updates["avatar_sizes"] = nil
+	}
+	if payload.Timezone != nil {
+		updates["timezone"] = *payload.Timezone
+	}
+	if payload.Locale != nil {
+		updates["locale"] = *payload.Locale
+	}
+	if payload.Title != nil {
+		updates["title"] = *payload.Title
+	}
+	if payload.Bio != nil {
+		updates["bio"] = *payload.Bio
+	}
+
+	if len(updates) == 0 {
+		return FetchUser(id)
+	}
+	var user types.User
+	var replaced []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+			return err
+		}
+		if payload.Avatar != nil && *payload.Avatar != user.Avatar {
+			replaced = avatarObjectKeys(user.Avatar, user.AvatarSizes)
+		}
+		return tx.Model(&user).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	deleteAvatarObjects(fmt.Sprintf("user %d", id), replaced)
+	return FetchUser(id)
+}
+
+// avatarObjectKeys returns the S3 keys of an avatar and its thumbnails.
+// URLs that don't point at the bucket are skipped.
+func avatarObjectKeys(avatar string, sizes map[string]string) []string {
+	var keys []string
+	if key, ok := utils.S3KeyFromUrl(avatar); ok {
+		keys = append(keys, key)
+	}
+	for _, url := range sizes {
+		if key, ok := utils.S3KeyFromUrl(url); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
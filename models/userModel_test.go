@@ -0,0 +1,25 @@
+package models
+
+import (
+	"server/config"
+	"server/types"
+	"testing"
+)
+
+func TestUpdatingTheAvatarClearsThumbnails(t *testing.T) {
+	testDB(t)
+
+	user := types.User{Name: "Avatar", Email: testEmail(t, "avatar"), Avatar: "https://cdn.example.com/old.png",
+		AvatarSizes: map[string]string{"64": "https://cdn.example.com/old-64.png"}}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	avatar := "https://cdn.example.com/new.png"
+	updated, err := UpdateUserProfile(user.ID, types.UpdateUserPayload{Avatar: &avatar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Avatar != avatar || len(updated.AvatarSizes) != 0 {
+		t.Errorf("avatar = %q with sizes %v, want %q without sizes", updated.Avatar, updated.AvatarSizes, avatar)
+	}
+}
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"errors"
+	"server/config"
+	"server/types"
+	"server/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrAlreadyWorkspaceMember = errors.New("user is already a member of the workspace")
+var ErrWorkspaceOwnerRequired = errors.New("a workspace must keep exactly one owner")
+var ErrWorkspaceOwnerRole = errors.New("the owner's role can't be changed")
+var ErrMemberInvitationRequired = errors.New("user doesn't share a workspace with the inviter and has to be invited")
+
+// FetchWorkspaceMembers lists the members of a workspace with their profile.
+//
+// Parameters:
+//   - workspaceId: The ID of the workspace.
+//
+// Returns:
+//   - []types.WorkspaceMemberResponse: The members, owner first, then by name.
+//   - error: An error object if there is an issue retrieving the members.
+func FetchWorkspaceMembers(workspaceId int) ([]types.WorkspaceMemberResponse, error) {
+	members := []types.WorkspaceMemberResponse{}
+	result := config.DB.Table(utils.WORKSPACE_USERS_TABLE+" AS wu").
+		Select("wu.user_id, u.name, u.email, u.avatar, wu.role, wu.is_owner, wu.created_at AS joined_at").
+		Joins("JOIN "+utils.USERS_TABLE+" AS u ON u.id = wu.user_id").
+		Where("wu.workspace_id = ?", workspaceId).
+		Order("wu.is_owner DESC, u.name, u.id").
+		Scan(&members)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return members, nil
+}
+
+// AddWorkspaceMember adds an existing user to a workspace without asking
+// them. Only people who already share a workspace with the one adding them
+// can be added this way; anyone else has to accept an invitation. It becomes
+// the user's primary workspace if they don't have one yet.
+//
+// Parameters:
+//   - workspaceId: The ID of the workspace.
+//   - addedBy: The ID of the owner or admin adding the user.
+//   - userId: The ID of the user to add.
+//   - role: The member's role.
+//
+// Returns:
+//   - *types.WorkspaceUser: The new membership.
+//   - error: ErrAlreadyWorkspaceMember, ErrMemberInvitationRequired, a
+//     *QuotaExceededError when the plan has no seats left, or an error object
+//     if there is an issue saving the membership.
+func AddWorkspaceMember(workspaceId, addedBy, userId int, role string) (*types.WorkspaceUser, error) {
+	var workspaceUser *types.WorkspaceUser
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockWorkspace(tx, workspaceId); err != nil {
+			return err
+		}
+		var shared int64
+		err := tx.Table(utils.WORKSPACE_USERS_TABLE+" AS a").
+			Joins("JOIN "+utils.WORKSPACE_USERS_TABLE+" AS b ON a.workspace_id = b.workspace_id").
+			Where("a.user_id = ? AND b.user_id = ?", addedBy, userId).
+			Count(&shared).Error
+		if err != nil {
+			return err
+		}
+		if shared == 0 {
+			return ErrMemberInvitationRequired
+		}
+		workspaceUser, err = addWorkspaceMember(tx, workspaceId, userId, role, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return workspaceUser, nil
+}
+
+// addWorkspaceMember creates a membership inside a transaction that already
+// holds the workspace lock. Every member joining an existing workspace goes
+// through it, so the plan's seats are always checked. invitationId is the
+// invitation the user accepts, whose seat they take, or 0.
+func addWorkspaceMember(tx *gorm.DB, workspaceId, userId int, role string, invitationId int) (*types.WorkspaceUser, error) {
+	var count int64
+	if err := tx.Model(&types.WorkspaceUser{}).Where("workspace_id=? AND user_id=?", workspaceId, userId).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAlreadyWorkspaceMember
+	}
+	if err := checkSeatAvailable(tx, workspaceId, invitationId); err != nil {
+		return nil, err
+	}
+	var primaries int64
+	if err := tx.Model(&types.WorkspaceUser{}).Where("user_id=? AND is_primary=?", userId, true).Count(&primaries).Error; err != nil {
+		return nil, err
+	}
+	workspaceUser := types.WorkspaceUser{WorkspaceId: workspaceId, UserId: userId, Role: role, IsPrimary: primaries == 0}
+	if err := tx.Create(&workspaceUser).Error; err != nil {
+		return nil, err
+	}
+	return &workspaceUser, nil
+}
+
+// UpdateWorkspaceMemberRole changes the role of a member. The owner's role
+// can't be changed.
+//
+// Parameters:
+//   - workspaceId: The ID of the workspace.
+//   - userId: The ID of the member.
+//   - role: The new role.
+//
+// Returns:
+//   - *types.WorkspaceUser: The updated membership.
+//   - error: ErrNotWorkspaceMember, ErrWorkspaceOwnerRole, or an error object
+//     if there is an issue saving the membership.
+func UpdateWorkspaceMemberRole(workspaceId, userId int, role string) (*types.WorkspaceUser, error) {
+	var workspaceUser types.WorkspaceUser
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockWorkspaceMember(tx, workspaceId, userId, &workspaceUser); err != nil {
+			return err
+		}
+		if workspaceUser.IsOwner {
+			return ErrWorkspaceOwnerRole
+		}
+		workspaceUser.Role = role
+		return tx.Model(&workspaceUser).Update("role", role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &workspaceUser, nil
+}
+
+// RemoveWorkspaceMember removes a member from a workspace. The owner can't be
+// removed; ownership has to be transferred first. When it was the member's
+// primary workspace, another of their workspaces becomes primary.
+//
+// Parameters:
+//   - workspaceId: The ID of the workspace.
+//   - userId: The ID of the member.
+//
+// Returns:
+//   - error: ErrNotWorkspaceMember, ErrWorkspaceOwnerRequired, or an error
+//     object if there is an issue deleting the membership.
+func RemoveWorkspaceMember(workspaceId, userId int) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var workspaceUser types.WorkspaceUser
+		if err := lockWorkspaceMember(tx, workspaceId, userId, &workspaceUser); err != nil {
+			return err
+		}
+		if workspaceUser.IsOwner {
+			return ErrWorkspaceOwnerRequired
+		}
+		if workspaceUser.IsPrimary {
+			if err := reassignPrimaryWorkspaces(tx, workspaceId, userId); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&workspaceUser).Error
+	})
+}
+
+// TransferWorkspaceOwnership makes another member the owner of a workspace.
+// The previous owner stays on as an admin.
+//
+// Parameters:
+//   - workspaceId: The ID of the workspace.
+//   - userId: The ID of the member who becomes the owner.
+//
+// Returns:
+//   - error: ErrNotWorkspaceMember, ErrWorkspaceOwnerRequired, or an error
+//     object if there is an issue saving the memberships.
+func TransferWorkspaceOwnership(workspaceId, userId int) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var workspaceUser types.WorkspaceUser
+		if err := lockWorkspaceMember(tx, workspaceId, userId, &workspaceUser); err != nil {
+			return err
+		}
+		err := tx.Model(&types.WorkspaceUser{}).
+			Where("workspace_id=? AND is_owner=? AND user_id<>?", workspaceId, true, userId).
+			Updates(map[string]interface{}{"is_owner": false, "role": utils.WORKSPACE_ROLE_ADMIN}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&workspaceUser).Updates(map[string]interface{}{"is_owner": true, "role": utils.WORKSPACE_ROLE_ADMIN}).Error
+		if err != nil {
+			return err
+		}
+
+		var owners int64
+		if err := tx.Model(&types.WorkspaceUser{}).Where("workspace_id=? AND is_owner=?", workspaceId, true).Count(&owners).Error; err != nil {
+			return err
+		}
+		if owners != 1 {
+			return ErrWorkspaceOwnerRequired
+		}
+		return nil
+	})
+}
+
+// SetPrimaryWorkspace makes a workspace the user's primary workspace.
+//
+// Parameters:
+//   - userId: The ID of the user.
+//   - workspaceId: The ID of a workspace the user is a member of.
+//
+// Returns:
+//   - *types.WorkspaceUser: The updated membership.
+//   - error: ErrNotWorkspaceMember, or an error object if there is an issue saving the memberships.
+func SetPrimaryWorkspace(userId, workspaceId int) (*types.WorkspaceUser, error) {
+	var workspaceUser *types.WorkspaceUser
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		workspaceUser, err = setPrimaryWorkspace(tx, userId, workspaceId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return workspaceUser, nil
+}
+
+// setPrimaryWorkspace moves the user's primary flag to a workspace, or
+// clears it when workspaceId is 0.
+func setPrimaryWorkspace(tx *gorm.DB, userId, workspaceId int) (*types.WorkspaceUser, error) {
+	// Lock all of the user's memberships so two requests can't leave
+	// two primaries behind.
+	var memberships []types.WorkspaceUser
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id=?", userId).Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+	var workspaceUser *types.WorkspaceUser
+	for i := range memberships {
+		if memberships[i].WorkspaceId == workspaceId {
+			workspaceUser = &memberships[i]
+		}
+	}
+	if workspaceUser == nil && workspaceId != 0 {
+		return nil, ErrNotWorkspaceMember
+	}
+	if err := tx.Model(&types.WorkspaceUser{}).Where("user_id=? AND workspace_id<>?", userId, workspaceId).Update("is_primary", false).Error; err != nil {
+		return nil, err
+	}
+	if workspaceUser == nil {
+		return nil, nil
+	}
+	workspaceUser.IsPrimary = true
+	return workspaceUser, tx.Model(workspaceUser).Update("is_primary", true).Error
+}
+
+// lockWorkspace locks a workspace row for the rest of the transaction so
+// membership changes to it are applied one at a time.
+func lockWorkspace(tx *gorm.DB, workspaceId int) error {
+	var workspace types.Workspace
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&workspace, workspaceId).Error
+}
+
+// lockWorkspaceMember locks the workspace and loads a member's membership.
+func lockWorkspaceMember(tx *gorm.DB, workspaceId, userId int, workspaceUser *types.WorkspaceUser) error {
+	if err := lockWorkspace(tx, workspaceId); err != nil {
+		return err
+	}
+	err := tx.Where("workspace_id=? AND user_id=?", workspaceId, userId).First(workspaceUser).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotWorkspaceMember
+	}
+	return err
+}
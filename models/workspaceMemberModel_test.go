@@ -0,0 +1,194 @@
+package models
+
+import (
+	"errors"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestDirectAddsLeaveInvitationSeats(t *testing.T) {
+	testDB(t)
+	t.Setenv("PLAN_FREE_SEATS", "2")
+
+	owner := types.User{Name: "Owner", Email: testEmail(t, "owner")}
+	colleague := types.User{Name: "Colleague", Email: testEmail(t, "colleague")}
+	invitee := types.User{Name: "Invitee", Email: testEmail(t, "invitee")}
+	for _, user := range []*types.User{&owner, &colleague, &invitee} {
+		if err := config.DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	workspace := types.Workspace{Name: "Seats", Role: "test", Status: true, Plan: "free"}
+	shared := types.Workspace{Name: "Shared", Role: "test", Status: true}
+	for _, w := range []*types.Workspace{&workspace, &shared} {
+		if err := config.DB.Create(w).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	memberships := []types.WorkspaceUser{
+		{WorkspaceId: workspace.ID, UserId: owner.ID, IsOwner: true},
+		{WorkspaceId: shared.ID, UserId: owner.ID},
+		{WorkspaceId: shared.ID, UserId: colleague.ID},
+	}
+	if err := config.DB.Create(&memberships).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	invitation, err := CreateWorkspaceInvitation(&workspace, &owner, invitee.Email, "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var quotaErr *QuotaExceededError
+	if _, err := AddWorkspaceMember(workspace.ID, owner.ID, colleague.ID, "member"); !errors.As(err, &quotaErr) {
+		t.Fatalf("direct add error = %v, want the seat held by the invitation to count", err)
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockWorkspace(tx, workspace.ID); err != nil {
+			return err
+		}
+		_, err := addWorkspaceMember(tx, workspace.ID, invitee.ID, "member", invitation.ID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("accepting the invitation failed: %v", err)
+	}
+}
+
+// ownershipFixture creates a workspace owned by the first of users, with the
+// others as members.
+func ownershipFixture(t *testing.T, names ...string) (types.Workspace, []types.User) {
+	t.Helper()
+	workspace := types.Workspace{Name: "Ownership", Role: "test", Status: true}
+	if err := config.DB.Create(&workspace).Error; err != nil {
+		t.Fatal(err)
+	}
+	users := make([]types.User, len(names))
+	for i, name := range names {
+		users[i] = types.User{Name: name, Email: testEmail(t, name)}
+		if err := config.DB.Create(&users[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+		membership := types.WorkspaceUser{WorkspaceId: workspace.ID, UserId: users[i].ID, Role: "member", IsOwner: i == 0}
+		if err := config.DB.Create(&membership).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return workspace, users
+}
+
+func workspaceOwners(t *testing.T, workspaceId int) []int {
+	t.Helper()
+	var owners []int
+	if err := config.DB.Model(&types.WorkspaceUser{}).Where("workspace_id=? AND is_owner=?", workspaceId, true).Pluck("user_id", &owners).Error; err != nil {
+		t.Fatal(err)
+	}
+	return owners
+}
+
+func TestTransferWorkspaceOwnershipKeepsOneOwner(t *testing.T) {
+	testDB(t)
+	workspace, users := ownershipFixture(t, "owner", "heir", "bystander")
+	owner, heir := users[0], users[1]
+
+	if err := TransferWorkspaceOwnership(workspace.ID, heir.ID); err != nil {
+		t.Fatal(err)
+	}
+	if owners := workspaceOwners(t, workspace.ID); len(owners) != 1 || owners[0] != heir.ID {
+		t.Fatalf("owners = %v, want only %d", owners, heir.ID)
+	}
+	previous, err := FetchWorkspaceUser(workspace.ID, owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous.IsOwner || previous.Role != utils.WORKSPACE_ROLE_ADMIN {
+		t.Errorf("previous owner = %+v, want an admin who isn't the owner", previous)
+	}
+
+	// Transferring to the current owner changes nothing.
+	if err := TransferWorkspaceOwnership(workspace.ID, heir.ID); err != nil {
+		t.Fatal(err)
+	}
+	if owners := workspaceOwners(t, workspace.ID); len(owners) != 1 || owners[0] != heir.ID {
+		t.Fatalf("owners = %v, want only %d", owners, heir.ID)
+	}
+
+	outsider := types.User{Name: "Outsider", Email: testEmail(t, "outsider")}
+	if err := config.DB.Create(&outsider).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := TransferWorkspaceOwnership(workspace.ID, outsider.ID); !errors.Is(err, ErrNotWorkspaceMember) {
+		t.Fatalf("transfer to a non-member: error = %v, want ErrNotWorkspaceMember", err)
+	}
+	if owners := workspaceOwners(t, workspace.ID); len(owners) != 1 || owners[0] != heir.ID {
+		t.Fatalf("owners = %v, want only %d", owners, heir.ID)
+	}
+}
+
+func TestRemoveWorkspaceMemberRefusesTheOwner(t *testing.T) {
+	testDB(t)
+	workspace, users := ownershipFixture(t, "owner", "member")
+
+	if err := RemoveWorkspaceMember(workspace.ID, users[0].ID); !errors.Is(err, ErrWorkspaceOwnerRequired) {
+		t.Fatalf("removing the owner: error = %v, want ErrWorkspaceOwnerRequired", err)
+	}
+	if owners := workspaceOwners(t, workspace.ID); len(owners) != 1 || owners[0] != users[0].ID {
+		t.Fatalf("owners = %v, want only %d", owners, users[0].ID)
+	}
+	if err := RemoveWorkspaceMember(workspace.ID, users[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveWorkspaceMember(workspace.ID, users[1].ID); !errors.Is(err, ErrNotWorkspaceMember) {
+		t.Fatalf("removing a former member: error = %v, want ErrNotWorkspaceMember", err)
+	}
+}
+
+func TestRemoveWorkspaceMemberMovesPrimaryMembership(t *testing.T) {
+	testDB(t)
+	workspace, users := ownershipFixture(t, "owner", "member", "colleague")
+	member, colleague := users[1], users[2]
+
+	other := types.Workspace{Name: "Other", Role: "test", Status: true}
+	if err := config.DB.Create(&other).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Create(&types.WorkspaceUser{WorkspaceId: other.ID, UserId: member.ID, Role: "member"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []types.User{member, colleague} {
+		if _, err := SetPrimaryWorkspace(user.ID, workspace.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RemoveWorkspaceMember(workspace.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+	primary, err := FetchPrimaryWorkspaceUser(member.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary == nil || primary.WorkspaceId != other.ID {
+		t.Fatalf("primary membership after removal = %+v, want workspace %d", primary, other.ID)
+	}
+	// Only the removed member's primary workspace moves.
+	primary, err = FetchPrimaryWorkspaceUser(colleague.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary == nil || primary.WorkspaceId != workspace.ID {
+		t.Fatalf("colleague's primary membership = %+v, want workspace %d", primary, workspace.ID)
+	}
+
+	if err := RemoveWorkspaceMember(workspace.ID, colleague.ID); err != nil {
+		t.Fatal(err)
+	}
+	if primary, err := FetchPrimaryWorkspaceUser(colleague.ID); err != nil || primary != nil {
+		t.Fatalf("primary membership without workspaces = %+v, %v, want none", primary, err)
+	}
+}
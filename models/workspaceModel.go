@@ -0,0 +1,372 @@
+package models
+
+import (
+	"errors"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrWorkspaceArchived = errors.New("workspace is archived")
+var ErrWorkspaceNotRestorable = errors.New("workspace is not deleted or its retention window has ended")
+
+// Function to fetch workspace detail by id
+//
+// Parameters:
+//   - id: An integer representing the workspace's unique identifier.
+//
+// Returns:
+//   - *types.Workspace: A pointer to the Workspace object if found.
+//   - error: An error object if there is an issue retrieving the workspace.
+func FetchWorkspace(id int) (*types.Workspace, error) {
+	var workspace types.Workspace
+	result := config.DB.First(&workspace, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &workspace, nil
+}
+
+// Function to fetch a user's membership in a workspace
+//
+// Parameters:
+//   - workspaceId: The ID of the workspace.
+//   - userId: The ID of the user.
+//
+// Returns:
+//   - *types.WorkspaceUser: A pointer to the WorkspaceUser object if the user is a member.
+//   - error: An error object if there is an issue retrieving the membership.
+func FetchWorkspaceUser(workspaceId, userId int) (*types.WorkspaceUser, error) {
+	var workspaceUser types.WorkspaceUser
+	result := config.DB.Where("workspace_id=? AND user_id=?", workspaceId, userId).First(&workspaceUser)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &workspaceUser, nil
+}
+
+// FetchPrimaryWorkspaceUser fetches the membership of a user's primary
+// workspace. Deleted workspaces are never primary.
+//
+// Parameters:
+//   - userId: The ID of the user.
+//
+// Returns:
+//   - *types.WorkspaceUser: The primary membership, or nil when the user has none.
+//   - error: An error object if there is an issue retrieving the membership.
+func FetchPrimaryWorkspaceUser(userId int) (*types.WorkspaceUser, error) {
+	var workspaceUser types.WorkspaceUser
+	result := config.DB.Table(utils.WORKSPACE_USERS_TABLE+" AS wu").Select("wu.*").
+		Joins("JOIN "+utils.WORKSPACES_TABLE+" AS w ON w.id = wu.workspace_id").
+		Where("wu.user_id = ? AND wu.is_primary = ? AND w.deleted_at IS NULL", userId, true).
+		Limit(1).Find(&workspaceUser)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &workspaceUser, nil
+}
+
+// Function to check whether two users are members of at least one common workspace
+//
+// Parameters:
+//   - userId: The ID of the first user.
+//   - otherUserId: The ID of the second user.
+//
+// Returns:
+//   - bool: True if both users belong to the same workspace.
+func SharesWorkspace(userId, otherUserId int) bool {
+	var count int64
+	config.DB.Table(utils.WORKSPACE_USERS_TABLE+" AS a").
+		Joins("JOIN "+utils.WORKSPACE_USERS_TABLE+" AS b ON a.workspace_id = b.workspace_id").
+		Where("a.user_id = ? AND b.user_id = ?", userId, otherUserId).
+		Count(&count)
+	return count > 0
+}
+
+// SearchWorkspaceUsers finds users who share a workspace with the given user
+// by name or email. Exact email matches rank first, then prefix matches, then
+// trigram matches by similarity. Without pg_trgm, substring matches take the
+// place of trigram matches.
+//
+// Parameters:
+//   - userId: The ID of the user searching.
+//   - query: The search text.
+//   - limit: The maximum number of users to return.
+//
+// Returns:
+//   - []types.CollaboratorResponse: The matching users, best match first.
+//   - error: An error object if there is an issue querying the users.
+func SearchWorkspaceUsers(userId int, query string, limit int) ([]types.CollaboratorResponse, error) {
+	query = strings.TrimSpace(query)
+	prefix := escapeLike(query) + "%"
+	wordPrefix := "% " + prefix
+
+	match := clause.Expr{SQL: "? <% name OR ? <% email", Vars: []interface{}{query, query}}
+	order := clause.Expr{SQL: "GREATEST(word_similarity(?, name), word_similarity(?, email)) DESC,", Vars: []interface{}{query, query}}
+	if !trigramSearch {
+		substring := "%" + escapeLike(query) + "%"
+		match = clause.Expr{SQL: "name ILIKE ? OR email ILIKE ?", Vars: []interface{}{substring, substring}}
+		order = clause.Expr{}
+	}
+
+	var users []types.User
+	result := config.DB.Model(&types.User{}).
+		Select("id", "name", "email", "avatar", "suspended_at").
+		Where("id <> ? AND suspended_at IS NULL", userId).
+		Where("EXISTS (SELECT 1 FROM "+utils.WORKSPACE_USERS_TABLE+" AS a JOIN "+utils.WORKSPACE_USERS_TABLE+" AS b ON a.workspace_id = b.workspace_id WHERE a.user_id = ? AND b.user_id = "+utils.USERS_TABLE+".id)", userId).
+		Where("name ILIKE ? OR email ILIKE ? OR name ILIKE ? OR ?", prefix, prefix, wordPrefix, match).
+		Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "CASE WHEN lower(email) = lower(?) THEN 0 WHEN name ILIKE ? OR email ILIKE ? THEN 1 WHEN name ILIKE ? THEN 2 ELSE 3 END, ? name, id",
+			Vars: []interface{}{query, prefix, prefix, wordPrefix, order},
+		}}).
+		Limit(limit).
+		Find(&users)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	response := make([]types.CollaboratorResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, types.CollaboratorResponse{
+			ID:     user.ID,
+			UserId: user.ID,
+			Name:   user.Name,
+			Email:  user.Email,
+			Avatar: user.Avatar,
+			Status: true,
+		})
+	}
+	return response, nil
+}
+
+// CreateWorkspace creates a workspace with the creator as its owner. It
+// becomes the creator's primary workspace if they don't have one yet.
+//
+// Parameters:
+//   - userId: The ID of the user creating the workspace.
+//   - payload: The name and role of the workspace.
+//
+// Returns:
+//   - *types.WorkspaceResponse: The new workspace and the creator's membership.
+//   - error: An error object if there is an issue creating the workspace.
+func CreateWorkspace(userId int, payload types.WorkspacePayload) (*types.WorkspaceResponse, error) {
+	response := types.WorkspaceResponse{
+		Workspace: types.Workspace{Name: payload.Name, Role: payload.Role, Status: true},
+		IsOwner:   true,
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&response.Workspace).Error; err != nil {
+			return err
+		}
+		var primaries int64
+		if err := tx.Model(&types.WorkspaceUser{}).Where("user_id=? AND is_primary=?", userId, true).Count(&primaries).Error; err != nil {
+			return err
+		}
+		response.IsPrimary = primaries == 0
+		err := tx.Create(&types.WorkspaceUser{
+			WorkspaceId: response.ID,
+			UserId:      userId,
+			IsOwner:     true,
+			IsPrimary:   response.IsPrimary,
+			Role:        utils.WORKSPACE_ROLE_ADMIN,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&types.WorkspaceUsage{WorkspaceId: response.ID}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// FetchUserWorkspaces lists the workspaces a user is a member of.
+//
+// Parameters:
+//   - userId: The ID of the user.
+//   - includeArchived: Whether archived workspaces are included.
+//
+// Returns:
+//   - []types.WorkspaceResponse: The workspaces, primary first, then by name.
+//   - error: An error object if there is an issue retrieving the workspaces.
+func FetchUserWorkspaces(userId int, includeArchived bool) ([]types.WorkspaceResponse, error) {
+	workspaces := []types.WorkspaceResponse{}
+	db := config.DB.Table(utils.WORKSPACES_TABLE+" AS w").
+		Select("w.*, wu.is_owner, wu.is_primary").
+		Joins("JOIN "+utils.WORKSPACE_USERS_TABLE+" AS wu ON wu.workspace_id = w.id").
+		Where("wu.user_id = ?", userId)
+	if includeArchived {
+		// Only owners see deleted workspaces, so they can restore them.
+		db = db.Where("w.deleted_at IS NULL OR wu.is_owner = ?", true)
+	} else {
+		db = db.Where("w.archived_at IS NULL")
+	}
+	result := db.Order("wu.is_primary DESC, w.name, w.id").Scan(&workspaces)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return workspaces, nil
+}
+
+// UpdateWorkspace updates the fields that are set in the payload.
+//
+// Parameters:
+//   - id: The ID of the workspace.
+//   - payload: The fields to change. Nil fields are left untouched.
+//
+// Returns:
+//   - *types.Workspace: A pointer to the updated Workspace object.
+//   - error: An error object if there is an issue updating the workspace.
+func UpdateWorkspace(id int, payload types.UpdateWorkspacePayload) (*types.Workspace, error) {
+	updates := map[string]interface{}{}
+	if payload.Name != nil {
+		updates["name"] = *payload.Name
+	}
+	if payload.Role != nil {
+		updates["role"] = *payload.Role
+	}
+
+	if len(updates) > 0 {
+		result := config.DB.Model(&types.Workspace{ID: id}).Updates(updates)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+	}
+	return FetchWorkspace(id)
+}
+
+// ArchiveWorkspace marks a workspace as archived and inactive.
+//
+// Parameters:
+//   - id: The ID of the workspace.
+//
+// Returns:
+//   - *types.Workspace: A pointer to the archived Workspace object.
+//   - error: An error object if there is an issue updating the workspace.
+func ArchiveWorkspace(id int) (*types.Workspace, error) {
+	result := config.DB.Model(&types.Workspace{ID: id}).Where("archived_at IS NULL").Updates(map[string]interface{}{
+		"status":      false,
+		"archived_at": time.Now(),
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return FetchWorkspace(id)
+}
+
+// UnarchiveWorkspace makes an archived workspace active and writable again.
+// Deleted workspaces have to be restored first.
+//
+// Parameters:
+//   - id: The ID of the workspace.
+//
+// Returns:
+//   - *types.Workspace: A pointer to the updated Workspace object.
+//   - error: An error object if there is an issue updating the workspace.
+func UnarchiveWorkspace(id int) (*types.Workspace, error) {
+	result := config.DB.Model(&types.Workspace{ID: id}).Where("deleted_at IS NULL").Updates(map[string]interface{}{
+		"status":      true,
+		"archived_at": nil,
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return FetchWorkspace(id)
+}
+
+// DeleteWorkspace archives a workspace and schedules it to be purged once
+// the retention window ends. Until then the owner can restore it. Members
+// for whom it was the primary workspace get another of their workspaces as
+// primary instead.
+//
+// Parameters:
+//   - id: The ID of the workspace.
+//
+// Returns:
+//   - *types.Workspace: A pointer to the deleted Workspace object.
+//   - error: An error object if there is an issue updating the workspace.
+func DeleteWorkspace(id int) (*types.Workspace, error) {
+	now := time.Now()
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&types.Workspace{ID: id}).Where("deleted_at IS NULL").Updates(map[string]interface{}{
+			"status":      false,
+			"archived_at": gorm.Expr("COALESCE(archived_at, ?)", now),
+			"deleted_at":  now,
+			"purge_after": now.Add(utils.WORKSPACE_DELETION_RETENTION),
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return reassignPrimaryWorkspaces(tx, id, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return FetchWorkspace(id)
+}
+
+// reassignPrimaryWorkspaces moves the primary flag of the members of a
+// deleted workspace, or of one member leaving it when userId isn't 0, to
+// their oldest membership of a workspace that isn't deleted, preferring ones
+// that aren't archived. Members without one are left without a primary
+// workspace.
+func reassignPrimaryWorkspaces(tx *gorm.DB, workspaceId, userId int) error {
+	err := tx.Exec(`UPDATE `+utils.WORKSPACE_USERS_TABLE+` SET is_primary = true WHERE id IN (
+		SELECT DISTINCT ON (o.user_id) o.id FROM `+utils.WORKSPACE_USERS_TABLE+` AS o
+		JOIN `+utils.WORKSPACES_TABLE+` AS w ON w.id = o.workspace_id
+		JOIN `+utils.WORKSPACE_USERS_TABLE+` AS p ON p.user_id = o.user_id AND p.workspace_id = ? AND p.is_primary
+		WHERE o.workspace_id <> ? AND w.deleted_at IS NULL AND (? = 0 OR o.user_id = ?)
+		ORDER BY o.user_id, w.archived_at IS NOT NULL, o.created_at, o.id)`, workspaceId, workspaceId, userId, userId).Error
+	if err != nil {
+		return err
+	}
+	db := tx.Model(&types.WorkspaceUser{}).Where("workspace_id=? AND is_primary=?", workspaceId, true)
+	if userId != 0 {
+		db = db.Where("user_id=?", userId)
+	}
+	return db.Update("is_primary", false).Error
+}
+
+// RestoreWorkspace cancels the deletion of a workspace. It stays archived
+// until it is unarchived.
+//
+// Parameters:
+//   - id: The ID of the workspace.
+//
+// Returns:
+//   - *types.Workspace: A pointer to the restored Workspace object.
+//   - error: ErrWorkspaceNotRestorable, or an error object if there is an
+//     issue updating the workspace.
+func RestoreWorkspace(id int) (*types.Workspace, error) {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&types.Workspace{ID: id}).
+			Where("deleted_at IS NOT NULL AND purge_after > ?", time.Now()).
+			Updates(map[string]interface{}{"deleted_at": nil, "purge_after": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrWorkspaceNotRestorable
+		}
+		// Members left without a primary workspace by the deletion get
+		// this one back.
+		return tx.Exec(`UPDATE `+utils.WORKSPACE_USERS_TABLE+` AS wu SET is_primary = true
+			WHERE wu.workspace_id = ? AND NOT EXISTS (
+				SELECT 1 FROM `+utils.WORKSPACE_USERS_TABLE+` AS o
+				JOIN `+utils.WORKSPACES_TABLE+` AS w ON w.id = o.workspace_id
+				WHERE o.user_id = wu.user_id AND o.is_primary AND w.deleted_at IS NULL)`, id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return FetchWorkspace(id)
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"server/config"
+	"server/types"
+	"testing"
+)
+
+func TestDeleteWorkspaceMovesPrimaryMemberships(t *testing.T) {
+	testDB(t)
+
+	user := types.User{Name: "Primary", Email: testEmail(t, "primary")}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	deleted := types.Workspace{Name: "Deleted", Role: "test", Status: true}
+	other := types.Workspace{Name: "Other", Role: "test", Status: true}
+	for _, w := range []*types.Workspace{&deleted, &other} {
+		if err := config.DB.Create(w).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	memberships := []types.WorkspaceUser{
+		{WorkspaceId: deleted.ID, UserId: user.ID, IsOwner: true, IsPrimary: true},
+		{WorkspaceId: other.ID, UserId: user.ID},
+	}
+	if err := config.DB.Create(&memberships).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DeleteWorkspace(deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	primary, err := FetchPrimaryWorkspaceUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary == nil || primary.WorkspaceId != other.ID {
+		t.Fatalf("primary membership after deletion = %+v, want workspace %d", primary, other.ID)
+	}
+
+	if _, err := DeleteWorkspace(other.ID); err != nil {
+		t.Fatal(err)
+	}
+	if primary, err := FetchPrimaryWorkspaceUser(user.ID); err != nil || primary != nil {
+		t.Fatalf("primary membership with only deleted workspaces = %+v, %v, want none", primary, err)
+	}
+
+	if _, err := RestoreWorkspace(deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	primary, err = FetchPrimaryWorkspaceUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary == nil || primary.WorkspaceId != deleted.ID {
+		t.Fatalf("primary membership after restoring = %+v, want workspace %d", primary, deleted.ID)
+	}
+}
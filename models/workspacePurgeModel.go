@@ -0,0 +1,136 @@
+package models
+
+import (
+	"errors"
+	"log"
+	"server/config"
+	"server/types"
+	"server/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RunWorkspacePurge purges the workspaces whose retention window has ended,
+// then again every interval. It never returns and is meant to run in the
+// background.
+//
+// Parameters:
+//   - interval: The time between two purges.
+func RunWorkspacePurge(interval time.Duration) {
+	for {
+		PurgeDeletedWorkspaces()
+		time.Sleep(interval)
+	}
+}
+
+// PurgeDeletedWorkspaces permanently removes every deleted workspace whose
+// retention window has ended. Failures are logged and retried on the next run.
+func PurgeDeletedWorkspaces() {
+	var ids []int
+	err := config.DB.Model(&types.Workspace{}).
+		Where("deleted_at IS NOT NULL AND purge_after <= ?", time.Now()).
+		Order("id").
+		Pluck("id", &ids).Error
+	if err != nil {
+		log.Printf("workspace purge: failed to list deleted workspaces: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if err := PurgeWorkspace(id); err != nil {
+			log.Printf("workspace purge: failed to purge workspace %d: %v", id, err)
+		}
+	}
+}
+
+// PurgeWorkspace permanently removes a deleted workspace whose retention
+// window has ended, together with its sessions, attachments, collaborators,
+// memberships, invitations and stored files. What was removed is logged.
+//
+// Parameters:
+//   - id: The ID of the workspace.
+//
+// Returns:
+//   - error: ErrWorkspaceNotRestorable when the workspace isn't due for
+//     purging, or an error object if there is an issue deleting its rows.
+//     Files that can't be removed from S3 are logged but don't fail the purge.
+func PurgeWorkspace(id int) error {
+	var workspace types.Workspace
+	var objectKeys []string
+	var sessions, attachments, collaborators, members int64
+	// The rows are removed across the workspace boundary.
+	err := SystemTransaction(func(tx *gorm.DB) error {
+		// Lock the row so a restore can't slip in between the check and the delete.
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id=? AND deleted_at IS NOT NULL AND purge_after <= ?", id, time.Now()).
+			First(&workspace)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return ErrWorkspaceNotRestorable
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		sessionIds := tx.Model(&types.Session{}).Select("id").Where("workspace_id=?", id)
+		var files []types.SessionAttachment
+		err := tx.Select("url").Where("workspace_id=? OR session_id IN (?)", id, sessionIds).Find(&files).Error
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if key, ok := utils.S3KeyFromUrl(file.Url); ok {
+				objectKeys = append(objectKeys, key)
+			}
+		}
+		objectKeys = append(objectKeys, avatarObjectKeys(workspace.Avatar, workspace.AvatarSizes)...)
+
+		result = tx.Where("workspace_id=? OR session_id IN (?)", id, sessionIds).Delete(&types.SessionCollaborator{})
+		if result.Error != nil {
+			return result.Error
+		}
+		collaborators = result.RowsAffected
+		result = tx.Where("workspace_id=? OR session_id IN (?)", id, sessionIds).Delete(&types.SessionAttachment{})
+		if result.Error != nil {
+			return result.Error
+		}
+		attachments = result.RowsAffected
+		result = tx.Where("workspace_id=?", id).Delete(&types.Session{})
+		if result.Error != nil {
+			return result.Error
+		}
+		sessions = result.RowsAffected
+		result = tx.Where("workspace_id=?", id).Delete(&types.WorkspaceUser{})
+		if result.Error != nil {
+			return result.Error
+		}
+		members = result.RowsAffected
+
+		cleanups := []interface{}{&types.WorkspaceInvitation{}, &types.WorkspaceUsage{}}
+		for _, model := range cleanups {
+			if err := tx.Where("workspace_id=?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&workspace).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// Files go last so a failed transaction never leaves rows pointing at
+	// missing objects.
+	var failed []string
+	if len(objectKeys) > 0 {
+		failed, err = utils.DeleteFromS3(objectKeys)
+		if err != nil {
+			log.Printf("workspace purge: failed to delete files of workspace %d: %v", id, err)
+		}
+	}
+	log.Printf("workspace purge: removed workspace %d (%q): %d sessions, %d attachments, %d collaborators, %d members, %d of %d files",
+		id, workspace.Name, sessions, attachments, collaborators, members, len(objectKeys)-len(failed), len(objectKeys))
+	for _, key := range failed {
+		log.Printf("workspace purge: file left behind for workspace %d: %s", id, key)
+	}
+	return nil
+}
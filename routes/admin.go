@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"server/controllers"
+	"server/middleware"
+	"server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AdminRoutes(route *gin.Engine) {
+	adminRoutes := route.Group("/admin")
+	adminRoutes.Use(middleware.AuthMiddleware(), middleware.RequireRole(utils.USER_ROLE_ADMIN))
+	{
+		adminRoutes.GET("/users", controllers.AdminListUsers)
+		adminRoutes.GET("/users/:id", controllers.AdminGetUser)
+		adminRoutes.DELETE("/users/:id", controllers.AdminDeleteUser)
+		adminRoutes.POST("/users/:id/suspend", controllers.AdminSuspendUser)
+		adminRoutes.POST("/users/:id/unsuspend", controllers.AdminUnsuspendUser)
+		adminRoutes.POST("/users/:id/force-password-reset", controllers.AdminForcePasswordReset)
+		adminRoutes.POST("/users/:id/revoke-tokens", controllers.AdminRevokeUserTokens)
+	}
+}
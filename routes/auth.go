@@ -2,15 +2,23 @@ package routes
 
 import (
 	"server/controllers"
+	"server/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func AuthRoutes(route *gin.Engine) {
+	failureThreshold := middleware.CaptchaFailureThreshold()
+
 	authRoutes := route.Group("/auth")
 	{
-		authRoutes.POST("/login", controllers.Login)
-		authRoutes.POST("/register", controllers.Register)
+		authRoutes.POST("/login", middleware.CaptchaMiddleware(failureThreshold), controllers.Login)
+		authRoutes.POST("/register", middleware.CaptchaMiddleware(0), controllers.Register)
 		authRoutes.POST("/sociallogin", controllers.SocialLogin)
+		authRoutes.POST("/logout", controllers.Logout)
+		authRoutes.POST("/verify-email", controllers.VerifyEmail)
+		authRoutes.POST("/forgot-password", controllers.ForgotPassword)
+		authRoutes.POST("/reset-password", controllers.ResetPassword)
+		authRoutes.POST("/switch-workspace", middleware.AuthMiddleware(), controllers.SwitchWorkspace)
 	}
 }
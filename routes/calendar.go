@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"server/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CalendarRoutes serves calendar feeds. They are authenticated by the secret
+// token in their URL, since calendar apps can't send a bearer token.
+func CalendarRoutes(route *gin.Engine) {
+	calendarRoutes := route.Group("/calendar")
+	{
+		calendarRoutes.GET("/:token", controllers.GetCalendarFeed)
+	}
+}
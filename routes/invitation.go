@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"server/controllers"
+	"server/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InvitationRoutes(route *gin.Engine) {
+	invitationRoutes := route.Group("/invitations")
+	invitationRoutes.Use(middleware.AuthMiddleware())
+	{
+		invitationRoutes.POST("/accept", controllers.AcceptWorkspaceInvitation)
+	}
+}
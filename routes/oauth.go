@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"server/controllers"
+	"server/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func OAuthRoutes(route *gin.Engine) {
+	route.GET("/.well-known/openid-configuration", controllers.OIDCDiscovery)
+
+	oauthRoutes := route.Group("/oauth")
+	{
+		oauthRoutes.GET("/jwks", controllers.OIDCJWKS)
+		oauthRoutes.POST("/token", controllers.Token)
+		oauthRoutes.GET("/userinfo", controllers.UserInfo)
+		oauthRoutes.POST("/userinfo", controllers.UserInfo)
+		oauthRoutes.GET("/authorize", middleware.OptionalAuthMiddleware(), controllers.Authorize)
+	}
+
+	authorizedRoutes := oauthRoutes.Group("")
+	authorizedRoutes.Use(middleware.AuthMiddleware())
+	{
+		authorizedRoutes.GET("/authorize/details", controllers.AuthorizeDetails)
+		authorizedRoutes.POST("/authorize", controllers.AuthorizeConsent)
+		authorizedRoutes.GET("/clients", controllers.ListOAuthClients)
+		authorizedRoutes.POST("/clients", controllers.RegisterOAuthClient)
+	}
+}
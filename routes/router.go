@@ -25,5 +25,12 @@ func InitRoute() *gin.Engine {
 	DefaultRoutes(router)
 	AuthRoutes(router)
 	UserRoutes(router)
+	OAuthRoutes(router)
+	SAMLRoutes(router)
+	WorkspaceRoutes(router)
+	InvitationRoutes(router)
+	SessionRoutes(router)
+	CalendarRoutes(router)
+	AdminRoutes(router)
 	return router
 }
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"server/controllers"
+	"server/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SAMLRoutes(route *gin.Engine) {
+	samlRoutes := route.Group("/saml/:workspace_id")
+	{
+		samlRoutes.GET("/metadata", controllers.SAMLMetadata)
+		samlRoutes.GET("/login", controllers.SAMLLogin)
+		samlRoutes.POST("/acs", controllers.SAMLAssertionConsumer)
+	}
+
+	samlConfigRoutes := samlRoutes.Group("/idp")
+	samlConfigRoutes.Use(middleware.AuthMiddleware())
+	{
+		samlConfigRoutes.GET("", controllers.GetSAMLConfig)
+		samlConfigRoutes.PUT("", controllers.UpdateSAMLConfig)
+	}
+}
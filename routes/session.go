@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"server/controllers"
+	"server/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SessionRoutes(route *gin.Engine) {
+	sessionRoutes := route.Group("/sessions")
+	sessionRoutes.Use(middleware.AuthMiddleware(), middleware.WorkspaceMiddleware())
+	{
+		sessionRoutes.POST("/", controllers.CreateSession)
+		sessionRoutes.GET("/", controllers.ListSessions)
+		sessionRoutes.GET("/:id", controllers.GetSession)
+		sessionRoutes.PATCH("/:id", controllers.UpdateSession)
+		sessionRoutes.DELETE("/:id", controllers.DeleteSession)
+		sessionRoutes.GET("/:id/calendar", controllers.DownloadSessionCalendar)
+		sessionRoutes.GET("/:id/collaborators", controllers.ListSessionCollaborators)
+		sessionRoutes.POST("/:id/collaborators", controllers.AddSessionCollaborator)
+		sessionRoutes.DELETE("/:id/collaborators/:user_id", controllers.RemoveSessionCollaborator)
+		sessionRoutes.GET("/:id/attachments", controllers.ListSessionAttachments)
+		sessionRoutes.POST("/:id/attachments", controllers.UploadSessionAttachments)
+		sessionRoutes.DELETE("/:id/attachments/:attachment_id", controllers.DeleteSessionAttachment)
+	}
+}
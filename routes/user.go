@@ -3,6 +3,7 @@ package routes
 import (
 	"server/controllers"
 	"server/middleware"
+	"server/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,5 +13,20 @@ func UserRoutes(route *gin.Engine) {
 	userRoutes.Use(middleware.AuthMiddleware())
 	{
 		userRoutes.GET("/", controllers.GetUser)
+		userRoutes.PATCH("/", controllers.UpdateUser)
+		userRoutes.GET("/settings", controllers.GetUserSettings)
+		userRoutes.PATCH("/settings", controllers.UpdateUserSettings)
+		userRoutes.PUT("/avatar", controllers.UploadUserAvatar)
+		userRoutes.POST("/export", controllers.StartDataExport)
+		userRoutes.GET("/export/:id", controllers.GetDataExport)
+		userRoutes.POST("/calendar-feed", controllers.CreateCalendarFeed)
+		userRoutes.DELETE("/calendar-feed", controllers.DeleteCalendarFeed)
+	}
+
+	usersRoutes := route.Group("/users")
+	usersRoutes.Use(middleware.AuthMiddleware())
+	{
+		usersRoutes.GET("/search", middleware.RateLimitMiddleware(utils.USER_SEARCH_RATE_LIMIT, utils.USER_SEARCH_RATE_WINDOW), controllers.SearchUsers)
+		usersRoutes.GET("/:id", controllers.GetPublicUser)
 	}
 }
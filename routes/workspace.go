@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"server/controllers"
+	"server/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func WorkspaceRoutes(route *gin.Engine) {
+	workspaceRoutes := route.Group("/workspaces")
+	workspaceRoutes.Use(middleware.AuthMiddleware())
+	{
+		workspaceRoutes.POST("/", controllers.CreateWorkspace)
+		workspaceRoutes.GET("/", controllers.ListWorkspaces)
+		workspaceRoutes.GET("/:id", controllers.GetWorkspace)
+		workspaceRoutes.PATCH("/:id", controllers.UpdateWorkspace)
+		workspaceRoutes.DELETE("/:id", controllers.DeleteWorkspace)
+		workspaceRoutes.POST("/:id/archive", controllers.ArchiveWorkspace)
+		workspaceRoutes.POST("/:id/unarchive", controllers.UnarchiveWorkspace)
+		workspaceRoutes.POST("/:id/restore", controllers.RestoreWorkspace)
+		workspaceRoutes.PUT("/:id/avatar", controllers.UploadWorkspaceAvatar)
+		workspaceRoutes.PUT("/:id/primary", controllers.SetPrimaryWorkspace)
+		workspaceRoutes.POST("/:id/transfer-ownership", controllers.TransferWorkspaceOwnership)
+		workspaceRoutes.GET("/:id/members", controllers.ListWorkspaceMembers)
+		workspaceRoutes.POST("/:id/members", controllers.AddWorkspaceMember)
+		workspaceRoutes.PATCH("/:id/members/:user_id", controllers.UpdateWorkspaceMember)
+		workspaceRoutes.DELETE("/:id/members/:user_id", controllers.RemoveWorkspaceMember)
+		workspaceRoutes.GET("/:id/usage", controllers.GetWorkspaceUsage)
+		workspaceRoutes.GET("/:id/invitations", controllers.ListWorkspaceInvitations)
+		workspaceRoutes.POST("/:id/invitations", controllers.CreateWorkspaceInvitation)
+		workspaceRoutes.POST("/:id/invitations/:invitation_id/resend", controllers.ResendWorkspaceInvitation)
+		workspaceRoutes.DELETE("/:id/invitations/:invitation_id", controllers.RevokeWorkspaceInvitation)
+	}
+}
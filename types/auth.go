@@ -1,5 +1,10 @@
 package types
 
+import (
+	"server/utils"
+	"time"
+)
+
 type GoogleUser struct {
 	ID            string `json:"id"`
 	Email         string `json:"email"`
@@ -10,3 +15,48 @@ type GoogleUser struct {
 	Picture       string `json:"picture"`
 	Locale        string `json:"locale"`
 }
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=30"`
+}
+
+type SwitchWorkspacePayload struct {
+	WorkspaceId int `json:"workspace_id" binding:"required,min=1"`
+}
+
+type VerifyEmailPayload struct {
+	Token           string `json:"token" binding:"required"`
+	InvitationToken string `json:"invitation_token"`
+}
+
+type PasswordResetToken struct {
+	ID        int        `json:"id" gorm:"primary_key"`
+	UserId    int        `json:"user_id"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt *time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (e *PasswordResetToken) TableName() string {
+	return utils.PASSWORD_RESET_TOKENS_TABLE
+}
+
+type PendingRegistration struct {
+	ID        int        `json:"id" gorm:"primary_key"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email" gorm:"index"`
+	Password  string     `json:"-"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt *time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (e *PendingRegistration) TableName() string {
+	return utils.PENDING_REGISTRATIONS_TABLE
+}
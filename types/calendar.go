@@ -0,0 +1,26 @@
+package types
+
+import (
+	"server/utils"
+	"time"
+)
+
+// CalendarFeed is a user's secret calendar subscription. Only the hash of its
+// token is stored; the feed URL is shown once when the token is issued.
+type CalendarFeed struct {
+	ID         int        `json:"id" gorm:"primary_key"`
+	UserId     int        `json:"user_id" gorm:"uniqueIndex"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  *time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (e *CalendarFeed) TableName() string {
+	return utils.CALENDAR_FEEDS_TABLE
+}
+
+type CalendarFeedResponse struct {
+	URL       string     `json:"url"`
+	CreatedAt *time.Time `json:"created_at"`
+}
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"server/utils"
+	"time"
+)
+
+type DataExport struct {
+	ID          int        `json:"id" gorm:"primary_key"`
+	UserId      int        `json:"user_id" gorm:"index"`
+	Status      string     `json:"status"`
+	ObjectKey   string     `json:"-"`
+	Error       string     `json:"-"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedAt   *time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (e *DataExport) TableName() string {
+	return utils.DATA_EXPORTS_TABLE
+}
+
+type DataExportResponse struct {
+	ID          int        `json:"id"`
+	Status      string     `json:"status"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedAt   *time.Time `json:"created_at"`
+}
+
+// DataExportWorkspace is a workspace the user belongs to, as written to the
+// export archive.
+type DataExportWorkspace struct {
+	Workspace
+	IsOwner   bool       `json:"is_owner"`
+	IsPrimary bool       `json:"is_primary"`
+	JoinedAt  *time.Time `json:"joined_at"`
+}
+
+// DataExportAttachment is a session attachment, as written to the export
+// archive. File is the path of the original file inside the archive.
+type DataExportAttachment struct {
+	SessionAttachment
+	File string `json:"file,omitempty"`
+}
@@ -0,0 +1,34 @@
+package types
+
+import (
+	"server/utils"
+	"time"
+)
+
+type WorkspaceInvitation struct {
+	ID          int        `json:"id" gorm:"primary_key"`
+	WorkspaceId int        `json:"workspace_id" gorm:"index"`
+	Email       string     `json:"email" gorm:"index"`
+	Role        string     `json:"role"`
+	InvitedBy   int        `json:"invited_by"`
+	TokenHash   string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	AcceptedBy  *int       `json:"accepted_by"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   *time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (e *WorkspaceInvitation) TableName() string {
+	return utils.WORKSPACE_INVITATIONS_TABLE
+}
+
+type WorkspaceInvitationPayload struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"omitempty,oneof=admin member viewer"`
+}
+
+type AcceptInvitationPayload struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -0,0 +1,49 @@
+package types
+
+// ListField describes a field of a list endpoint that can be filtered or
+// sorted on. Fields without a Column are handled by the endpoint itself.
+type ListField struct {
+	Column string
+	Type   string
+	Filter bool
+	Sort   bool
+}
+
+// ListQuerySpec is the set of fields, defaults and limits a list endpoint
+// accepts.
+type ListQuerySpec struct {
+	Fields       map[string]ListField
+	DefaultSort  []ListSort
+	DefaultLimit int
+	MaxLimit     int
+}
+
+type ListFilter struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+type ListSort struct {
+	Field string
+	Desc  bool
+}
+
+// ListQuery is a parsed list request: its filters, sort order, page size and
+// the cursor of the page to continue from.
+type ListQuery struct {
+	Filters []ListFilter
+	Sort    []ListSort
+	Limit   int
+	Cursor  string
+}
+
+// Filter returns the value of a filter on field with the given operator.
+func (q ListQuery) Filter(field, op string) (interface{}, bool) {
+	for _, filter := range q.Filters {
+		if filter.Field == field && filter.Op == op {
+			return filter.Value, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,188 @@
+package types
+
+import (
+	"server/utils"
+	"time"
+)
+
+type OAuthClient struct {
+	ID           int        `json:"id" gorm:"primary_key"`
+	ClientId     string     `json:"client_id" gorm:"uniqueIndex"`
+	ClientSecret string     `json:"-"`
+	Name         string     `json:"name"`
+	RedirectURIs string     `json:"redirect_uris"`
+	Scopes       string     `json:"scopes"`
+	IsPublic     bool       `json:"is_public"`
+	CreatedBy    int        `json:"created_by"`
+	Status       bool       `json:"status"`
+	CreatedAt    *time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (e *OAuthClient) TableName() string {
+	return utils.OAUTH_CLIENTS_TABLE
+}
+
+type OAuthClientPayload struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,dive,url"`
+	IsPublic     bool     `json:"is_public"`
+}
+
+type OAuthClientResponse struct {
+	ClientId     string     `json:"client_id"`
+	ClientSecret string     `json:"client_secret,omitempty"`
+	Name         string     `json:"name"`
+	RedirectURIs []string   `json:"redirect_uris"`
+	Scopes       []string   `json:"scopes"`
+	IsPublic     bool       `json:"is_public"`
+	CreatedAt    *time.Time `json:"created_at"`
+}
+
+type OAuthAuthorizationCode struct {
+	ID                  int        `json:"id" gorm:"primary_key"`
+	CodeHash            string     `json:"-" gorm:"uniqueIndex"`
+	ClientId            string     `json:"client_id"`
+	UserId              int        `json:"user_id"`
+	RedirectURI         string     `json:"redirect_uri"`
+	Scope               string     `json:"scope"`
+	Nonce               string     `json:"nonce"`
+	CodeChallenge       string     `json:"-"`
+	CodeChallengeMethod string     `json:"-"`
+	AuthTime            *time.Time `json:"auth_time"`
+	ExpiresAt           *time.Time `json:"expires_at"`
+	Used                bool       `json:"used"`
+	CreatedAt           *time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (e *OAuthAuthorizationCode) TableName() string {
+	return utils.OAUTH_AUTHORIZATION_CODES_TABLE
+}
+
+type OAuthRefreshToken struct {
+	ID        int        `json:"id" gorm:"primary_key"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ClientId  string     `json:"client_id"`
+	UserId    int        `json:"user_id"`
+	Scope     string     `json:"scope"`
+	AuthTime  *time.Time `json:"auth_time"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Revoked   bool       `json:"revoked"`
+	CreatedAt *time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (e *OAuthRefreshToken) TableName() string {
+	return utils.OAUTH_REFRESH_TOKENS_TABLE
+}
+
+type OAuthConsent struct {
+	ID        int        `json:"id" gorm:"primary_key"`
+	UserId    int        `json:"user_id" gorm:"uniqueIndex:idx_oauth_consent_user_client"`
+	ClientId  string     `json:"client_id" gorm:"uniqueIndex:idx_oauth_consent_user_client"`
+	Scope     string     `json:"scope"`
+	CreatedAt *time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (e *OAuthConsent) TableName() string {
+	return utils.OAUTH_CONSENTS_TABLE
+}
+
+type OAuthSigningKey struct {
+	ID         int        `json:"id" gorm:"primary_key"`
+	Kid        string     `json:"kid" gorm:"uniqueIndex"`
+	PrivateKey string     `json:"-"`
+	RotatedAt  *time.Time `json:"rotated_at"`
+	RetiredAt  *time.Time `json:"retired_at"`
+	CreatedAt  *time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (e *OAuthSigningKey) TableName() string {
+	return utils.OAUTH_SIGNING_KEYS_TABLE
+}
+
+type OAuthAuthorizePayload struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required"`
+	ClientId            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	Scope               string `form:"scope" json:"scope" binding:"required"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	Prompt              string `form:"prompt" json:"prompt"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
+
+type OAuthConsentPayload struct {
+	OAuthAuthorizePayload
+	Approve bool `json:"approve"`
+}
+
+type OAuthAuthorizeResponse struct {
+	ConsentRequired bool     `json:"consent_required"`
+	ClientName      string   `json:"client_name,omitempty"`
+	Scopes          []string `json:"scopes,omitempty"`
+	RedirectTo      string   `json:"redirect_to,omitempty"`
+}
+
+type OAuthTokenPayload struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientId     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IdToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type OIDCUserInfo struct {
+	Sub     string `json:"sub"`
+	Name    string `json:"name,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Picture string `json:"picture,omitempty"`
+}
+
+type OIDCDiscoveryResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
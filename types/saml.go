@@ -0,0 +1,149 @@
+package types
+
+import (
+	"encoding/xml"
+	"server/utils"
+	"time"
+)
+
+type SAMLIdPPayload struct {
+	Metadata string `json:"metadata" binding:"required"`
+	Enabled  bool   `json:"enabled"`
+}
+
+type SAMLConfigResponse struct {
+	WorkspaceId int    `json:"workspace_id"`
+	Enabled     bool   `json:"enabled"`
+	IdPEntityId string `json:"idp_entity_id"`
+	IdPSSOURL   string `json:"idp_sso_url"`
+	SPEntityId  string `json:"sp_entity_id"`
+	SPACSURL    string `json:"sp_acs_url"`
+}
+
+type SAMLAssertionReplay struct {
+	ID          int        `json:"id" gorm:"primary_key"`
+	AssertionId string     `json:"assertion_id" gorm:"uniqueIndex"`
+	WorkspaceId int        `json:"workspace_id"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedAt   *time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (e *SAMLAssertionReplay) TableName() string {
+	return utils.SAML_ASSERTION_REPLAYS_TABLE
+}
+
+// SAMLPendingRequest is an AuthnRequest sent to an IdP that has not been
+// answered yet. Responses must refer to one with InResponseTo.
+type SAMLPendingRequest struct {
+	ID          int        `json:"id" gorm:"primary_key"`
+	RequestId   string     `json:"request_id" gorm:"uniqueIndex"`
+	WorkspaceId int        `json:"workspace_id"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedAt   *time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (e *SAMLPendingRequest) TableName() string {
+	return utils.SAML_PENDING_REQUESTS_TABLE
+}
+
+// SAML metadata, used both to parse IdP metadata and to render SP metadata.
+// Elements are matched on their local name so documents using any prefix work.
+
+type SAMLEntityDescriptor struct {
+	XMLName          xml.Name              `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityID         string                `xml:"entityID,attr"`
+	IDPSSODescriptor *SAMLIDPSSODescriptor `xml:"IDPSSODescriptor,omitempty"`
+	SPSSODescriptor  *SAMLSPSSODescriptor  `xml:"SPSSODescriptor,omitempty"`
+}
+
+type SAMLIDPSSODescriptor struct {
+	WantAuthnRequestsSigned bool                `xml:"WantAuthnRequestsSigned,attr"`
+	KeyDescriptors          []SAMLKeyDescriptor `xml:"KeyDescriptor"`
+	SingleSignOnServices    []SAMLEndpoint      `xml:"SingleSignOnService"`
+}
+
+type SAMLSPSSODescriptor struct {
+	AuthnRequestsSigned        bool                  `xml:"AuthnRequestsSigned,attr"`
+	WantAssertionsSigned       bool                  `xml:"WantAssertionsSigned,attr"`
+	ProtocolSupportEnumeration string                `xml:"protocolSupportEnumeration,attr"`
+	NameIDFormats              []string              `xml:"NameIDFormat"`
+	AssertionConsumerServices  []SAMLIndexedEndpoint `xml:"AssertionConsumerService"`
+}
+
+type SAMLKeyDescriptor struct {
+	Use             string `xml:"use,attr,omitempty"`
+	X509Certificate string `xml:"KeyInfo>X509Data>X509Certificate"`
+}
+
+type SAMLEndpoint struct {
+	Binding  string `xml:"Binding,attr"`
+	Location string `xml:"Location,attr"`
+}
+
+type SAMLIndexedEndpoint struct {
+	Binding   string `xml:"Binding,attr"`
+	Location  string `xml:"Location,attr"`
+	Index     int    `xml:"index,attr"`
+	IsDefault bool   `xml:"isDefault,attr"`
+}
+
+type SAMLAuthnRequest struct {
+	XMLName                     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID                          string   `xml:"ID,attr"`
+	Version                     string   `xml:"Version,attr"`
+	IssueInstant                string   `xml:"IssueInstant,attr"`
+	Destination                 string   `xml:"Destination,attr"`
+	ProtocolBinding             string   `xml:"ProtocolBinding,attr"`
+	AssertionConsumerServiceURL string   `xml:"AssertionConsumerServiceURL,attr"`
+	Issuer                      string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+}
+
+// SAML assertion contents. Only ever unmarshalled from the element returned
+// by signature validation, never from the raw response.
+
+type SAMLAssertion struct {
+	ID             string              `xml:"ID,attr"`
+	IssueInstant   string              `xml:"IssueInstant,attr"`
+	Issuer         string              `xml:"Issuer"`
+	Subject        SAMLSubject         `xml:"Subject"`
+	Conditions     SAMLConditions      `xml:"Conditions"`
+	Attributes     []SAMLAttribute     `xml:"AttributeStatement>Attribute"`
+	AuthnStatement *SAMLAuthnStatement `xml:"AuthnStatement"`
+}
+
+type SAMLSubject struct {
+	NameID               SAMLNameID                `xml:"NameID"`
+	SubjectConfirmations []SAMLSubjectConfirmation `xml:"SubjectConfirmation"`
+}
+
+type SAMLNameID struct {
+	Format string `xml:"Format,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type SAMLSubjectConfirmation struct {
+	Method string                      `xml:"Method,attr"`
+	Data   SAMLSubjectConfirmationData `xml:"SubjectConfirmationData"`
+}
+
+type SAMLSubjectConfirmationData struct {
+	NotOnOrAfter string `xml:"NotOnOrAfter,attr"`
+	Recipient    string `xml:"Recipient,attr"`
+	InResponseTo string `xml:"InResponseTo,attr"`
+}
+
+type SAMLConditions struct {
+	NotBefore    string   `xml:"NotBefore,attr"`
+	NotOnOrAfter string   `xml:"NotOnOrAfter,attr"`
+	Audiences    []string `xml:"AudienceRestriction>Audience"`
+}
+
+type SAMLAttribute struct {
+	Name   string   `xml:"Name,attr"`
+	Values []string `xml:"AttributeValue"`
+}
+
+type SAMLAuthnStatement struct {
+	AuthnInstant string `xml:"AuthnInstant,attr"`
+	SessionIndex string `xml:"SessionIndex,attr"`
+}
@@ -5,26 +5,59 @@ import (
 	"time"
 )
 
+// Session is a scheduled research session. Datetime and EndsAt are instants;
+// Timezone is the IANA zone the session was planned in and is used to
+// present them.
 type Session struct {
-	ID        int        `json:"id" gorm:"primary_key"`
-	Title     string     `json:"title"`
-	Objective string     `json:"objective"`
-	Stage     string     `json:"stage"`
-	Datetime  string     `json:"datetime"`
-	Duration  int        `json:"duration"`
-	Status    bool       `json:"status"`
-	CreatedBy int        `json:"created_by"`
-	CreatedAt *time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
-}
-
+	ID              int       `json:"id" gorm:"primary_key"`
+	Title           string    `json:"title"`
+	Objective       string    `json:"objective"`
+	Stage           string    `json:"stage" gorm:"index"`
+	Datetime        time.Time `json:"datetime" gorm:"type:timestamptz;index"`
+	EndsAt          time.Time `json:"ends_at" gorm:"type:timestamptz;index"`
+	Timezone        string    `json:"timezone" gorm:"default:UTC"`
+	DurationMinutes int       `json:"duration_minutes"`
+	Status          bool      `json:"status"`
+	// Sequence counts the revisions sent to calendars, see RFC 5545.
+	Sequence    int        `json:"-" gorm:"not null;default:0"`
+	CreatedBy   int        `json:"created_by" gorm:"index"`
+	WorkspaceId int        `json:"workspace_id" gorm:"index"`
+	CreatedAt   *time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+	UpdatedAt   *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// SessionPayload creates a session. Datetime is RFC 3339 with an offset;
+// Timezone defaults to the creator's time zone.
 type SessionPayload struct {
-	Title         string `json:"title" binding:"required"`
-	Objective     string `json:"objective" binding:"required"`
-	Stage         string `json:"stage" binding:"required"`
-	Datetime      string `json:"datetime" binding:"required"`
-	Duration      int    `json:"duration" binding:"required"`
-	Collaborators string `json:"collaborators"`
+	Title           string                     `json:"title" binding:"required"`
+	Objective       string                     `json:"objective" binding:"required"`
+	Stage           string                     `json:"stage" binding:"required"`
+	Datetime        string                     `json:"datetime" binding:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	Timezone        string                     `json:"timezone" binding:"omitempty,timezone"`
+	DurationMinutes int                        `json:"duration_minutes" binding:"required,min=1,max=1440"`
+	Collaborators   []SessionCollaboratorInput `json:"collaborators" binding:"omitempty,max=50,dive"`
+	AllowConflicts  bool                       `json:"allow_conflicts"`
+}
+
+type UpdateSessionPayload struct {
+	Title           *string `json:"title" binding:"omitempty,min=1"`
+	Objective       *string `json:"objective" binding:"omitempty,min=1"`
+	Stage           *string `json:"stage" binding:"omitempty,min=1"`
+	Datetime        *string `json:"datetime" binding:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Timezone        *string `json:"timezone" binding:"omitempty,timezone"`
+	DurationMinutes *int    `json:"duration_minutes" binding:"omitempty,min=1,max=1440"`
+	Status          *bool   `json:"status"`
+	AllowConflicts  bool    `json:"allow_conflicts"`
+}
+
+// SessionConflict is a participant who is already booked in another session
+// at the same time.
+type SessionConflict struct {
+	UserId    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	SessionId int       `json:"session_id"`
+	Datetime  time.Time `json:"datetime"`
+	EndsAt    time.Time `json:"ends_at"`
 }
 
 func (e *Session) TableName() string {
@@ -32,18 +65,33 @@ func (e *Session) TableName() string {
 }
 
 type SessionAttachment struct {
-	ID        int        `json:"id" gorm:"primary_key"`
-	SessionId int        `json:"session_id"`
-	Url       string     `json:"url"`
-	Name      string     `json:"name"`
-	Category  string     `json:"category"`
-	Status    bool       `json:"status"`
-	CreatedAt *time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID          int        `json:"id" gorm:"primary_key"`
+	SessionId   int        `json:"session_id"`
+	WorkspaceId int        `json:"workspace_id" gorm:"index"`
+	Url         string     `json:"url"`
+	Name        string     `json:"name"`
+	Category    string     `json:"category"`
+	Size        int64      `json:"size"`
+	ContentType string     `json:"content_type"`
+	UploadedBy  int        `json:"uploaded_by"`
+	Status      bool       `json:"status"`
+	CreatedAt   *time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 type SessionAttachmentsPayload struct {
-	SessionId int `form:"session_id" binding:"required"`
+	Category string `form:"category" binding:"required,session_category"`
+}
+
+type SessionAttachmentQuery struct {
+	Category string `form:"category" binding:"omitempty,session_category"`
+}
+
+// SessionAttachmentResponse is an attachment with a short-lived link to
+// download it.
+type SessionAttachmentResponse struct {
+	SessionAttachment
+	DownloadURL string `json:"download_url"`
 }
 
 type SessionAttachmentFileData struct {
@@ -56,19 +104,20 @@ func (e *SessionAttachment) TableName() string {
 }
 
 type SessionCollaborator struct {
-	ID        int        `json:"id" gorm:"primary_key"`
-	SessionId int        `json:"session_id"`
-	UserId    int        `json:"user_id"`
-	Status    bool       `json:"status"`
-	CreatedAt *time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID          int        `json:"id" gorm:"primary_key"`
+	SessionId   int        `json:"session_id"`
+	WorkspaceId int        `json:"workspace_id" gorm:"index"`
+	UserId      int        `json:"user_id"`
+	Status      bool       `json:"status"`
+	CreatedAt   *time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 type ProcessedSessionCollaborator struct {
 	ID     int    `json:"id" gorm:"primary_key"`
 	UserId int    `json:"user_id"`
 	Name   string `json:"name"`
-	Email  string `json:"string"`
+	Email  string `json:"email"`
 	Avatar string `json:"avatar"`
 	Status bool   `json:"status"`
 }
@@ -78,6 +127,19 @@ type SessionCollaboratorPayload struct {
 	Title     string `json:"title" binding:"required"`
 }
 
+// SessionCollaboratorInput names a workspace member to add to a session by
+// user ID or email.
+type SessionCollaboratorInput struct {
+	UserId int    `json:"user_id" binding:"required_without=Email,omitempty,min=1"`
+	Email  string `json:"email" binding:"required_without=UserId,omitempty,email"`
+}
+
+// AddSessionCollaboratorPayload adds a collaborator to an existing session.
+type AddSessionCollaboratorPayload struct {
+	SessionCollaboratorInput
+	AllowConflicts bool `json:"allow_conflicts"`
+}
+
 type SessionCollaboratorResponse struct {
 	ID        int    `json:"id"`
 	SessionId int    `json:"session_id"`
@@ -107,24 +169,33 @@ type CollaboratorResponse struct {
 }
 
 type ProcessedSession struct {
-	ID        int    `json:"id"`
-	Title     string `json:"title"`
-	Objective string `json:"objective"`
-	Stage     string `json:"stage"`
-	Datetime  string `json:"datetime"`
-	Duration  int    `json:"duration"`
-	Status    bool   `json:"status"`
-	CreatedBy int    `json:"created_by"`
+	ID              int       `json:"id"`
+	Title           string    `json:"title"`
+	Objective       string    `json:"objective"`
+	Stage           string    `json:"stage"`
+	Datetime        time.Time `json:"datetime"`
+	EndsAt          time.Time `json:"ends_at"`
+	Timezone        string    `json:"timezone"`
+	DurationMinutes int       `json:"duration_minutes"`
+	Status          bool      `json:"status"`
+	CreatedBy       int       `json:"created_by"`
+}
+
+type SessionListResponse struct {
+	Sessions   []ProcessedSessionResponse `json:"sessions"`
+	NextCursor string                     `json:"next_cursor"`
 }
 
 type ProcessedSessionResponse struct {
-	ID            int                    `json:"id"`
-	Title         string                 `json:"title"`
-	Objective     string                 `json:"objective"`
-	Stage         string                 `json:"stage"`
-	Datetime      string                 `json:"datetime"`
-	Duration      int                    `json:"duration"`
-	Status        bool                   `json:"status"`
-	CreatedBy     int                    `json:"created_by"`
-	Collaborators []CollaboratorResponse `json:"collaborators"`
+	ID              int                    `json:"id"`
+	Title           string                 `json:"title"`
+	Objective       string                 `json:"objective"`
+	Stage           string                 `json:"stage"`
+	Datetime        time.Time              `json:"datetime"`
+	EndsAt          time.Time              `json:"ends_at"`
+	Timezone        string                 `json:"timezone"`
+	DurationMinutes int                    `json:"duration_minutes"`
+	Status          bool                   `json:"status"`
+	CreatedBy       int                    `json:"created_by"`
+	Collaborators   []CollaboratorResponse `json:"collaborators"`
 }
@@ -0,0 +1,40 @@
+package types
+
+// UserSettings are a user's preferences with defaults filled in for
+// everything they haven't set. DefaultWorkspaceId is the workspace of the
+// user's primary membership, which login signs them into.
+type UserSettings struct {
+	Timezone           string               `json:"timezone"`
+	DateFormat         string               `json:"date_format"`
+	TimeFormat         string               `json:"time_format"`
+	WeekStart          string               `json:"week_start"`
+	DefaultWorkspaceId *int                 `json:"default_workspace_id"`
+	Notifications      NotificationSettings `json:"notifications"`
+}
+
+type NotificationSettings struct {
+	SessionInvites   bool `json:"session_invites"`
+	SessionReminders bool `json:"session_reminders"`
+	SessionUpdates   bool `json:"session_updates"`
+	WorkspaceInvites bool `json:"workspace_invites"`
+	ProductUpdates   bool `json:"product_updates"`
+}
+
+// UserSettingsPayload changes the settings that are sent and leaves the rest
+// as they are. A default_workspace_id of 0 clears the default workspace.
+type UserSettingsPayload struct {
+	Timezone           *string                      `json:"timezone,omitempty" binding:"omitempty,timezone"`
+	DateFormat         *string                      `json:"date_format,omitempty" binding:"omitempty,oneof=YYYY-MM-DD DD/MM/YYYY MM/DD/YYYY DD.MM.YYYY"`
+	TimeFormat         *string                      `json:"time_format,omitempty" binding:"omitempty,oneof=12h 24h"`
+	WeekStart          *string                      `json:"week_start,omitempty" binding:"omitempty,oneof=monday sunday saturday"`
+	DefaultWorkspaceId *int                         `json:"default_workspace_id,omitempty" binding:"omitempty,min=0"`
+	Notifications      *NotificationSettingsPayload `json:"notifications,omitempty"`
+}
+
+type NotificationSettingsPayload struct {
+	SessionInvites   *bool `json:"session_invites,omitempty"`
+	SessionReminders *bool `json:"session_reminders,omitempty"`
+	SessionUpdates   *bool `json:"session_updates,omitempty"`
+	WorkspaceInvites *bool `json:"workspace_invites,omitempty"`
+	ProductUpdates   *bool `json:"product_updates,omitempty"`
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"server/utils"
+	"time"
+)
+
+// WorkspaceUsage holds the running totals a workspace's plan limits are
+// checked against. Seats are counted from the memberships directly.
+type WorkspaceUsage struct {
+	WorkspaceId  int        `json:"workspace_id" gorm:"primary_key;autoIncrement:false"`
+	Sessions     int64      `json:"sessions"`
+	StorageBytes int64      `json:"storage_bytes"`
+	UpdatedAt    *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (e *WorkspaceUsage) TableName() string {
+	return utils.WORKSPACE_USAGE_TABLE
+}
+
+// UsageLimit is the amount used of one resource and the plan's limit on it.
+// A limit of 0 means unlimited.
+type UsageLimit struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
+
+type WorkspaceUsageResponse struct {
+	Plan         string     `json:"plan"`
+	Seats        UsageLimit `json:"seats"`
+	Sessions     UsageLimit `json:"sessions"`
+	StorageBytes UsageLimit `json:"storage_bytes"`
+}
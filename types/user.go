@@ -1,24 +1,73 @@
 package types
 
-import "time"
+import (
+	"server/utils"
+	"time"
+)
 
 type User struct {
-	ID        int        `json:"id" gorm:"primary_key"`
-	Name      string     `json:"name"`
-	Email     string     `json:"email"`
-	Password  string     `json:"password"`
-	Avatar    string     `json:"avatar"`
-	CreatedAt *time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID          int               `json:"id" gorm:"primary_key"`
+	Name        string            `json:"name"`
+	Email       string            `json:"email"`
+	Password    string            `json:"password"`
+	Avatar      string            `json:"avatar"`
+	AvatarSizes map[string]string `json:"avatar_sizes" gorm:"serializer:json;type:jsonb"`
+	Timezone    string            `json:"timezone"`
+	Locale      string            `json:"locale"`
+	Title       string            `json:"title"`
+	Bio         string            `json:"bio"`
+	CreatedAt   *time.Time        `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   *time.Time        `json:"updated_at" gorm:"autoUpdateTime"`
+
+	Role                  string     `json:"role" gorm:"default:user"`
+	Provider              string     `json:"provider"`
+	SuspendedAt           *time.Time `json:"suspended_at"`
+	TokensRevokedAt       *time.Time `json:"tokens_revoked_at"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+
+	// Settings holds only the preferences the user has changed. Read them
+	// through models.FetchUserSettings, which fills in the defaults.
+	Settings map[string]interface{} `json:"-" gorm:"serializer:json;type:jsonb"`
 }
 
 type UserResponse struct {
-	ID        int        `json:"id"`
-	Name      string     `json:"name"`
-	Email     string     `json:"email"`
-	Avatar    string     `json:"avatar"`
-	CreatedAt *time.Time `json:"created_at"`
-	UpdatedAt *time.Time `json:"updated_at"`
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Email       string            `json:"email"`
+	Avatar      string            `json:"avatar"`
+	AvatarSizes map[string]string `json:"avatar_sizes"`
+	Timezone    string            `json:"timezone"`
+	Locale      string            `json:"locale"`
+	Title       string            `json:"title"`
+	Bio         string            `json:"bio"`
+	CreatedAt   *time.Time        `json:"created_at"`
+	UpdatedAt   *time.Time        `json:"updated_at"`
+}
+
+type PublicUserResponse struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Email       string            `json:"email,omitempty"`
+	Avatar      string            `json:"avatar"`
+	AvatarSizes map[string]string `json:"avatar_sizes"`
+	Timezone    string            `json:"timezone"`
+	Locale      string            `json:"locale"`
+	Title       string            `json:"title"`
+	Bio         string            `json:"bio"`
+}
+
+type UpdateUserPayload struct {
+	Name     *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Avatar   *string `json:"avatar" binding:"omitempty,http_url"`
+	Timezone *string `json:"timezone" binding:"omitempty,timezone"`
+	Locale   *string `json:"locale" binding:"omitempty,bcp47_language_tag"`
+	Title    *string `json:"title" binding:"omitempty,max=100"`
+	Bio      *string `json:"bio" binding:"omitempty,max=500"`
+}
+
+type UserSearchQuery struct {
+	Query string `form:"q" binding:"required,min=1,max=100"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=25"`
 }
 
 type LoginPayload struct {
@@ -27,20 +76,55 @@ type LoginPayload struct {
 }
 
 type RegisterPayload struct {
-	Name     string `json:"name" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8,max=30"`
+	Name            string `json:"name" binding:"required"`
+	Email           string `json:"email" binding:"required,email"`
+	Password        string `json:"password" binding:"required,min=8,max=30"`
+	InvitationToken string `json:"invitation_token"`
 }
 
 type SocialLoginPayload struct {
-	Token    string `json:"token" binding:"required"`
-	Provider string `json:"provider" binding:"required"`
+	Token           string `json:"token" binding:"required"`
+	Provider        string `json:"provider" binding:"required"`
+	InvitationToken string `json:"invitation_token"`
 }
 
 type AuthResponse struct {
-	Token string `json:"token"`
+	Token     string `json:"token,omitempty"`
+	CSRFToken string `json:"csrf_token,omitempty"`
+}
+
+type AdminUserQuery struct {
+	Query       string     `form:"q"`
+	Status      string     `form:"status" binding:"omitempty,oneof=active suspended"`
+	Provider    string     `form:"provider" binding:"omitempty,oneof=password google saml"`
+	CreatedFrom *time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo   *time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page        int        `form:"page" binding:"omitempty,min=1"`
+	PerPage     int        `form:"per_page" binding:"omitempty,min=1,max=100"`
+}
+
+type AdminUserResponse struct {
+	ID                    int        `json:"id"`
+	Name                  string     `json:"name"`
+	Email                 string     `json:"email"`
+	Avatar                string     `json:"avatar"`
+	Role                  string     `json:"role"`
+	Provider              string     `json:"provider"`
+	Status                string     `json:"status"`
+	SuspendedAt           *time.Time `json:"suspended_at"`
+	TokensRevokedAt       *time.Time `json:"tokens_revoked_at"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	CreatedAt             *time.Time `json:"created_at"`
+	UpdatedAt             *time.Time `json:"updated_at"`
+}
+
+type AdminUserListResponse struct {
+	Users   []AdminUserResponse `json:"users"`
+	Total   int64               `json:"total"`
+	Page    int                 `json:"page"`
+	PerPage int                 `json:"per_page"`
 }
 
 func (e *User) TableName() string {
-	return "users"
+	return utils.USERS_TABLE
 }
@@ -1,24 +1,51 @@
 package types
 
-import "time"
+import (
+	"server/utils"
+	"time"
+)
 
 type Workspace struct {
-	ID        int        `json:"id" gorm:"primary_key"`
-	Name      string     `json:"name"`
-	Avatar    string     `json:"avatar"`
-	Role      string     `json:"role"`
-	Status    bool       `json:"status"`
-	CreatedAt *time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID          int               `json:"id" gorm:"primary_key"`
+	Name        string            `json:"name"`
+	Avatar      string            `json:"avatar"`
+	AvatarSizes map[string]string `json:"avatar_sizes" gorm:"serializer:json;type:jsonb"`
+	Role        string            `json:"role"`
+	Status      bool              `json:"status"`
+	CreatedAt   *time.Time        `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   *time.Time        `json:"updated_at" gorm:"autoUpdateTime"`
+
+	SamlEnabled     bool   `json:"saml_enabled"`
+	SamlIdpMetadata string `json:"-" gorm:"type:text"`
+
+	ArchivedAt *time.Time `json:"archived_at"`
+	Plan       string     `json:"plan" gorm:"default:free"`
+
+	// A deleted workspace is archived and purged with everything in it once
+	// PurgeAfter has passed, unless the owner restores it first.
+	DeletedAt  *time.Time `json:"deleted_at"`
+	PurgeAfter *time.Time `json:"purge_after"`
 }
 
 type WorkspacePayload struct {
-	Name string `form:"name" binding:"required"`
-	Role string `form:"role" binding:"required"`
+	Name string `form:"name" binding:"required,max=100"`
+	Role string `form:"role" binding:"required,max=50"`
+}
+
+type UpdateWorkspacePayload struct {
+	Name *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Role *string `json:"role" binding:"omitempty,min=1,max=50"`
+}
+
+// WorkspaceResponse is a workspace together with the caller's membership.
+type WorkspaceResponse struct {
+	Workspace
+	IsOwner   bool `json:"is_owner"`
+	IsPrimary bool `json:"is_primary"`
 }
 
 func (e *Workspace) TableName() string {
-	return "workspaces"
+	return utils.WORKSPACES_TABLE
 }
 
 type WorkspaceUser struct {
@@ -27,10 +54,36 @@ type WorkspaceUser struct {
 	UserId      int        `json:"user_id"`
 	IsOwner     bool       `json:"is_owner"`
 	IsPrimary   bool       `json:"is_primary"`
+	Role        string     `json:"role" gorm:"default:member"`
 	CreatedAt   *time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt   *time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 func (e *WorkspaceUser) TableName() string {
-	return "workspace_users"
+	return utils.WORKSPACE_USERS_TABLE
+}
+
+// WorkspaceMemberPayload adds an existing user to a workspace by ID or email.
+type WorkspaceMemberPayload struct {
+	UserId int    `json:"user_id" binding:"required_without=Email,omitempty,min=1"`
+	Email  string `json:"email" binding:"required_without=UserId,omitempty,email"`
+	Role   string `json:"role" binding:"omitempty,oneof=admin member viewer"`
+}
+
+type WorkspaceMemberRolePayload struct {
+	Role string `json:"role" binding:"required,oneof=admin member viewer"`
+}
+
+type TransferOwnershipPayload struct {
+	UserId int `json:"user_id" binding:"required,min=1"`
+}
+
+type WorkspaceMemberResponse struct {
+	UserId   int        `json:"user_id"`
+	Name     string     `json:"name"`
+	Email    string     `json:"email"`
+	Avatar   string     `json:"avatar"`
+	Role     string     `json:"role"`
+	IsOwner  bool       `json:"is_owner"`
+	JoinedAt *time.Time `json:"joined_at"`
 }
@@ -0,0 +1,20 @@
+package utils
+
+import "time"
+
+const AUTH_COOKIE_NAME string = "auth_token"
+const CSRF_COOKIE_NAME string = "csrf_token"
+const CSRF_HEADER_NAME string = "X-CSRF-Token"
+
+const PASSWORD_RESET_TOKEN_TTL time.Duration = time.Hour
+const EMAIL_VERIFICATION_TOKEN_TTL time.Duration = 24 * time.Hour
+
+const USER_ROLE_USER string = "user"
+const USER_ROLE_ADMIN string = "admin"
+
+const USER_PROVIDER_PASSWORD string = "password"
+const USER_PROVIDER_GOOGLE string = "google"
+const USER_PROVIDER_SAML string = "saml"
+
+const USER_STATUS_ACTIVE string = "active"
+const USER_STATUS_SUSPENDED string = "suspended"
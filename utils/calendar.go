@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const CALENDAR_PRODID string = "-//Go Server//Sessions//EN"
+const CALENDAR_CONTENT_TYPE string = "text/calendar; charset=utf-8"
+
+// iTIP methods of a calendar, see RFC 5546.
+const CALENDAR_METHOD_PUBLISH string = "PUBLISH"
+const CALENDAR_METHOD_REQUEST string = "REQUEST"
+const CALENDAR_METHOD_CANCEL string = "CANCEL"
+
+// CALENDAR_FEED_PAST_WINDOW is how far back a calendar feed lists sessions.
+const CALENDAR_FEED_PAST_WINDOW time.Duration = 90 * 24 * time.Hour
+const CALENDAR_FEED_MAX_EVENTS int = 1000
+
+// CALENDAR_FEED_REFRESH is the refresh interval suggested to calendar apps.
+const CALENDAR_FEED_REFRESH string = "PT1H"
+
+// CalendarPerson is the organizer or an attendee of an event.
+type CalendarPerson struct {
+	Name  string
+	Email string
+}
+
+// CalendarEvent is a VEVENT. UID stays the same for the life of the event and
+// Sequence goes up with every revision, so calendar apps update or cancel the
+// event they already have.
+type CalendarEvent struct {
+	UID         string
+	Sequence    int
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	URL         string
+	Cancelled   bool
+	Updated     time.Time
+	Organizer   CalendarPerson
+	Attendees   []CalendarPerson
+}
+
+// BuildCalendar encodes events as an RFC 5545 iCalendar object. Times are
+// written in UTC. An empty method leaves out METHOD, as feeds should.
+func BuildCalendar(name, method string, events []CalendarEvent) []byte {
+	var b strings.Builder
+	line := func(content string) {
+		b.WriteString(foldCalendarLine(content))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:" + CALENDAR_PRODID)
+	line("CALSCALE:GREGORIAN")
+	if method != "" {
+		line("METHOD:" + method)
+	}
+	if name != "" {
+		line("NAME:" + escapeCalendarText(name))
+		line("X-WR-CALNAME:" + escapeCalendarText(name))
+		line("REFRESH-INTERVAL;VALUE=DURATION:" + CALENDAR_FEED_REFRESH)
+		line("X-PUBLISHED-TTL:" + CALENDAR_FEED_REFRESH)
+	}
+	for _, event := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + event.UID)
+		line("DTSTAMP:" + calendarTime(event.Updated))
+		line("LAST-MODIFIED:" + calendarTime(event.Updated))
+		line(fmt.Sprintf("SEQUENCE:%d", event.Sequence))
+		line("DTSTART:" + calendarTime(event.Start))
+		line("DTEND:" + calendarTime(event.End))
+		line("SUMMARY:" + escapeCalendarText(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION:" + escapeCalendarText(event.Description))
+		}
+		if event.URL != "" {
+			line("URL:" + event.URL)
+		}
+		if event.Cancelled {
+			line("STATUS:CANCELLED")
+		} else {
+			line("STATUS:CONFIRMED")
+		}
+		if event.Organizer.Email != "" {
+			line(fmt.Sprintf("ORGANIZER;CN=%s:mailto:%s", quoteCalendarParam(event.Organizer.Name), event.Organizer.Email))
+		}
+		for _, attendee := range event.Attendees {
+			line(fmt.Sprintf("ATTENDEE;CN=%s;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=FALSE:mailto:%s", quoteCalendarParam(attendee.Name), attendee.Email))
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+func calendarTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escapeCalendarText escapes a TEXT value (RFC 5545 section 3.3.11).
+func escapeCalendarText(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(text)
+}
+
+// quoteCalendarParam quotes a parameter value. Double quotes can't be
+// escaped inside one, so they are dropped.
+func quoteCalendarParam(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r == '"' || r < ' ' {
+			return -1
+		}
+		return r
+	}, value)
+	return `"` + value + `"`
+}
+
+// foldCalendarLine splits a content line into lines of at most 75 octets,
+// continuing each with a space and never cutting a UTF-8 sequence.
+func foldCalendarLine(content string) string {
+	if len(content) <= 75 {
+		return content
+	}
+	var b strings.Builder
+	limit := 75
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && content[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(content[:cut])
+		b.WriteString("\r\n ")
+		content = content[cut:]
+		// The leading space counts towards the next line.
+		limit = 74
+	}
+	b.WriteString(content)
+	return b.String()
+}
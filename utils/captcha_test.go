@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// captchaStub serves a siteverify endpoint that accepts the token "pass".
+func captchaStub(t *testing.T, score *float64) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("secret") != "stub-secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(siteVerifyResponse{Success: r.PostForm.Get("response") == "pass", Score: score})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewCaptchaVerifierUsesStub(t *testing.T) {
+	server := captchaStub(t, nil)
+	t.Setenv("CAPTCHA_SECRET", "stub-secret")
+	t.Setenv("CAPTCHA_VERIFY_URL", server.URL)
+
+	for _, provider := range []string{"hcaptcha", "turnstile", "recaptcha"} {
+		t.Run(provider, func(t *testing.T) {
+			t.Setenv("CAPTCHA_PROVIDER", provider)
+			verifier := NewCaptchaVerifier()
+			if valid, err := verifier.Verify("pass", "203.0.113.1"); err != nil || !valid {
+				t.Errorf("Verify(pass) = %v, %v, want true", valid, err)
+			}
+			if valid, err := verifier.Verify("fail", "203.0.113.1"); err != nil || valid {
+				t.Errorf("Verify(fail) = %v, %v, want false", valid, err)
+			}
+		})
+	}
+}
+
+func TestRecaptchaVerifierRejectsLowScores(t *testing.T) {
+	score := 0.3
+	server := captchaStub(t, &score)
+	verifier := &RecaptchaVerifier{Secret: "stub-secret", VerifyURL: server.URL, MinScore: 0.5}
+	if valid, err := verifier.Verify("pass", ""); err != nil || valid {
+		t.Errorf("Verify with score %v = %v, %v, want false", score, valid, err)
+	}
+}
+
+func TestSiteVerifierReportsEndpointErrors(t *testing.T) {
+	server := captchaStub(t, nil)
+	verifier := &SiteVerifier{Secret: "wrong-secret", VerifyURL: server.URL}
+	if _, err := verifier.Verify("pass", ""); err == nil {
+		t.Error("Verify succeeded although the endpoint failed")
+	}
+}
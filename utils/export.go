@@ -0,0 +1,24 @@
+package utils
+
+import "time"
+
+const DATA_EXPORT_STATUS_PENDING string = "pending"
+const DATA_EXPORT_STATUS_PROCESSING string = "processing"
+const DATA_EXPORT_STATUS_COMPLETED string = "completed"
+const DATA_EXPORT_STATUS_FAILED string = "failed"
+
+// DATA_EXPORT_TTL is how long a finished export can be downloaded.
+const DATA_EXPORT_TTL time.Duration = 7 * 24 * time.Hour
+
+// DATA_EXPORT_LINK_TTL is how long a single download link stays valid.
+const DATA_EXPORT_LINK_TTL time.Duration = 15 * time.Minute
+
+const DATA_EXPORT_MAX_ATTACHMENT_BYTES int64 = 100 << 20
+
+// DATA_EXPORT_STALE_AFTER is how long an export can stay queued or running
+// before it is considered lost and a new one may be started.
+const DATA_EXPORT_STALE_AFTER time.Duration = time.Hour
+
+// DATA_EXPORT_CLEANUP_INTERVAL is how often archives of expired exports are
+// deleted from S3.
+const DATA_EXPORT_CLEANUP_INTERVAL time.Duration = time.Hour
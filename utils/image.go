@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+const AVATAR_MAX_UPLOAD_BYTES int64 = 5 << 20
+const AVATAR_MAX_PIXELS int = 40_000_000
+
+// AVATAR_SIZES are the edge lengths, in pixels, of the square thumbnails
+// generated for every avatar upload.
+var AVATAR_SIZES = []int{64, 128, 256, 512}
+
+// AVATAR_DEFAULT_SIZE is the thumbnail stored in the Avatar field.
+const AVATAR_DEFAULT_SIZE int = 256
+
+type ProcessedImage struct {
+	Size        int
+	Data        []byte
+	ContentType string
+	Extension   string
+}
+
+// ProcessAvatar checks that data is a JPEG, PNG or GIF image and renders it
+// into square thumbnails for each of AVATAR_SIZES. The image is decoded and
+// re-encoded, so EXIF and any other metadata are dropped; the EXIF orientation
+// of JPEGs is applied first so photos keep the right way up.
+func ProcessAvatar(data []byte) ([]ProcessedImage, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" && contentType != "image/gif" {
+		return nil, fmt.Errorf("unsupported image type %q", contentType)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("file is not a valid image: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > AVATAR_MAX_PIXELS {
+		return nil, fmt.Errorf("image dimensions %dx%d are not supported", cfg.Width, cfg.Height)
+	}
+
+	var src image.Image
+	switch format {
+	case "jpeg":
+		src, err = jpeg.Decode(bytes.NewReader(data))
+		if err == nil {
+			src = applyOrientation(src, jpegOrientation(data))
+		}
+	case "png":
+		src, err = png.Decode(bytes.NewReader(data))
+	case "gif":
+		src, err = gif.Decode(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unsupported image format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("file is not a valid image: %w", err)
+	}
+
+	square := cropSquare(src)
+	images := make([]ProcessedImage, 0, len(AVATAR_SIZES))
+	for _, size := range AVATAR_SIZES {
+		thumbnail := resize(square, size)
+		var buf bytes.Buffer
+		processed := ProcessedImage{Size: size}
+		if format == "jpeg" {
+			err = jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 85})
+			processed.ContentType, processed.Extension = "image/jpeg", "jpg"
+		} else {
+			err = png.Encode(&buf, thumbnail)
+			processed.ContentType, processed.Extension = "image/png", "png"
+		}
+		if err != nil {
+			return nil, err
+		}
+		processed.Data = buf.Bytes()
+		images = append(images, processed)
+	}
+	return images, nil
+}
+
+// ReadLimited reads at most limit bytes from r and fails if there is more.
+func ReadLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("file is larger than %d bytes", limit)
+	}
+	return data, nil
+}
+
+// cropSquare returns the centered square of the largest possible size.
+func cropSquare(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	edge := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-edge)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-edge)/2
+	dst := image.NewRGBA(image.Rect(0, 0, edge, edge))
+	draw.Draw(dst, dst.Bounds(), src, image.Point{X: x0, Y: y0}, draw.Src)
+	return dst
+}
+
+// resize scales a square image to size x size. Every destination pixel is the
+// average of the source pixels it covers, which gives clean downscaling
+// without pulling in an imaging library.
+func resize(src *image.RGBA, size int) *image.RGBA {
+	edge := src.Bounds().Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0 := y * edge / size
+		sy1 := max((y+1)*edge/size, sy0+1)
+		for x := 0; x < size; x++ {
+			sx0 := x * edge / size
+			sx1 := max((x+1)*edge/size, sx0+1)
+			var r, g, b, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					offset := src.PixOffset(sx, sy)
+					r += uint32(src.Pix[offset])
+					g += uint32(src.Pix[offset+1])
+					b += uint32(src.Pix[offset+2])
+					a += uint32(src.Pix[offset+3])
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation tag (1-8) of a JPEG, or 1 when
+// there is none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	offset := 2
+	for offset+4 <= len(data) && data[offset] == 0xFF {
+		marker := data[offset+1]
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if marker == 0xDA || length < 2 || offset+2+length > len(data) {
+			break
+		}
+		segment := data[offset+4 : offset+2+length]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		offset += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// applyOrientation transforms an image according to its EXIF orientation.
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, src.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
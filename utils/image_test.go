@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var (
+	red  = color.RGBA{R: 255, A: 255}
+	blue = color.RGBA{B: 255, A: 255}
+)
+
+// halves returns a w x h image whose left half is red and right half blue.
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < w/2 {
+				img.SetRGBA(x, y, red)
+			} else {
+				img.SetRGBA(x, y, blue)
+			}
+		}
+	}
+	return img
+}
+
+// tiffWithOrientation builds a TIFF header and a one-entry IFD holding the
+// orientation tag.
+func tiffWithOrientation(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], 0x0112)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+	return tiff
+}
+
+// withAPP1 inserts an APP1 segment holding payload right after the SOI
+// marker of a JPEG.
+func withAPP1(t *testing.T, jpg []byte, payload []byte) []byte {
+	t.Helper()
+	if len(jpg) < 2 || jpg[0] != 0xFF || jpg[1] != 0xD8 {
+		t.Fatal("not a JPEG")
+	}
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	out := append([]byte{0xFF, 0xD8}, segment...)
+	out = append(out, payload...)
+	return append(out, jpg[2:]...)
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func exif(tiff []byte) []byte {
+	return append([]byte("Exif\x00\x00"), tiff...)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	plain := encodeJPEG(t, halves(8, 4))
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"no exif", plain, 1},
+		{"empty", nil, 1},
+		{"shorter than a marker", []byte{0xFF, 0xD8, 0xFF}, 1},
+		{"not a jpeg", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"non-exif app1", withAPP1(t, plain, []byte("http://ns.adobe.com/xap/1.0/\x00")), 1},
+		{"exif header only", withAPP1(t, plain, []byte("Exif\x00\x00")), 1},
+		{"short tiff", withAPP1(t, plain, exif([]byte("II*\x00"))), 1},
+		{"bad byte order", withAPP1(t, plain, exif(append([]byte("XX"), tiffWithOrientation(binary.LittleEndian, 6)[2:]...))), 1},
+		{"orientation 0", withAPP1(t, plain, exif(tiffWithOrientation(binary.LittleEndian, 0))), 1},
+		{"orientation 9", withAPP1(t, plain, exif(tiffWithOrientation(binary.BigEndian, 9))), 1},
+		{"ifd offset past the end", withAPP1(t, plain, exif(func() []byte {
+			tiff := tiffWithOrientation(binary.LittleEndian, 6)
+			binary.LittleEndian.PutUint32(tiff[4:], 0xFFFFFFF0)
+			return tiff
+		}())), 1},
+		{"entry count past the end", withAPP1(t, plain, exif(func() []byte {
+			tiff := tiffWithOrientation(binary.BigEndian, 6)
+			binary.BigEndian.PutUint16(tiff[10:], 0x0100)
+			binary.BigEndian.PutUint16(tiff[8:], 0xFFFF)
+			return tiff
+		}())), 1},
+		{"app1 length past the end", func() []byte {
+			data := withAPP1(t, plain, exif(tiffWithOrientation(binary.LittleEndian, 6)))
+			binary.BigEndian.PutUint16(data[4:], 0xFFFF)
+			return data
+		}(), 1},
+		{"app1 length below two", func() []byte {
+			data := withAPP1(t, plain, exif(tiffWithOrientation(binary.LittleEndian, 6)))
+			binary.BigEndian.PutUint16(data[4:], 1)
+			return data
+		}(), 1},
+	}
+	for orientation := 1; orientation <= 8; orientation++ {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			tests = append(tests, struct {
+				name string
+				data []byte
+				want int
+			}{
+				name: order.String() + " orientation " + string(rune('0'+orientation)),
+				data: withAPP1(t, plain, exif(tiffWithOrientation(order, uint16(orientation)))),
+				want: orientation,
+			})
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJPEGOrientationTruncated(t *testing.T) {
+	data := withAPP1(t, encodeJPEG(t, halves(8, 4)), exif(tiffWithOrientation(binary.BigEndian, 6)))
+	for n := 0; n <= len(data); n++ {
+		if got := jpegOrientation(data[:n]); got < 1 || got > 8 {
+			t.Fatalf("jpegOrientation(data[:%d]) = %d", n, got)
+		}
+	}
+}
+
+func TestTIFFOrientationTruncated(t *testing.T) {
+	tiff := tiffWithOrientation(binary.LittleEndian, 8)
+	for n := 0; n < len(tiff); n++ {
+		want := 1
+		if n >= 22 {
+			// The whole entry is present; only the next-IFD offset is cut.
+			want = 8
+		}
+		if got := tiffOrientation(tiff[:n]); got != want {
+			t.Errorf("tiffOrientation(tiff[:%d]) = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// The source is 4x2 with a red left half; origin is where the source's
+	// top-left pixel ends up.
+	tests := []struct {
+		orientation   int
+		width, height int
+		origin        image.Point
+	}{
+		{1, 4, 2, image.Pt(0, 0)},
+		{2, 4, 2, image.Pt(3, 0)},
+		{3, 4, 2, image.Pt(3, 1)},
+		{4, 4, 2, image.Pt(0, 1)},
+		{5, 2, 4, image.Pt(0, 0)},
+		{6, 2, 4, image.Pt(1, 0)},
+		{7, 2, 4, image.Pt(1, 3)},
+		{8, 2, 4, image.Pt(0, 3)},
+	}
+	for _, tt := range tests {
+		src := halves(4, 2)
+		src.SetRGBA(0, 0, color.RGBA{G: 255, A: 255})
+		dst := applyOrientation(src, tt.orientation)
+		bounds := dst.Bounds()
+		if bounds.Dx() != tt.width || bounds.Dy() != tt.height {
+			t.Errorf("orientation %d: size %dx%d, want %dx%d", tt.orientation, bounds.Dx(), bounds.Dy(), tt.width, tt.height)
+			continue
+		}
+		if r, g, b, _ := dst.At(tt.origin.X, tt.origin.Y).RGBA(); r != 0 || g == 0 || b != 0 {
+			t.Errorf("orientation %d: source origin not at %v", tt.orientation, tt.origin)
+		}
+	}
+}
+
+// hasAPP1 reports whether a JPEG has an APP1 (EXIF/XMP) segment before the
+// image data.
+func hasAPP1(data []byte) bool {
+	offset := 2
+	for offset+4 <= len(data) && data[offset] == 0xFF {
+		marker := data[offset+1]
+		if marker == 0xE1 {
+			return true
+		}
+		if marker == 0xDA {
+			break
+		}
+		offset += 2 + int(binary.BigEndian.Uint16(data[offset+2:]))
+	}
+	return false
+}
+
+func TestProcessAvatarAppliesOrientationAndDropsEXIF(t *testing.T) {
+	// Rotating 90 degrees clockwise turns the red left half into the top.
+	data := withAPP1(t, encodeJPEG(t, halves(40, 20)), exif(tiffWithOrientation(binary.BigEndian, 6)))
+
+	images, err := ProcessAvatar(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != len(AVATAR_SIZES) {
+		t.Fatalf("got %d images, want %d", len(images), len(AVATAR_SIZES))
+	}
+	for i, processed := range images {
+		if processed.Size != AVATAR_SIZES[i] || processed.ContentType != "image/jpeg" {
+			t.Errorf("image %d: size %d, type %s", i, processed.Size, processed.ContentType)
+		}
+		if hasAPP1(processed.Data) {
+			t.Errorf("image %d still has an APP1 segment", processed.Size)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(processed.Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds().Dx() != processed.Size || img.Bounds().Dy() != processed.Size {
+			t.Errorf("image %d is %v", processed.Size, img.Bounds())
+		}
+		if r, _, b, _ := img.At(processed.Size/2, 2).RGBA(); r < b {
+			t.Errorf("image %d: top is not red", processed.Size)
+		}
+		if r, _, b, _ := img.At(processed.Size/2, processed.Size-3).RGBA(); b < r {
+			t.Errorf("image %d: bottom is not blue", processed.Size)
+		}
+	}
+}
+
+func TestProcessAvatarRejectsInvalidImages(t *testing.T) {
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, halves(4, 4)); err != nil {
+		t.Fatal(err)
+	}
+	jpg := encodeJPEG(t, halves(8, 8))
+
+	tests := map[string][]byte{
+		"empty":          nil,
+		"text":           []byte("hello, world"),
+		"truncated png":  pngData.Bytes()[:len(pngData.Bytes())/2],
+		"truncated jpeg": withAPP1(t, jpg, exif(tiffWithOrientation(binary.LittleEndian, 6)))[:40],
+	}
+	for name, data := range tests {
+		if _, err := ProcessAvatar(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package utils
+
+// Value types of list fields.
+const LIST_FIELD_STRING string = "string"
+const LIST_FIELD_INT string = "int"
+const LIST_FIELD_BOOL string = "bool"
+const LIST_FIELD_TIME string = "time"
+
+// Filter operators. A plain field=value parameter is LIST_OP_EQ, field_from
+// is LIST_OP_GTE and field_to is LIST_OP_LT.
+const LIST_OP_EQ string = "eq"
+const LIST_OP_GTE string = "gte"
+const LIST_OP_LT string = "lt"
+
+const LIST_RANGE_FROM_SUFFIX string = "_from"
+const LIST_RANGE_TO_SUFFIX string = "_to"
+
+const SESSION_LIST_DEFAULT_LIMIT int = 25
+const SESSION_LIST_MAX_LIMIT int = 100
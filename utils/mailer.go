@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+// Mailer sends plain text emails, optionally with a calendar invitation.
+type Mailer interface {
+	Send(to, subject, body string) error
+	// SendCalendar sends body together with an iCalendar object for the
+	// given iTIP method, which mail clients show as an invitation.
+	SendCalendar(to, subject, body, method string, calendar []byte) error
+}
+
+// SMTPMailer sends emails through the SMTP server configured in the environment.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	message := strings.Join(append(mailHeaders(m.From, to, subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	), "\r\n")
+	return smtp.SendMail(fmt.Sprintf("%s:%s", m.Host, m.Port), auth, m.From, []string{to}, []byte(message))
+}
+
+func (m *SMTPMailer) SendCalendar(to, subject, body, method string, calendar []byte) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+	text, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=UTF-8"}})
+	if err != nil {
+		return err
+	}
+	text.Write([]byte(body))
+	invite, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {fmt.Sprintf("text/calendar; charset=UTF-8; method=%s", method)},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+	invite.Write([]byte(wrapBase64(calendar)))
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	message := strings.Join(append(mailHeaders(m.From, to, subject),
+		"MIME-Version: 1.0",
+		fmt.Sprintf("Content-Type: multipart/alternative; boundary=%q", writer.Boundary()),
+		"",
+		parts.String(),
+	), "\r\n")
+	return smtp.SendMail(fmt.Sprintf("%s:%s", m.Host, m.Port), auth, m.From, []string{to}, []byte(message))
+}
+
+// mailHeaders returns the From, To and Subject lines of a message. Subjects
+// carry names users chose, so line breaks are removed from every value to
+// keep it from adding headers, and non-ASCII subjects are encoded as RFC 2047
+// encoded words.
+func mailHeaders(from, to, subject string) []string {
+	return []string{
+		"From: " + mailHeaderValue(from),
+		"To: " + mailHeaderValue(to),
+		"Subject: " + mime.QEncoding.Encode("utf-8", mailHeaderValue(subject)),
+	}
+}
+
+// mailHeaderValue replaces line breaks in a header value with spaces.
+func mailHeaderValue(value string) string {
+	return strings.Join(strings.FieldsFunc(value, func(r rune) bool { return r == '\r' || r == '\n' }), " ")
+}
+
+// wrapBase64 encodes data in lines of 76 characters, as MIME requires.
+func wrapBase64(data []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+	return b.String()
+}
+
+// LogMailer writes emails to the log instead of sending them. It is used when
+// no SMTP server is configured, e.g. during local development.
+type LogMailer struct{}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	log.Printf("mail to=%s subject=%q\n%s\n", to, subject, body)
+	return nil
+}
+
+func (m *LogMailer) SendCalendar(to, subject, body, method string, calendar []byte) error {
+	log.Printf("mail to=%s subject=%q method=%s\n%s\n%s\n", to, subject, method, body, calendar)
+	return nil
+}
+
+// NewMailer returns an SMTPMailer when SMTP_HOST is set, otherwise a LogMailer.
+func NewMailer() Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return &LogMailer{}
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	return &SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+}
+
+// SendMailAsync sends an email in the background so the time a request takes
+// does not depend on whether an email was sent.
+func SendMailAsync(to, subject, body string) {
+	go func() {
+		if err := NewMailer().Send(to, subject, body); err != nil {
+			log.Printf("Couldn't send mail to %v. Here's why: %v\n", to, err)
+		}
+	}()
+}
+
+// SendCalendarMailAsync sends an email with a calendar invitation in the
+// background, like SendMailAsync.
+func SendCalendarMailAsync(to, subject, body, method string, calendar []byte) {
+	go func() {
+		if err := NewMailer().SendCalendar(to, subject, body, method, calendar); err != nil {
+			log.Printf("Couldn't send mail to %v. Here's why: %v\n", to, err)
+		}
+	}()
+}
+
+// AppURL returns the frontend base URL from APP_URL, used to build links in emails.
+func AppURL() string {
+	return strings.TrimRight(os.Getenv("APP_URL"), "/")
+}
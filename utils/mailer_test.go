@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMailHeadersCannotInjectHeaders(t *testing.T) {
+	headers := mailHeaders("server@example.com", "bob@example.com", "Eve\r\nBcc: victim@example.com invited you to Acme")
+	for _, header := range headers {
+		if strings.ContainsAny(header, "\r\n") {
+			t.Errorf("header %q contains a line break", header)
+		}
+	}
+	if want := "Subject: Eve Bcc: victim@example.com invited you to Acme"; headers[2] != want {
+		t.Errorf("subject = %q, want %q", headers[2], want)
+	}
+}
+
+func TestMailHeadersEncodeNonASCIISubjects(t *testing.T) {
+	subject := mailHeaders("server@example.com", "bob@example.com", "Zoë invited you to Café")[2]
+	if !strings.HasPrefix(subject, "Subject: =?utf-8?q?") {
+		t.Errorf("subject = %q, want an RFC 2047 encoded word", subject)
+	}
+	if ascii := mailHeaders("server@example.com", "bob@example.com", "Welcome")[2]; ascii != "Subject: Welcome" {
+		t.Errorf("subject = %q, want it unchanged", ascii)
+	}
+}
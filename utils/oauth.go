@@ -0,0 +1,26 @@
+package utils
+
+import "time"
+
+const OAUTH_SCOPE_OPENID string = "openid"
+const OAUTH_SCOPE_PROFILE string = "profile"
+const OAUTH_SCOPE_EMAIL string = "email"
+const OAUTH_SCOPE_OFFLINE_ACCESS string = "offline_access"
+
+const OAUTH_GRANT_AUTHORIZATION_CODE string = "authorization_code"
+const OAUTH_GRANT_REFRESH_TOKEN string = "refresh_token"
+
+const OAUTH_PKCE_METHOD_S256 string = "S256"
+const OAUTH_PKCE_METHOD_PLAIN string = "plain"
+
+const OAUTH_CODE_TTL time.Duration = 10 * time.Minute
+const OAUTH_ACCESS_TOKEN_TTL time.Duration = time.Hour
+const OAUTH_ID_TOKEN_TTL time.Duration = time.Hour
+const OAUTH_REFRESH_TOKEN_TTL time.Duration = 30 * 24 * time.Hour
+const OAUTH_DEFAULT_KEY_ROTATION time.Duration = 30 * 24 * time.Hour
+
+// OAUTH_SIGNING_KEY_LOCK_ID is the Postgres advisory lock taken while the
+// signing key is rotated.
+const OAUTH_SIGNING_KEY_LOCK_ID int64 = 0x6f696463
+
+var OAUTH_SUPPORTED_SCOPES = []string{OAUTH_SCOPE_OPENID, OAUTH_SCOPE_PROFILE, OAUTH_SCOPE_EMAIL, OAUTH_SCOPE_OFFLINE_ACCESS}
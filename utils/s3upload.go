@@ -1,15 +1,20 @@
 package utils
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 type BucketBasics struct {
@@ -19,6 +24,10 @@ type BucketBasics struct {
 var S3_URL_FORMAT string = "https://%s.s3.amazonaws.com/%s"
 var S3_REGION string = os.Getenv("AWS_S3_REGION")
 
+// S3_MULTIPART_PART_SIZE is the size of the parts of streamed uploads. S3
+// requires at least 5 MiB for every part but the last.
+const S3_MULTIPART_PART_SIZE int = 8 << 20
+
 func GetS3Url(fileName string) string {
 	var S3_BUCKET_NAME string = os.Getenv("AWS_S3_BUCKET_NAME")
 	return fmt.Sprintf(S3_URL_FORMAT, S3_BUCKET_NAME, fileName)
@@ -60,3 +69,188 @@ func UploadFileToS3(objectKey string, fileName string) error {
 	}
 	return err
 }
+
+// UploadBytesToS3 puts data into an object in the bucket with the given content type.
+func UploadBytesToS3(objectKey string, data []byte, contentType string) error {
+	s3ClientBasics := NewBucketBasics()
+
+	var S3_BUCKET_NAME string = os.Getenv("AWS_S3_BUCKET_NAME")
+	_, err := s3ClientBasics.S3Client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:      aws.String(S3_BUCKET_NAME),
+		Key:         aws.String(objectKey),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+		ACL:         "public-read",
+	})
+	if err != nil {
+		log.Printf("Couldn't upload %v to %v:%v. Here's why: %v\n",
+			contentType, S3_BUCKET_NAME, objectKey, err)
+	}
+	return err
+}
+
+// UploadPrivateStreamToS3 streams size bytes from body into an object that is
+// only reachable through presigned links.
+func UploadPrivateStreamToS3(objectKey string, body io.Reader, size int64, contentType string) error {
+	s3ClientBasics := NewBucketBasics()
+
+	var S3_BUCKET_NAME string = os.Getenv("AWS_S3_BUCKET_NAME")
+	_, err := s3ClientBasics.S3Client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:        aws.String(S3_BUCKET_NAME),
+		Key:           aws.String(objectKey),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String(contentType),
+		ACL:           "private",
+	})
+	if err != nil {
+		log.Printf("Couldn't upload %v to %v:%v. Here's why: %v\n",
+			contentType, S3_BUCKET_NAME, objectKey, err)
+	}
+	return err
+}
+
+// UploadPrivateMultipartToS3 streams body into an object that is only
+// reachable through presigned links, using a multipart upload so only one
+// part is held in memory at a time. The upload is aborted when reading body
+// or uploading a part fails.
+func UploadPrivateMultipartToS3(objectKey string, body io.Reader, contentType string) error {
+	s3ClientBasics := NewBucketBasics()
+	ctx := context.TODO()
+
+	var S3_BUCKET_NAME string = os.Getenv("AWS_S3_BUCKET_NAME")
+	upload, err := s3ClientBasics.S3Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(S3_BUCKET_NAME),
+		Key:         aws.String(objectKey),
+		ContentType: aws.String(contentType),
+		ACL:         "private",
+	})
+	if err != nil {
+		return err
+	}
+
+	parts, err := uploadParts(ctx, s3ClientBasics.S3Client, upload, body)
+	if err == nil {
+		_, err = s3ClientBasics.S3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          upload.Bucket,
+			Key:             upload.Key,
+			UploadId:        upload.UploadId,
+			MultipartUpload: &s3types.CompletedMultipartUpload{Parts: parts},
+		})
+	}
+	if err != nil {
+		log.Printf("Couldn't upload %v to %v:%v. Here's why: %v\n",
+			contentType, S3_BUCKET_NAME, objectKey, err)
+		// Parts of unfinished uploads are stored and billed until aborted.
+		_, abortErr := s3ClientBasics.S3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   upload.Bucket,
+			Key:      upload.Key,
+			UploadId: upload.UploadId,
+		})
+		if abortErr != nil {
+			log.Printf("Couldn't abort upload of %v:%v. Here's why: %v\n", S3_BUCKET_NAME, objectKey, abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// uploadParts reads body in parts of S3_MULTIPART_PART_SIZE and uploads them
+// to a multipart upload.
+func uploadParts(ctx context.Context, client *s3.Client, upload *s3.CreateMultipartUploadOutput, body io.Reader) ([]s3types.CompletedPart, error) {
+	var parts []s3types.CompletedPart
+	buf := make([]byte, S3_MULTIPART_PART_SIZE)
+	for number := int32(1); ; number++ {
+		n, err := io.ReadFull(body, buf)
+		if err == io.EOF && number > 1 {
+			return parts, nil
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		output, uploadErr := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     upload.Bucket,
+			Key:        upload.Key,
+			UploadId:   upload.UploadId,
+			PartNumber: aws.Int32(number),
+			Body:       bytes.NewReader(buf[:n]),
+		})
+		if uploadErr != nil {
+			return nil, uploadErr
+		}
+		parts = append(parts, s3types.CompletedPart{ETag: output.ETag, PartNumber: aws.Int32(number)})
+		if err != nil {
+			// A short read was the last part.
+			return parts, nil
+		}
+	}
+}
+
+// OpenFromS3 opens an object in the bucket for reading. The caller closes it.
+func OpenFromS3(objectKey string) (io.ReadCloser, error) {
+	s3ClientBasics := NewBucketBasics()
+
+	var S3_BUCKET_NAME string = os.Getenv("AWS_S3_BUCKET_NAME")
+	output, err := s3ClientBasics.S3Client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(S3_BUCKET_NAME),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Body, nil
+}
+
+// DeleteFromS3 removes objects from the bucket and returns the keys that
+// could not be deleted.
+func DeleteFromS3(objectKeys []string) (failed []string, err error) {
+	s3ClientBasics := NewBucketBasics()
+
+	var S3_BUCKET_NAME string = os.Getenv("AWS_S3_BUCKET_NAME")
+	// DeleteObjects takes at most 1000 keys per call.
+	for start := 0; start < len(objectKeys); start += 1000 {
+		batch := objectKeys[start:min(start+1000, len(objectKeys))]
+		objects := make([]s3types.ObjectIdentifier, 0, len(batch))
+		for _, key := range batch {
+			objects = append(objects, s3types.ObjectIdentifier{Key: aws.String(key)})
+		}
+		output, err := s3ClientBasics.S3Client.DeleteObjects(context.TODO(), &s3.DeleteObjectsInput{
+			Bucket: aws.String(S3_BUCKET_NAME),
+			Delete: &s3types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return append(failed, objectKeys[start:]...), err
+		}
+		for _, objectErr := range output.Errors {
+			failed = append(failed, aws.ToString(objectErr.Key))
+		}
+	}
+	return failed, nil
+}
+
+// PresignS3Download returns a link that downloads an object until it expires.
+// filename is suggested to the browser through Content-Disposition.
+func PresignS3Download(objectKey string, filename string, expires time.Duration) (string, error) {
+	s3ClientBasics := NewBucketBasics()
+
+	var S3_BUCKET_NAME string = os.Getenv("AWS_S3_BUCKET_NAME")
+	request, err := s3.NewPresignClient(s3ClientBasics.S3Client).PresignGetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket:                     aws.String(S3_BUCKET_NAME),
+		Key:                        aws.String(objectKey),
+		ResponseContentDisposition: aws.String(fmt.Sprintf("attachment; filename=%q", filename)),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+	return request.URL, nil
+}
+
+// S3KeyFromUrl returns the object key of a URL built by GetS3Url. ok is false
+// for URLs that point somewhere else.
+func S3KeyFromUrl(url string) (key string, ok bool) {
+	prefix := GetS3Url("")
+	if !strings.HasPrefix(url, prefix) || len(url) == len(prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(url, prefix), true
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestUploadPartsSplitsTheStream(t *testing.T) {
+	var mu sync.Mutex
+	sizes := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		number := r.URL.Query().Get("partNumber")
+		mu.Lock()
+		sizes[number] = len(body)
+		mu.Unlock()
+		w.Header().Set("ETag", fmt.Sprintf("%q", "etag-"+number))
+	}))
+	defer server.Close()
+
+	client := s3.New(s3.Options{
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	})
+	upload := &s3.CreateMultipartUploadOutput{Bucket: aws.String("bucket"), Key: aws.String("export.zip"), UploadId: aws.String("upload")}
+	body := bytes.NewReader(make([]byte, 2*S3_MULTIPART_PART_SIZE+3))
+
+	parts, err := uploadParts(context.Background(), client, upload, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("uploaded %d parts, want 3", len(parts))
+	}
+	want := map[string]int{"1": S3_MULTIPART_PART_SIZE, "2": S3_MULTIPART_PART_SIZE, "3": 3}
+	for number, size := range want {
+		if sizes[number] != size {
+			t.Errorf("part %s has %d bytes, want %d", number, sizes[number], size)
+		}
+	}
+	for i, part := range parts {
+		if aws.ToInt32(part.PartNumber) != int32(i+1) || aws.ToString(part.ETag) != fmt.Sprintf("%q", fmt.Sprintf("etag-%d", i+1)) {
+			t.Errorf("part %d = %v %v", i+1, aws.ToInt32(part.PartNumber), aws.ToString(part.ETag))
+		}
+	}
+}
+
+func TestUploadPartsStopsOnReadErrors(t *testing.T) {
+	client := s3.New(s3.Options{Region: "us-east-1"})
+	upload := &s3.CreateMultipartUploadOutput{Bucket: aws.String("bucket"), Key: aws.String("export.zip"), UploadId: aws.String("upload")}
+	reader, writer := io.Pipe()
+	writer.CloseWithError(fmt.Errorf("archive failed"))
+
+	if _, err := uploadParts(context.Background(), client, upload, reader); err == nil || err.Error() != "archive failed" {
+		t.Fatalf("error = %v, want the read error", err)
+	}
+}
@@ -0,0 +1,20 @@
+package utils
+
+import "time"
+
+const SAML_BINDING_HTTP_POST string = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+const SAML_BINDING_HTTP_REDIRECT string = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+const SAML_NAMEID_FORMAT_EMAIL string = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+const SAML_STATUS_SUCCESS string = "urn:oasis:names:tc:SAML:2.0:status:Success"
+const SAML_CONFIRMATION_METHOD_BEARER string = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+const SAML_PROTOCOL_NAMESPACE string = "urn:oasis:names:tc:SAML:2.0:protocol"
+const SAML_ASSERTION_NAMESPACE string = "urn:oasis:names:tc:SAML:2.0:assertion"
+
+// SAML_CLOCK_SKEW is the tolerance applied to assertion validity windows.
+const SAML_CLOCK_SKEW time.Duration = 3 * time.Minute
+
+// SAML_REQUEST_TTL is how long the IdP has to answer an AuthnRequest.
+const SAML_REQUEST_TTL time.Duration = 10 * time.Minute
+
+var SAML_EMAIL_ATTRIBUTES = []string{"email", "mail", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"}
+var SAML_NAME_ATTRIBUTES = []string{"name", "displayName", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name"}
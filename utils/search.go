@@ -0,0 +1,10 @@
+package utils
+
+import "time"
+
+const USER_SEARCH_DEFAULT_LIMIT int = 10
+
+// USER_SEARCH_RATE_LIMIT searches are allowed per user every
+// USER_SEARCH_RATE_WINDOW.
+const USER_SEARCH_RATE_LIMIT int = 60
+const USER_SEARCH_RATE_WINDOW time.Duration = time.Minute
@@ -1,6 +1,21 @@
 package utils
 
+import "time"
+
 const SESSION_CATEGORY_ADD_FILES string = "1"
 const SESSION_CATEGORY_ADD_PERSONA string = "2"
 const SESSION_CATEGORY_ADD_IA string = "3"
 const SESSION_CATEGORY_ADD_CJM string = "4"
+
+var SESSION_CATEGORIES = []string{SESSION_CATEGORY_ADD_FILES, SESSION_CATEGORY_ADD_PERSONA, SESSION_CATEGORY_ADD_IA, SESSION_CATEGORY_ADD_CJM}
+
+const SESSION_ATTACHMENT_MAX_BYTES int64 = 25 << 20
+const SESSION_ATTACHMENT_MAX_FILES int = 10
+
+// SESSION_ATTACHMENT_LINK_TTL is how long the download links of attachments
+// stay valid.
+const SESSION_ATTACHMENT_LINK_TTL time.Duration = 15 * time.Minute
+
+// SESSION_DEFAULT_TIMEZONE is used for sessions whose creator has no time
+// zone.
+const SESSION_DEFAULT_TIMEZONE string = "UTC"
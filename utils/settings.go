@@ -0,0 +1,6 @@
+package utils
+
+const USER_SETTINGS_DEFAULT_TIMEZONE string = "UTC"
+const USER_SETTINGS_DEFAULT_DATE_FORMAT string = "YYYY-MM-DD"
+const USER_SETTINGS_DEFAULT_TIME_FORMAT string = "24h"
+const USER_SETTINGS_DEFAULT_WEEK_START string = "monday"
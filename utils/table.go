@@ -4,3 +4,18 @@ var SESSIONS_TABLE string = "sessions"
 var SESSION_ATTACHMENTS_TABLE string = "session_attachments"
 var SESSION_COLLABORATORS_TABLE string = "session_collaborators"
 var USERS_TABLE string = "users"
+var WORKSPACES_TABLE string = "workspaces"
+var WORKSPACE_USERS_TABLE string = "workspace_users"
+var OAUTH_CLIENTS_TABLE string = "oauth_clients"
+var OAUTH_AUTHORIZATION_CODES_TABLE string = "oauth_authorization_codes"
+var OAUTH_REFRESH_TOKENS_TABLE string = "oauth_refresh_tokens"
+var OAUTH_CONSENTS_TABLE string = "oauth_consents"
+var OAUTH_SIGNING_KEYS_TABLE string = "oauth_signing_keys"
+var SAML_ASSERTION_REPLAYS_TABLE string = "saml_assertion_replays"
+var SAML_PENDING_REQUESTS_TABLE string = "saml_pending_requests"
+var PASSWORD_RESET_TOKENS_TABLE string = "password_reset_tokens"
+var PENDING_REGISTRATIONS_TABLE string = "pending_registrations"
+var DATA_EXPORTS_TABLE string = "data_exports"
+var WORKSPACE_INVITATIONS_TABLE string = "workspace_invitations"
+var WORKSPACE_USAGE_TABLE string = "workspace_usage"
+var CALENDAR_FEEDS_TABLE string = "calendar_feeds"
@@ -0,0 +1,28 @@
+package utils
+
+import "time"
+
+// Roles of workspace members. The owner is marked separately with
+// WorkspaceUser.IsOwner and always has every permission.
+const WORKSPACE_ROLE_ADMIN string = "admin"
+const WORKSPACE_ROLE_MEMBER string = "member"
+const WORKSPACE_ROLE_VIEWER string = "viewer"
+
+const WORKSPACE_HEADER_NAME string = "X-Workspace-ID"
+
+// TENANT_SCOPED_TABLES hold rows that belong to a single workspace. Queries on
+// them are filtered to the workspace of the request, see models.WithTenant.
+var TENANT_SCOPED_TABLES = []string{SESSIONS_TABLE, SESSION_ATTACHMENTS_TABLE, SESSION_COLLABORATORS_TABLE}
+
+// Postgres settings carrying the tenant of a transaction for row-level
+// security policies.
+const TENANT_WORKSPACE_SETTING string = "app.workspace_id"
+const TENANT_USER_SETTING string = "app.user_id"
+const TENANT_BYPASS_SETTING string = "app.bypass_tenant"
+
+const WORKSPACE_INVITATION_TTL time.Duration = 7 * 24 * time.Hour
+
+// Deleted workspaces can be restored by their owner until the retention
+// window ends. The purge checks for expired ones every WORKSPACE_PURGE_INTERVAL.
+const WORKSPACE_DELETION_RETENTION time.Duration = 30 * 24 * time.Hour
+const WORKSPACE_PURGE_INTERVAL time.Duration = time.Hour